@@ -6,10 +6,14 @@ import (
 	"encoding/hex"
 	"fmt"
 	mathrand "math/rand"
+	"net"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	wailsRuntime "github.com/wailsapp/wails/v2/pkg/runtime"
 
@@ -17,10 +21,13 @@ import (
 	"github.com/omid3098/autopaqet/gui/internal/diag"
 	"github.com/omid3098/autopaqet/gui/internal/network"
 	"github.com/omid3098/autopaqet/gui/internal/npcap"
+	"github.com/omid3098/autopaqet/gui/internal/outbound"
 	"github.com/omid3098/autopaqet/gui/internal/process"
 	"github.com/omid3098/autopaqet/gui/internal/profile"
 	"github.com/omid3098/autopaqet/gui/internal/proxy"
+	"github.com/omid3098/autopaqet/gui/internal/qr"
 	"github.com/omid3098/autopaqet/gui/internal/uri"
+	"github.com/omid3098/autopaqet/gui/internal/validate"
 )
 
 // ConnectionState represents the current connection state.
@@ -49,24 +56,53 @@ type NpcapStatus struct {
 	DownloadURL string `json:"download_url"`
 }
 
+// ConfigImport is the result of importing a paqet YAML config file.
+type ConfigImport struct {
+	Profile     *profile.Profile `json:"profile"`
+	Unknown     []string         `json:"unknown,omitempty"`
+	Unsupported []string         `json:"unsupported,omitempty"`
+}
+
+// SigningKey is an Ed25519 key pair for signing share links.
+type SigningKey struct {
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+}
+
+// ProfilesChanged is sent with the "profiles:changed" event when another
+// program changed profiles.json, or reloading it failed.
+type ProfilesChanged struct {
+	*profile.Change
+	Error string `json:"error,omitempty"`
+}
+
 // App struct holds the application state and bound methods.
 type App struct {
-	ctx           context.Context
-	store         *profile.Store
-	connState     ConnectionState
-	lastError     string
-	networkInfo   *NetworkInfo
-	manager       *process.Manager
-	detector      network.Detector
-	npcapChecker  npcap.Checker
-	proxySetter   proxy.Setter
-	pacServer     *proxy.PACServer
-	configDir     string
-	activeProfile *profile.Profile
-	binaryPath    string
-	cancelDiag    context.CancelFunc
-	diagMu        sync.Mutex
-	diagActive    bool
+	ctx            context.Context
+	store          *profile.Store
+	presets        *profile.PresetStore
+	connState      ConnectionState
+	lastError      string
+	networkInfo    *NetworkInfo
+	manager        *process.Manager
+	detector       network.Detector
+	npcapChecker   npcap.Checker
+	proxySetter    proxy.Setter
+	pacServer      *proxy.PACServer
+	configDir      string
+	activeProfile  *profile.Profile
+	activeOpts     *config.Options // options paqet is running with
+	activeConfig   string          // YAML generated from activeOpts
+	activeEndpoint string          // server endpoint that verified
+	sessionMu      sync.Mutex
+	sessionID      string    // profile of the running connection, for history
+	sessionStart   time.Time // when it connected
+	binaryPath     string
+	cancelDiag     context.CancelFunc
+	diagMu         sync.Mutex
+	diagActive     bool
+	serverMu       sync.Mutex
+	serverConfigs  map[string]*config.ServerOptions // by profile ID
 }
 
 // managerRunner adapts process.Manager to diag.PaqetRunner.
@@ -97,6 +133,25 @@ func (a *App) startup(ctx context.Context) {
 	}
 	a.store = store
 
+	// An unreadable presets.json is moved aside and reported through
+	// StoreResets. Without user presets the built-in presets still work.
+	presets, err := profile.NewPresetStore(dir)
+	if err != nil {
+		a.lastError = fmt.Sprintf("failed to initialize preset store: %v", err)
+	} else {
+		a.presets = presets
+	}
+
+	// Sync subscriptions that are due, now and every minute
+	go a.store.RunSubscriptionSync(ctx, time.Minute, a.subscriptionSynced)
+
+	// Pick up profiles.json changes made by other programs
+	go func() {
+		if err := a.store.Watch(ctx, a.profilesChanged); err != nil {
+			a.profilesChanged(nil, err)
+		}
+	}()
+
 	// Find paqet binary
 	a.binaryPath = findPaqetBinary()
 
@@ -126,6 +181,7 @@ func (a *App) startup(ctx context.Context) {
 
 // shutdown is called when the app is closing.
 func (a *App) shutdown(ctx context.Context) {
+	a.endSession()
 	if a.manager != nil {
 		a.manager.Stop()
 	}
@@ -167,50 +223,11 @@ func (a *App) Connect(profileID string) error {
 	a.diagActive = true
 	a.diagMu.Unlock()
 
-	// Detect network
-	netInfo, err := a.detector.Detect()
-	if err != nil {
-		a.finishDiag()
-		a.emitState(StateError)
-		return fmt.Errorf("network detection failed: %w", err)
-	}
-
 	// Build config options
 	socksListen := p.SocksListen
 	if socksListen == "" {
 		socksListen = "127.0.0.1:1080"
 	}
-	serverAddr := fmt.Sprintf("%s:%d", p.Host, p.Port)
-
-	configOpts := &config.Options{
-		ServerAddr:    serverAddr,
-		Key:           p.Key,
-		InterfaceName: netInfo.InterfaceName,
-		LocalAddr:     fmt.Sprintf("%s:%d", netInfo.LocalIP, 10000+mathrand.Intn(55000)),
-		GatewayMAC:    netInfo.GatewayMAC,
-		NpcapGUID:     netInfo.NpcapGUID,
-		SocksListen:   socksListen,
-		SocksUser:     p.SocksUser,
-		SocksPass:     p.SocksPass,
-		Mode:          p.Mode,
-		Conn:          p.Conn,
-		MTU:           p.MTU,
-		Block:         p.Block,
-		RcvWnd:        p.RcvWnd,
-		SndWnd:        p.SndWnd,
-		DShard:        p.DShard,
-		PShard:        p.PShard,
-		DSCP:          p.DSCP,
-		SmuxBuf:       p.SmuxBuf,
-		StreamBuf:     p.StreamBuf,
-		TCPBuf:        p.TCPBuf,
-		UDPBuf:        p.UDPBuf,
-		SockBuf:       p.SockBuf,
-		LocalFlag:     p.LocalFlag,
-		RemoteFlag:    p.RemoteFlag,
-		Forward:       p.Forward,
-		LogLevel:      "info",
-	}
 
 	// Create prober
 	runner := &managerRunner{m: a.manager}
@@ -220,42 +237,144 @@ func (a *App) Connect(profileID string) error {
 		wailsRuntime.EventsEmit(a.ctx, "log:line", line)
 	})
 
-	// Run diagnostics
-	npcapChecker := a.npcapChecker
-	result := prober.Run(ctx, &diag.RunOptions{
-		ConfigOpts:  configOpts,
-		SocksAddr:   socksListen,
-		ProfileName: p.Name,
-		ServerAddr:  serverAddr,
-		NpcapCheck: func() (bool, string) {
-			if npcapChecker == nil {
-				return true, ""
-			}
-			status := npcapChecker.Check()
-			return status.Installed, status.DownloadURL
-		},
-		IsWindows: runtime.GOOS == "windows",
-	})
+	// Try each endpoint in order until one verifies
+	endpoints := p.Endpoints()
+	var failures []string
+	for i, endpoint := range endpoints {
+		if i > 0 {
+			wailsRuntime.EventsEmit(a.ctx, "connection:failover", &Failover{
+				ProfileID: p.ID,
+				Failed:    endpoints[i-1],
+				Next:      endpoint,
+				Reason:    failures[len(failures)-1],
+			})
+		}
 
-	a.finishDiag()
+		configOpts, err := a.endpointOptions(p, endpoint, socksListen)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", endpoint, err))
+			continue
+		}
 
-	if result.Success {
-		// Re-enable manager state handler for crash detection
-		a.manager.SetStateChangeHandler(func(state process.State) {
-			if state == process.StateError || state == process.StateIdle {
-				a.emitState(ConnectionState(state))
-				a.activeProfile = nil
-			}
+		// Run diagnostics
+		npcapChecker := a.npcapChecker
+		result := prober.Run(ctx, &diag.RunOptions{
+			ConfigOpts:   configOpts,
+			ServerConfig: a.serverConfig(p.ID),
+			SocksAddr:    socksListen,
+			ProfileName:  p.Name,
+			ServerAddr:   endpoint,
+			NpcapCheck: func() (bool, string) {
+				if npcapChecker == nil {
+					return true, ""
+				}
+				status := npcapChecker.Check()
+				return status.Installed, status.DownloadURL
+			},
+			IsWindows: runtime.GOOS == "windows",
 		})
-		a.emitState(StateConnected)
-		return nil
+
+		if result.Success {
+			a.finishDiag()
+			a.startSession(p.ID, profile.Attempt{
+				Time:     time.Now(),
+				Success:  true,
+				Endpoint: endpoint,
+				ReadyMs:  result.SocksReadyMs,
+			})
+			a.activeOpts = configOpts
+			a.activeConfig, _ = config.Generate(configOpts)
+			a.activeEndpoint = endpoint
+			a.store.SetLastEndpoint(p.ID, endpoint)
+			a.watchProcess()
+			a.emitState(StateConnected)
+			wailsRuntime.EventsEmit(a.ctx, "connection:endpoint", endpoint)
+			return nil
+		}
+
+		// Stop paqet if still running before the next attempt
+		a.manager.Stop()
+		failures = append(failures, fmt.Sprintf("%s: %s", endpoint, result.Summary))
+		if ctx.Err() != nil {
+			break
+		}
 	}
 
-	// Failure — stop paqet if still running
-	a.manager.Stop()
+	a.finishDiag()
 	a.emitState(StateError)
-	a.lastError = result.Summary
-	return fmt.Errorf("%s", result.Summary)
+	if len(endpoints) == 1 {
+		// Keep the single-endpoint message as it was, without the address
+		a.lastError = strings.TrimPrefix(failures[0], endpoints[0]+": ")
+	} else {
+		a.lastError = fmt.Sprintf("all %d endpoints failed: %s", len(endpoints), strings.Join(failures, "; "))
+	}
+	if ctx.Err() == nil { // a cancelled attempt says nothing about the server
+		a.store.RecordAttempt(p.ID, profile.Attempt{Time: time.Now(), Summary: a.lastError})
+	}
+	return fmt.Errorf("%s", a.lastError)
+}
+
+// startSession records a successful connection attempt and starts timing the
+// session for the profile's history.
+func (a *App) startSession(profileID string, attempt profile.Attempt) {
+	a.store.RecordAttempt(profileID, attempt)
+
+	a.sessionMu.Lock()
+	a.sessionID, a.sessionStart = profileID, attempt.Time
+	a.sessionMu.Unlock()
+}
+
+// endSession adds the finished session's duration to the profile's history.
+// It is called on every way a connection ends, and only counts the first.
+func (a *App) endSession() {
+	a.sessionMu.Lock()
+	id, start := a.sessionID, a.sessionStart
+	a.sessionID = ""
+	a.sessionMu.Unlock()
+
+	if id != "" && a.store != nil {
+		a.store.RecordSession(id, time.Since(start))
+	}
+}
+
+// Failover is emitted when Connect moves on to the next endpoint of a profile.
+type Failover struct {
+	ProfileID string `json:"profile_id"`
+	Failed    string `json:"failed"`
+	Next      string `json:"next"`
+	Reason    string `json:"reason"`
+}
+
+// endpointOptions detects the route to an endpoint and builds the paqet
+// options for connecting the profile through it.
+func (a *App) endpointOptions(p *profile.Profile, endpoint, socksListen string) (*config.Options, error) {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	netInfo, err := a.detector.Detect(routeHost(host))
+	if err != nil {
+		return nil, fmt.Errorf("network detection failed: %w", err)
+	}
+
+	configOpts := profileOptions(p)
+	configOpts.ServerAddr = endpoint
+	configOpts.InterfaceName = netInfo.InterfaceName
+	configOpts.LocalAddr = net.JoinHostPort(netInfo.LocalIP, strconv.Itoa(10000+mathrand.Intn(55000)))
+	configOpts.GatewayMAC = netInfo.GatewayMAC
+	configOpts.NpcapGUID = netInfo.NpcapGUID
+	configOpts.SocksListen = socksListen
+	configOpts.LogLevel = "info"
+	return configOpts, nil
+}
+
+// ActiveEndpoint returns the server endpoint of the current connection, which
+// may be one of the profile's fallbacks. It is empty when not connected.
+func (a *App) ActiveEndpoint() string {
+	if a.connState != StateConnected {
+		return ""
+	}
+	return a.activeEndpoint
 }
 
 // CancelConnect cancels an in-progress diagnostic/connection attempt.
@@ -272,6 +391,18 @@ func (a *App) CancelConnect() {
 	a.emitState(StateIdle)
 }
 
+// watchProcess re-enables the manager state handler for crash detection.
+func (a *App) watchProcess() {
+	a.manager.SetStateChangeHandler(func(state process.State) {
+		if state == process.StateError || state == process.StateIdle {
+			a.endSession()
+			a.emitState(ConnectionState(state))
+			a.activeProfile = nil
+			a.activeOpts = nil
+		}
+	})
+}
+
 func (a *App) emitState(state ConnectionState) {
 	a.connState = state
 	wailsRuntime.EventsEmit(a.ctx, "connection:state", string(state))
@@ -286,6 +417,8 @@ func (a *App) finishDiag() {
 
 // Disconnect stops the running paqet process.
 func (a *App) Disconnect() error {
+	a.endSession()
+
 	// Disable proxy first
 	if a.proxySetter != nil && a.proxySetter.IsSystemProxyEnabled() {
 		a.proxySetter.DisableSystemProxy()
@@ -310,6 +443,124 @@ func (a *App) GetConnectionState() ConnectionState {
 	return a.connState
 }
 
+// LiveUpdate describes how a profile edit was applied to the running connection.
+type LiveUpdate struct {
+	ProfileID string   `json:"profile_id"`
+	Restarted bool     `json:"restarted"`
+	Changed   []string `json:"changed,omitempty"` // config key paths
+	Message   string   `json:"message"`
+}
+
+// applyLiveUpdate regenerates the running config from an edited profile and
+// diffs it against the current one. A rename is applied in place. Any config
+// change restarts paqet with the new config; the system proxy stays enabled
+// throughout. This includes SOCKS username and password changes: paqet reads
+// its config only at startup, so they cannot be applied in place, and the
+// returned message tells the user that is why paqet restarted. The active
+// options change only once paqet runs with them.
+func (a *App) applyLiveUpdate(p *profile.Profile) (*LiveUpdate, error) {
+	old := a.activeOpts
+	opts := profileOptions(p)
+	opts.InterfaceName = old.InterfaceName
+	opts.LocalAddr = old.LocalAddr
+	opts.GatewayMAC = old.GatewayMAC
+	opts.NpcapGUID = old.NpcapGUID
+	opts.LogLevel = old.LogLevel
+	if opts.SocksListen == "" {
+		opts.SocksListen = "127.0.0.1:1080"
+	}
+
+	// Stay on the endpoint that verified while the profile still lists it.
+	endpoint := p.Endpoints()[0]
+	for _, ep := range p.Endpoints() {
+		if ep == a.activeEndpoint {
+			endpoint = ep
+		}
+	}
+	opts.ServerAddr = endpoint
+
+	// A new host may need a different route (e.g. IPv4 to IPv6).
+	oldHost, _, _ := net.SplitHostPort(old.ServerAddr)
+	newHost, _, _ := net.SplitHostPort(endpoint)
+	if newHost != oldHost && a.detector != nil {
+		netInfo, err := a.detector.Detect(routeHost(newHost))
+		if err != nil {
+			return nil, fmt.Errorf("network detection failed: %w", err)
+		}
+		_, port, _ := net.SplitHostPort(old.LocalAddr)
+		opts.InterfaceName = netInfo.InterfaceName
+		opts.LocalAddr = net.JoinHostPort(netInfo.LocalIP, port)
+		opts.GatewayMAC = netInfo.GatewayMAC
+		opts.NpcapGUID = netInfo.NpcapGUID
+	}
+
+	newConfig, err := config.Generate(opts)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := config.Diff(a.activeConfig, newConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	update := &LiveUpdate{ProfileID: p.ID, Changed: changed}
+	configPath := filepath.Join(a.configDir, diag.ConfigFileName)
+	switch {
+	case len(changed) == 0 && p.Name != a.activeProfile.Name:
+		update.Message = "Profile renamed in place; paqet was not restarted"
+	case len(changed) == 0:
+		update.Message = "Profile saved; the running connection already matches"
+	default:
+		if err := os.WriteFile(configPath, []byte(newConfig), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write config: %w", err)
+		}
+		// Suppress the idle/starting transitions of the restart.
+		a.manager.SetStateChangeHandler(nil)
+		if err := a.manager.Restart(configPath); err != nil {
+			a.endSession()
+			a.activeProfile = nil
+			a.activeOpts = nil
+			a.emitState(StateError)
+			return nil, err
+		}
+		a.watchProcess()
+		update.Restarted = true
+		update.Message = "Restarted paqet to apply: " + strings.Join(changed, ", ") + ". The connection dropped briefly"
+		if onlySocksCredentials(changed) {
+			// paqet reads its config only at startup, so credentials
+			// cannot change in place.
+			update.Message = "Restarted paqet to apply the new SOCKS credentials; they cannot change in place because paqet reads them only at startup. The connection dropped briefly"
+		}
+
+		if opts.SocksListen != old.SocksListen && a.pacServer != nil {
+			if err := a.restartPAC(opts.SocksListen); err != nil {
+				update.Message += fmt.Sprintf(" (system proxy update failed: %v)", err)
+			}
+		}
+	}
+
+	if endpoint != a.activeEndpoint {
+		a.store.SetLastEndpoint(p.ID, endpoint)
+		wailsRuntime.EventsEmit(a.ctx, "connection:endpoint", endpoint)
+	}
+	a.activeProfile = p
+	a.activeOpts = opts
+	a.activeConfig = newConfig
+	a.activeEndpoint = endpoint
+	return update, nil
+}
+
+// onlySocksCredentials reports whether changed holds only the SOCKS username
+// and password.
+func onlySocksCredentials(changed []string) bool {
+	for _, key := range changed {
+		if key != "socks5[0].username" && key != "socks5[0].password" {
+			return false
+		}
+	}
+	return len(changed) > 0
+}
+
 // --- System Proxy Methods ---
 
 // EnableSystemProxy starts the PAC server and sets the system proxy.
@@ -339,6 +590,23 @@ func (a *App) EnableSystemProxy() error {
 	return nil
 }
 
+// restartPAC points the PAC server at a new SOCKS address and re-applies the
+// system proxy, since the PAC URL's port may change.
+func (a *App) restartPAC(socksAddr string) error {
+	a.pacServer.Stop()
+	a.pacServer = proxy.NewPACServer(socksAddr)
+	if _, err := a.pacServer.Start(); err != nil {
+		a.pacServer = nil
+		return fmt.Errorf("failed to start PAC server: %w", err)
+	}
+	if a.proxySetter != nil && a.proxySetter.IsSystemProxyEnabled() {
+		if err := a.proxySetter.EnableSystemProxy(a.pacServer.GetPACURL()); err != nil {
+			return fmt.Errorf("failed to set system proxy: %w", err)
+		}
+	}
+	return nil
+}
+
 // DisableSystemProxy removes the system proxy and stops the PAC server.
 func (a *App) DisableSystemProxy() error {
 	if a.proxySetter != nil {
@@ -361,12 +629,13 @@ func (a *App) IsSystemProxyEnabled() bool {
 
 // --- Network Methods ---
 
-// DetectNetwork auto-detects the network configuration.
-func (a *App) DetectNetwork() (*NetworkInfo, error) {
+// DetectNetwork auto-detects the network configuration used to reach
+// serverHost. An empty host detects the default IPv4 route.
+func (a *App) DetectNetwork(serverHost string) (*NetworkInfo, error) {
 	if a.detector == nil {
 		return nil, fmt.Errorf("network detector not initialized")
 	}
-	info, err := a.detector.Detect()
+	info, err := a.detector.Detect(routeHost(serverHost))
 	if err != nil {
 		return nil, err
 	}
@@ -404,6 +673,31 @@ func (a *App) ListProfiles() []*profile.Profile {
 	return a.store.List()
 }
 
+// ProfileRecovery reports whether profiles were restored from a backup at
+// startup because profiles.json was unreadable, or nil if it loaded normally.
+func (a *App) ProfileRecovery() *profile.Recovery {
+	if a.store == nil {
+		return nil
+	}
+	return a.store.Recovery()
+}
+
+// StoreResets reports the files other than profiles.json, such as
+// subscriptions.json, publishers.json, history.json or presets.json, that
+// were unreadable at startup and moved aside.
+func (a *App) StoreResets() []*profile.Recovery {
+	if a.store == nil {
+		return nil
+	}
+	resets := a.store.Resets()
+	if a.presets != nil {
+		if rec := a.presets.Reset(); rec != nil {
+			resets = append(resets, rec)
+		}
+	}
+	return resets
+}
+
 // CreateProfile creates a new profile.
 func (a *App) CreateProfile(p *profile.Profile) (*profile.Profile, error) {
 	if a.store == nil {
@@ -412,15 +706,44 @@ func (a *App) CreateProfile(p *profile.Profile) (*profile.Profile, error) {
 	return a.store.Create(p)
 }
 
-// UpdateProfile updates an existing profile.
+// UpdateProfile updates an existing profile. If the profile is connected,
+// the change is applied to the running connection (see applyLiveUpdate).
 func (a *App) UpdateProfile(p *profile.Profile) (*profile.Profile, error) {
 	if a.store == nil {
 		return nil, fmt.Errorf("store not initialized")
 	}
-	return a.store.Update(p)
+	updated, err := a.store.Update(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.connState == StateConnected && a.activeOpts != nil && a.activeProfile != nil &&
+		(a.activeProfile.ID == updated.ID || a.store.InheritsFrom(a.activeProfile.ID, updated.ID)) {
+		// The active profile may inherit the change
+		active, err := a.store.Get(a.activeProfile.ID)
+		if err != nil {
+			return updated, err
+		}
+		update, err := a.applyLiveUpdate(active)
+		if err != nil {
+			a.lastError = err.Error()
+			return updated, fmt.Errorf("profile saved, but applying it to the running connection failed: %w", err)
+		}
+		wailsRuntime.EventsEmit(a.ctx, "profile:live-update", update)
+	}
+	return updated, nil
+}
+
+// ValidateProfile checks a profile without saving it and returns one error
+// per invalid field, so the frontend can highlight each of them.
+func (a *App) ValidateProfile(p *profile.Profile) []*validate.FieldError {
+	var errs validate.Errors
+	errs.Add(p.Validate())
+	return errs
 }
 
-// DeleteProfile removes a profile by ID.
+// DeleteProfile removes a profile by ID. It fails if other profiles inherit
+// from it; DeleteProfileAndFlatten deletes it anyway.
 func (a *App) DeleteProfile(id string) error {
 	if a.store == nil {
 		return fmt.Errorf("store not initialized")
@@ -428,7 +751,85 @@ func (a *App) DeleteProfile(id string) error {
 	return a.store.Delete(id)
 }
 
-// ImportURI imports a paqet:// URI and creates a profile.
+// DeleteProfileAndFlatten removes a profile by ID, copying the settings other
+// profiles inherit from it into them.
+func (a *App) DeleteProfileAndFlatten(id string) error {
+	if a.store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return a.store.DeleteAndFlatten(id)
+}
+
+// SearchProfiles returns the profiles matching a query, in the user's order.
+func (a *App) SearchProfiles(q *profile.Query) []*profile.Profile {
+	if a.store == nil {
+		return nil
+	}
+	return a.store.Search(q)
+}
+
+// ListGroups returns the profile groups in use.
+func (a *App) ListGroups() []string {
+	if a.store == nil {
+		return nil
+	}
+	return a.store.Groups()
+}
+
+// ListTags returns the profile tags in use.
+func (a *App) ListTags() []string {
+	if a.store == nil {
+		return nil
+	}
+	return a.store.Tags()
+}
+
+// SetFavorite marks or unmarks a profile as a favorite.
+func (a *App) SetFavorite(id string, favorite bool) error {
+	if a.store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return a.store.SetFavorite(id, favorite)
+}
+
+// MoveProfile moves a profile up (negative offset) or down the list.
+func (a *App) MoveProfile(id string, offset int) error {
+	if a.store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return a.store.Move(id, offset)
+}
+
+// ReorderProfiles puts the given profiles in the order of ids, for example
+// after a drag and drop. Profiles not listed keep their positions.
+func (a *App) ReorderProfiles(ids []string) error {
+	if a.store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return a.store.Reorder(ids)
+}
+
+// ProfileHistories returns the connection history of every profile that was
+// ever connected, by profile ID.
+func (a *App) ProfileHistories() map[string]*profile.History {
+	if a.store == nil {
+		return nil
+	}
+	return a.store.Histories()
+}
+
+// ListProfilesByReliability returns the profiles that connect most reliably
+// first, so users can see which servers actually work.
+func (a *App) ListProfilesByReliability() []*profile.Profile {
+	if a.store == nil {
+		return nil
+	}
+	return a.store.ListByReliability()
+}
+
+// ImportURI imports a paqet:// URI and creates a profile. Encrypted links
+// fail with uri.ErrPassphraseRequired; the frontend then asks for the
+// passphrase and calls ImportEncryptedURI.
 func (a *App) ImportURI(raw string) (*profile.Profile, error) {
 	if a.store == nil {
 		return nil, fmt.Errorf("store not initialized")
@@ -436,22 +837,379 @@ func (a *App) ImportURI(raw string) (*profile.Profile, error) {
 	return a.store.ImportFromURI(raw)
 }
 
-// ExportURI exports a profile as a paqet:// URI string.
-func (a *App) ExportURI(id string) (string, error) {
+// ImportEncryptedURI imports a passphrase-encrypted paqet:// link.
+func (a *App) ImportEncryptedURI(raw, passphrase string) (*profile.Profile, error) {
+	if a.store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return a.store.ImportFromEncryptedURI(raw, passphrase)
+}
+
+// ImportConfigFile reads a paqet client YAML file and creates a profile from it.
+// The profile is named after the file. Keys that could not be carried over
+// are returned so the frontend can show them.
+func (a *App) ImportConfigFile(path string) (*ConfigImport, error) {
+	if a.store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	res, err := config.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	p, err := a.store.ImportFromConfig(res.Options, name)
+	if err != nil {
+		return nil, err
+	}
+	return &ConfigImport{
+		Profile:     p,
+		Unknown:     res.Unknown,
+		Unsupported: res.Unsupported,
+	}, nil
+}
+
+// ExportURI exports a profile as a paqet:// URI string, optionally in the
+// compact binary form.
+func (a *App) ExportURI(id string, compact bool) (string, error) {
 	if a.store == nil {
 		return "", fmt.Errorf("store not initialized")
 	}
-	return a.store.ExportToURI(id)
+	return a.store.ExportToURI(id, compact)
 }
 
-// GenerateQRCode generates a QR code PNG for the given profile ID.
-func (a *App) GenerateQRCode(id string) ([]byte, error) {
-	raw, err := a.ExportURI(id)
+// ExportEncryptedURI exports a profile as a passphrase-encrypted paqet:// link,
+// which is safe to paste where the plain key should not appear.
+func (a *App) ExportEncryptedURI(id, passphrase string) (string, error) {
+	if a.store == nil {
+		return "", fmt.Errorf("store not initialized")
+	}
+	return a.store.ExportToEncryptedURI(id, passphrase)
+}
+
+// ExportSignedURI exports a profile as a paqet:// URI signed with the
+// admin's private key from GenerateSigningKey.
+func (a *App) ExportSignedURI(id, privateKey string, compact bool) (string, error) {
+	if a.store == nil {
+		return "", fmt.Errorf("store not initialized")
+	}
+	return a.store.ExportToSignedURI(id, privateKey, compact)
+}
+
+// GenerateQRCode renders a profile's share link as a QR code PNG. level is
+// the error-correction level ("L", "M", "Q" or "H"; empty means "M"). The
+// compact link form gives a smaller code that is easier to scan.
+func (a *App) GenerateQRCode(id, level string, compact bool) ([]byte, error) {
+	raw, err := a.ExportURI(id, compact)
 	if err != nil {
 		return nil, err
 	}
-	_ = raw
-	return nil, fmt.Errorf("not implemented - Phase 3")
+	return qr.Encode(raw, level, 0)
+}
+
+// ImportQRImage decodes a QR code from a PNG or JPEG file and imports the
+// link in it. An encrypted link needs the passphrase; without one this fails
+// with uri.ErrPassphraseRequired, like ImportURI.
+func (a *App) ImportQRImage(path, passphrase string) (*profile.Profile, error) {
+	if a.store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	raw, err := qr.DecodeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase != "" {
+		return a.store.ImportFromEncryptedURI(raw, passphrase)
+	}
+	return a.store.ImportFromURI(raw)
+}
+
+// SelectImageFile opens a native file dialog for choosing a QR code image.
+// It returns an empty path if the dialog was cancelled.
+func (a *App) SelectImageFile() (string, error) {
+	return wailsRuntime.OpenFileDialog(a.ctx, wailsRuntime.OpenDialogOptions{
+		Title: "Import QR Code",
+		Filters: []wailsRuntime.FileFilter{
+			{DisplayName: "Images (*.png, *.jpg, *.jpeg)", Pattern: "*.png;*.jpg;*.jpeg"},
+		},
+	})
+}
+
+// ExportProfiles writes the given profiles, or all of them if ids is empty,
+// to a bundle file for ImportProfiles on another machine. With a passphrase
+// the bundle is encrypted; without one it holds the keys in plain text.
+func (a *App) ExportProfiles(ids []string, path, passphrase string) error {
+	if a.store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	data, err := a.store.ExportBundle(ids, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// ImportProfiles imports a bundle from ExportProfiles, or a text file with
+// one paqet:// link per line. duplicates is "merge", "skip" or "duplicate"
+// and applies to profiles with the same host, port and key as an existing
+// one. An encrypted bundle without a passphrase fails with
+// profile.ErrPassphraseRequired; the frontend then asks and retries.
+func (a *App) ImportProfiles(path, passphrase, duplicates string) (*profile.ImportResult, error) {
+	if a.store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return a.store.ImportBundle(data, passphrase, profile.Duplicates(duplicates))
+}
+
+// SelectProfilesFile opens a native file dialog for choosing a bundle or
+// links file to import. It returns an empty path if the dialog was cancelled.
+func (a *App) SelectProfilesFile() (string, error) {
+	return wailsRuntime.OpenFileDialog(a.ctx, wailsRuntime.OpenDialogOptions{
+		Title: "Import Profiles",
+		Filters: []wailsRuntime.FileFilter{
+			{DisplayName: "Profiles (*.json, *.txt)", Pattern: "*.json;*.txt"},
+			{DisplayName: "All Files", Pattern: "*"},
+		},
+	})
+}
+
+// SaveProfilesFile opens a native save dialog for a bundle file. It returns
+// an empty path if the dialog was cancelled.
+func (a *App) SaveProfilesFile() (string, error) {
+	return wailsRuntime.SaveFileDialog(a.ctx, wailsRuntime.SaveDialogOptions{
+		Title:           "Export Profiles",
+		DefaultFilename: "autopaqet-profiles.json",
+		Filters: []wailsRuntime.FileFilter{
+			{DisplayName: "Profile bundle (*.json)", Pattern: "*.json"},
+		},
+	})
+}
+
+// --- Preset Methods ---
+
+// ListPresets returns the built-in tuning presets followed by user presets.
+func (a *App) ListPresets() []*profile.Preset {
+	if a.presets == nil {
+		return profile.BuiltInPresets()
+	}
+	return a.presets.List()
+}
+
+// SavePreset creates or updates a user preset.
+func (a *App) SavePreset(p *profile.Preset) (*profile.Preset, error) {
+	if a.presets == nil {
+		return nil, fmt.Errorf("preset store not initialized")
+	}
+	return a.presets.Save(p)
+}
+
+// SavePresetFromProfile saves a profile's current tuning as a new user preset.
+func (a *App) SavePresetFromProfile(profileID, name, description string) (*profile.Preset, error) {
+	if a.store == nil || a.presets == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	p, err := a.store.Get(profileID)
+	if err != nil {
+		return nil, err
+	}
+	return a.presets.Save(&profile.Preset{
+		Name:        name,
+		Description: description,
+		Tuning:      profile.TuningOf(p),
+	})
+}
+
+// DeletePreset removes a user preset.
+func (a *App) DeletePreset(id string) error {
+	if a.presets == nil {
+		return fmt.Errorf("preset store not initialized")
+	}
+	return a.presets.Delete(id)
+}
+
+// preset returns a built-in or user preset by ID. Only built-in presets are
+// available if the preset store failed to load.
+func (a *App) preset(id string) (*profile.Preset, error) {
+	if a.presets != nil {
+		return a.presets.Get(id)
+	}
+	for _, p := range profile.BuiltInPresets() {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("preset %q not found", id)
+}
+
+// ApplyPreset overwrites a profile's tuning settings with a preset and saves
+// it. Mode, conn and FEC shards are kept, so the profile still matches its
+// server and a running connection survives the restart.
+func (a *App) ApplyPreset(profileID, presetID string) (*profile.Profile, error) {
+	if a.store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	preset, err := a.preset(presetID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := a.store.Get(profileID)
+	if err != nil {
+		return nil, err
+	}
+	preset.Apply(p)
+	return a.UpdateProfile(p) // applies to a running connection too
+}
+
+// --- Subscription Methods ---
+
+// ListSubscriptions returns all subscriptions with their last sync status.
+func (a *App) ListSubscriptions() []*profile.Subscription {
+	if a.store == nil {
+		return nil
+	}
+	return a.store.ListSubscriptions()
+}
+
+// AddSubscription adds a subscription and syncs it right away. The
+// subscription is kept even if the first sync fails; its status records why.
+func (a *App) AddSubscription(sub *profile.Subscription) (*profile.Subscription, error) {
+	if a.store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	added, err := a.store.AddSubscription(sub)
+	if err != nil {
+		return nil, err
+	}
+	_, err = a.SyncSubscription(added.ID)
+	return added, err
+}
+
+// SyncSubscription fetches a subscription now and updates its profiles.
+func (a *App) SyncSubscription(id string) (*profile.SyncResult, error) {
+	if a.store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	res, err := a.store.SyncSubscription(a.ctx, id)
+	a.subscriptionSynced(res, err)
+	return res, err
+}
+
+// DeleteSubscription removes a subscription. Its profiles are removed too
+// unless keepProfiles is set.
+func (a *App) DeleteSubscription(id string, keepProfiles bool) error {
+	if a.store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return a.store.DeleteSubscription(id, keepProfiles)
+}
+
+// subscriptionSynced tells the frontend to reload profiles and subscription
+// status. The result is nil if the sync failed.
+func (a *App) subscriptionSynced(res *profile.SyncResult, err error) {
+	wailsRuntime.EventsEmit(a.ctx, "subscription:synced", res)
+}
+
+// profilesChanged tells the frontend to reload profiles after another
+// program changed profiles.json.
+func (a *App) profilesChanged(change *profile.Change, err error) {
+	ev := &ProfilesChanged{Change: change}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	wailsRuntime.EventsEmit(a.ctx, "profiles:changed", ev)
+}
+
+// --- Publisher Methods ---
+
+// ListPublishers returns the trusted publisher keys.
+func (a *App) ListPublishers() []*profile.Publisher {
+	if a.store == nil {
+		return nil
+	}
+	return a.store.ListPublishers()
+}
+
+// AddPublisher trusts a publisher's public key. Profiles signed with it
+// become verified.
+func (a *App) AddPublisher(name, publicKey string) (*profile.Publisher, error) {
+	if a.store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return a.store.AddPublisher(name, publicKey)
+}
+
+// RemovePublisher stops trusting a publisher's public key.
+func (a *App) RemovePublisher(publicKey string) error {
+	if a.store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return a.store.RemovePublisher(publicKey)
+}
+
+// --- Store Encryption Methods ---
+
+// IsStoreEncrypted reports whether profile secrets are encrypted at rest.
+func (a *App) IsStoreEncrypted() bool {
+	return a.store != nil && a.store.EncryptionEnabled()
+}
+
+// IsStoreLocked reports whether the master passphrase is needed before
+// profiles can be used. Locked profiles are listed without their secrets.
+func (a *App) IsStoreLocked() bool {
+	return a.store != nil && a.store.Locked()
+}
+
+// UnlockStore unlocks encrypted profiles for the rest of the session.
+func (a *App) UnlockStore(passphrase string) error {
+	if a.store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return a.store.Unlock(passphrase)
+}
+
+// LockStore locks encrypted profiles until UnlockStore is called again. A
+// running connection is not affected.
+func (a *App) LockStore() error {
+	if a.store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return a.store.Lock()
+}
+
+// EnableStoreEncryption encrypts profile secrets under a master passphrase.
+func (a *App) EnableStoreEncryption(passphrase string) error {
+	if a.store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return a.store.EnableEncryption(passphrase)
+}
+
+// DisableStoreEncryption stores profile secrets in plaintext again.
+func (a *App) DisableStoreEncryption(passphrase string) error {
+	if a.store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return a.store.DisableEncryption(passphrase)
+}
+
+// ChangeStorePassphrase re-encrypts profile secrets under a new master
+// passphrase.
+func (a *App) ChangeStorePassphrase(oldPassphrase, newPassphrase string) error {
+	if a.store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return a.store.ChangePassphrase(oldPassphrase, newPassphrase)
 }
 
 // --- Log Methods ---
@@ -482,54 +1240,177 @@ func (a *App) GenerateKey() (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
-// GenerateConfigYAML generates YAML config from a profile and network info.
-func (a *App) GenerateConfigYAML(profileID string, net *NetworkInfo) (string, error) {
+// GenerateSigningKey creates an Ed25519 key pair for signing share links.
+// The public key is handed to users to add as a trusted publisher; the
+// private key stays with the admin.
+func (a *App) GenerateSigningKey() (*SigningKey, error) {
+	pub, priv, err := uri.GenerateSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{PublicKey: pub, PrivateKey: priv}, nil
+}
+
+// OutboundFormats returns the formats ExportOutbound supports.
+func (a *App) OutboundFormats() []string {
+	return outbound.Formats()
+}
+
+// ExportOutbound renders a profile's local SOCKS5 listener as an outbound
+// config snippet for another proxy client (see OutboundFormats), so it can be
+// chained through paqet. An empty profileID means the connected profile,
+// which uses the settings paqet is running with.
+func (a *App) ExportOutbound(profileID, format string) (string, error) {
 	if a.store == nil {
 		return "", fmt.Errorf("store not initialized")
 	}
+	if a.connState == StateConnected && a.activeProfile != nil && a.activeOpts != nil &&
+		(profileID == "" || profileID == a.activeProfile.ID) {
+		return outbound.Export(format, &outbound.Socks{
+			Name:   a.activeProfile.Name,
+			Listen: a.activeOpts.SocksListen,
+			User:   a.activeOpts.SocksUser,
+			Pass:   a.activeOpts.SocksPass,
+		})
+	}
+	if profileID == "" {
+		return "", fmt.Errorf("not connected")
+	}
+
 	p, err := a.store.Get(profileID)
 	if err != nil {
 		return "", err
 	}
+	return outbound.Export(format, &outbound.Socks{
+		Name:   p.Name,
+		Listen: p.SocksListen,
+		User:   p.SocksUser,
+		Pass:   p.SocksPass,
+	})
+}
 
-	localAddr := fmt.Sprintf("%s:%d", net.LocalIP, 12345)
-
-	opts := &config.Options{
-		ServerAddr:    fmt.Sprintf("%s:%d", p.Host, p.Port),
-		Key:           p.Key,
-		InterfaceName: net.InterfaceName,
-		LocalAddr:     localAddr,
-		GatewayMAC:    net.GatewayMAC,
-		NpcapGUID:     net.NpcapGUID,
-		SocksListen:   p.SocksListen,
-		SocksUser:     p.SocksUser,
-		SocksPass:     p.SocksPass,
-		Mode:          p.Mode,
-		Conn:          p.Conn,
-		MTU:           p.MTU,
-		Block:         p.Block,
-		RcvWnd:        p.RcvWnd,
-		SndWnd:        p.SndWnd,
-		DShard:        p.DShard,
-		PShard:        p.PShard,
-		DSCP:          p.DSCP,
-		SmuxBuf:       p.SmuxBuf,
-		StreamBuf:     p.StreamBuf,
-		TCPBuf:        p.TCPBuf,
-		UDPBuf:        p.UDPBuf,
-		SockBuf:       p.SockBuf,
-		LocalFlag:     p.LocalFlag,
-		RemoteFlag:    p.RemoteFlag,
-		Forward:       p.Forward,
-		LogLevel:      p.LogLevel,
+// GenerateConfigYAML generates YAML config from a profile and network info.
+func (a *App) GenerateConfigYAML(profileID string, info *NetworkInfo) (string, error) {
+	if a.store == nil {
+		return "", fmt.Errorf("store not initialized")
+	}
+	p, err := a.store.Get(profileID)
+	if err != nil {
+		return "", err
 	}
 
+	localAddr := net.JoinHostPort(info.LocalIP, "12345")
+
+	opts := profileOptions(p)
+	opts.InterfaceName = info.InterfaceName
+	opts.LocalAddr = localAddr
+	opts.GatewayMAC = info.GatewayMAC
+	opts.NpcapGUID = info.NpcapGUID
+
 	return config.Generate(opts)
 }
 
-// ParseURI parses a paqet:// URI without saving it (for preview).
-func (a *App) ParseURI(raw string) (*uri.PaqetURI, error) {
-	return uri.Parse(raw)
+// ParseURI parses a paqet:// URI without saving it (for preview), along with
+// warnings about parameters that would be ignored or rewritten on import.
+func (a *App) ParseURI(raw string) (*uri.ParseResult, error) {
+	return uri.ParseWithWarnings(raw)
+}
+
+// CheckServerConfig compares a profile against a pasted paqet server YAML
+// and returns every setting that must match but does not. The server config
+// is remembered for the session and used in the next diagnostic report.
+func (a *App) CheckServerConfig(profileID, serverYAML string) ([]config.Mismatch, error) {
+	if a.store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	p, err := a.store.Get(profileID)
+	if err != nil {
+		return nil, err
+	}
+	res, err := config.ParseServer([]byte(serverYAML))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse server config: %w", err)
+	}
+
+	a.serverMu.Lock()
+	if a.serverConfigs == nil {
+		a.serverConfigs = make(map[string]*config.ServerOptions)
+	}
+	a.serverConfigs[profileID] = res.Options
+	a.serverMu.Unlock()
+
+	return config.CheckCompat(profileOptions(p), res.Options), nil
+}
+
+// CheckServerConfigFile is CheckServerConfig for a server YAML file on disk.
+func (a *App) CheckServerConfigFile(profileID, path string) ([]config.Mismatch, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server config: %w", err)
+	}
+	return a.CheckServerConfig(profileID, string(data))
+}
+
+// serverConfig returns the server config remembered for a profile, if any.
+func (a *App) serverConfig(profileID string) *config.ServerOptions {
+	a.serverMu.Lock()
+	defer a.serverMu.Unlock()
+	return a.serverConfigs[profileID]
+}
+
+// profileOptions maps a profile onto config options. Machine-specific
+// network fields are left for the caller to fill in.
+func profileOptions(p *profile.Profile) *config.Options {
+	return &config.Options{
+		ServerAddr:   net.JoinHostPort(p.Host, strconv.Itoa(p.Port)),
+		Key:          p.Key,
+		SocksListen:  p.SocksListen,
+		SocksUser:    p.SocksUser,
+		SocksPass:    p.SocksPass,
+		Mode:         p.Mode,
+		Conn:         p.Conn,
+		MTU:          p.MTU,
+		Block:        p.Block,
+		NoDelay:      p.NoDelay,
+		Interval:     p.Interval,
+		Resend:       p.Resend,
+		NoCongestion: p.NoCongestion,
+		WDelay:       p.WDelay,
+		AckNoDelay:   p.AckNoDelay,
+		RcvWnd:       p.RcvWnd,
+		SndWnd:       p.SndWnd,
+		DShard:       p.DShard,
+		PShard:       p.PShard,
+		DSCP:         p.DSCP,
+		SmuxBuf:      p.SmuxBuf,
+		StreamBuf:    p.StreamBuf,
+		TCPBuf:       p.TCPBuf,
+		UDPBuf:       p.UDPBuf,
+		SockBuf:      p.SockBuf,
+		LocalFlag:    p.LocalFlag,
+		RemoteFlag:   p.RemoteFlag,
+		Forward:      p.Forward,
+		LogLevel:     p.LogLevel,
+	}
+}
+
+// routeHost returns the address network detection should route towards.
+// Hostnames are resolved so that an IPv6-only server selects the IPv6 route;
+// IPv4 is preferred when both families are available.
+func routeHost(host string) string {
+	if host == "" || net.ParseIP(host) != nil {
+		return host
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return host
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip.String()
+		}
+	}
+	return ips[0].String()
 }
 
 // ProfileDir returns the platform-specific profile storage directory.
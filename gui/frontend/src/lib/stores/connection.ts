@@ -1,5 +1,6 @@
 import { writable } from 'svelte/store';
 import { EventsOn } from '../../../wailsjs/runtime/runtime';
+import { loadProfiles } from './profiles';
 
 export type ConnectionState = 'idle' | 'testing' | 'connected' | 'error';
 
@@ -9,3 +10,37 @@ export const lastError = writable<string>('');
 EventsOn('connection:state', (state: ConnectionState) => {
   connectionState.set(state);
 });
+
+export interface LiveUpdate {
+  profile_id: string;
+  restarted: boolean;
+  changed?: string[];
+  message: string;
+}
+
+export const lastLiveUpdate = writable<LiveUpdate | null>(null);
+
+EventsOn('profile:live-update', (update: LiveUpdate) => {
+  lastLiveUpdate.set(update);
+});
+
+export interface Failover {
+  profile_id: string;
+  failed: string;
+  next: string;
+  reason: string;
+}
+
+// Endpoint the current connection verified through (may be a fallback)
+export const activeEndpoint = writable<string>('');
+export const lastFailover = writable<Failover | null>(null);
+
+EventsOn('connection:failover', (f: Failover) => {
+  lastFailover.set(f);
+});
+
+// The store records the endpoint as the profile's last_endpoint; reload it.
+EventsOn('connection:endpoint', (endpoint: string) => {
+  activeEndpoint.set(endpoint);
+  loadProfiles();
+});
@@ -1,5 +1,12 @@
 import { writable, derived } from 'svelte/store';
 import { ListProfiles } from '../../../wailsjs/go/main/App';
+import { EventsOn } from '../../../wailsjs/runtime/runtime';
+
+export interface ForwardRule {
+  listen: string;
+  target: string;
+  protocol?: 'tcp' | 'udp';
+}
 
 export interface Profile {
   id: string;
@@ -7,6 +14,8 @@ export interface Profile {
   host: string;
   port: number;
   key: string;
+  fallbacks?: string[];
+  last_endpoint?: string;
   socks_listen?: string;
   socks_user?: string;
   socks_pass?: string;
@@ -14,6 +23,12 @@ export interface Profile {
   conn?: number;
   mtu?: number;
   block?: string;
+  nodelay?: number;
+  interval?: number;
+  resend?: number;
+  nocongestion?: number;
+  wdelay?: boolean;
+  acknodelay?: boolean;
   rcvwnd?: number;
   sndwnd?: number;
   dshard?: number;
@@ -26,9 +41,100 @@ export interface Profile {
   sockbuf?: number;
   local_flag?: string;
   remote_flag?: string;
-  forward?: string[];
+  forward?: ForwardRule[];
   log_level?: string;
   system_proxy?: boolean;
+  group?: string;
+  tags?: string[];
+  favorite?: boolean;
+  parent?: string;
+  own?: string[];
+  subscription_id?: string;
+  subscription_ref?: string;
+  overrides?: string[];
+  signature?: string;
+  publisher?: string;
+  verification?: 'verified' | 'unverified' | 'tampered';
+  secrets?: string;
+}
+
+export interface Attempt {
+  time: string;
+  success: boolean;
+  endpoint?: string;
+  summary?: string;
+  ready_ms?: number;
+}
+
+export interface History {
+  attempts: number;
+  successes: number;
+  failures: number;
+  last_connected?: string;
+  last_failure?: string;
+  ready_total_ms: number;
+  connected_secs: number;
+  recent?: Attempt[];
+  avg_ready_ms?: number;
+  reliability?: number;
+}
+
+export interface Query {
+  text: string;
+  group: string;
+  tags: string[];
+  favorites: boolean;
+}
+
+export type Duplicates = 'merge' | 'skip' | 'duplicate';
+
+export interface ImportResult {
+  added: Profile[];
+  merged: Profile[];
+  skipped: string[];
+  errors: string[];
+}
+
+export interface Conflict {
+  id: string;
+  name: string;
+  external?: Profile;
+}
+
+// How an unreadable profiles.json, or another store file, was recovered from.
+export interface Recovery {
+  file: string;
+  reason: string;
+  corrupt_path: string;
+  backup: string;
+  profiles: number;
+  external: boolean;
+}
+
+// Sent when another program changed profiles.json, or reloading it failed.
+export interface ProfilesChanged {
+  added?: string[];
+  updated?: string[];
+  removed?: string[];
+  conflicts?: Conflict[];
+  recovery?: Recovery;
+  error?: string;
+}
+
+export interface Publisher {
+  name: string;
+  public_key: string;
+}
+
+export interface Subscription {
+  id: string;
+  name: string;
+  url: string;
+  interval?: number;
+  last_sync: string;
+  last_error?: string;
+  last_count?: number;
+  last_status?: number;
 }
 
 export const profiles = writable<Profile[]>([]);
@@ -52,3 +158,13 @@ export async function loadProfiles() {
     console.error('Failed to load profiles:', e);
   }
 }
+
+// Subscription syncs may add, update or remove profiles in the background.
+EventsOn('subscription:synced', () => {
+  loadProfiles();
+});
+
+// So may other programs writing profiles.json.
+EventsOn('profiles:changed', () => {
+  loadProfiles();
+});
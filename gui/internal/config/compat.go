@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Mismatch describes a single setting on which a client and server disagree.
+type Mismatch struct {
+	Field   string `json:"field"`
+	Client  string `json:"client"`
+	Server  string `json:"server"`
+	Message string `json:"message"`
+}
+
+// CheckCompat compares client options against a server configuration and
+// returns every setting that must match but does not. Defaults are applied to
+// both sides before comparing. TCP flags are compared crosswise: the client's
+// remote flag must equal the server's local flag, and the client's local flag
+// must equal the server's remote flag when the server sets one.
+func CheckCompat(client *Options, server *ServerOptions) []Mismatch {
+	var mismatches []Mismatch
+
+	add := func(field, clientVal, serverVal, message string) {
+		mismatches = append(mismatches, Mismatch{
+			Field:   field,
+			Client:  clientVal,
+			Server:  serverVal,
+			Message: message,
+		})
+	}
+
+	if client.Key != server.Key {
+		// Never echo keys back; they end up in logs and reports.
+		add("key", "", "", "secret keys differ")
+	}
+
+	clientMode, serverMode := orDefault(client.Mode, "fast"), orDefault(server.Mode, "fast")
+	if clientMode != serverMode {
+		add("mode", clientMode, serverMode, fmt.Sprintf("KCP mode is %s on the client but %s on the server", clientMode, serverMode))
+	}
+
+	clientConn, serverConn := intOrDefault(client.Conn, 1), intOrDefault(server.Conn, 1)
+	if clientConn != serverConn {
+		add("conn", strconv.Itoa(clientConn), strconv.Itoa(serverConn),
+			fmt.Sprintf("connection count is %d on the client but %d on the server", clientConn, serverConn))
+	}
+
+	clientBlock, serverBlock := orDefault(client.Block, "aes"), orDefault(server.Block, "aes")
+	if clientBlock != serverBlock {
+		add("block", clientBlock, serverBlock, fmt.Sprintf("block cipher is %s on the client but %s on the server", clientBlock, serverBlock))
+	}
+
+	if client.DShard != server.DShard || client.PShard != server.PShard {
+		clientFEC := fmt.Sprintf("%d/%d", client.DShard, client.PShard)
+		serverFEC := fmt.Sprintf("%d/%d", server.DShard, server.PShard)
+		add("fec", clientFEC, serverFEC, fmt.Sprintf("FEC data/parity shards are %s on the client but %s on the server", clientFEC, serverFEC))
+	}
+
+	clientRemote, serverLocal := orDefault(client.RemoteFlag, "PA"), orDefault(server.LocalFlag, "PA")
+	if clientRemote != serverLocal {
+		add("remote_flag", clientRemote, serverLocal,
+			fmt.Sprintf("client remote_flag %s must equal server local_flag %s", clientRemote, serverLocal))
+	}
+
+	if server.RemoteFlag != "" {
+		clientLocal := orDefault(client.LocalFlag, "PA")
+		if clientLocal != server.RemoteFlag {
+			add("local_flag", clientLocal, server.RemoteFlag,
+				fmt.Sprintf("client local_flag %s must equal server remote_flag %s", clientLocal, server.RemoteFlag))
+		}
+	}
+
+	if server.Port != 0 {
+		if _, portStr, err := net.SplitHostPort(client.ServerAddr); err == nil && portStr != strconv.Itoa(server.Port) {
+			add("port", portStr, strconv.Itoa(server.Port),
+				fmt.Sprintf("client connects to port %s but the server listens on %d", portStr, server.Port))
+		}
+	}
+
+	return mismatches
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+func intOrDefault(v, def int) int {
+	if v == 0 {
+		return def
+	}
+	return v
+}
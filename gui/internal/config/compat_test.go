@@ -0,0 +1,165 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleServerYAML = `# AutoPaqet Server Configuration
+role: "server"
+
+log:
+  level: "info"
+
+listen:
+  addr: ":9999"
+
+network:
+  interface: "eth0"
+  ipv4:
+    addr: "10.0.0.5:9999"
+    router_mac: "aa:bb:cc:dd:ee:ff"
+  tcp:
+    local_flag: ["S"]
+
+transport:
+  protocol: "kcp"
+  conn: 2
+  kcp:
+    mode: "fast3"
+    key: "serverkey"
+    block: "aes"
+`
+
+func TestParseServerConfig(t *testing.T) {
+	res, err := ParseServer([]byte(sampleServerYAML))
+	if err != nil {
+		t.Fatalf("ParseServer failed: %v", err)
+	}
+	opts := res.Options
+	if opts.Port != 9999 {
+		t.Errorf("Port = %d, want 9999", opts.Port)
+	}
+	if opts.ServerIP != "10.0.0.5" {
+		t.Errorf("ServerIP = %q, want %q", opts.ServerIP, "10.0.0.5")
+	}
+	if opts.LocalFlag != "S" || opts.RemoteFlag != "" {
+		t.Errorf("flags = %q/%q, want S/empty", opts.LocalFlag, opts.RemoteFlag)
+	}
+	if opts.Mode != "fast3" || opts.Conn != 2 || opts.Key != "serverkey" {
+		t.Errorf("transport = %s/%d/%s", opts.Mode, opts.Conn, opts.Key)
+	}
+	if len(res.Unknown) != 0 || len(res.Unsupported) != 0 {
+		t.Errorf("unexpected diagnostics: unknown=%v unsupported=%v", res.Unknown, res.Unsupported)
+	}
+}
+
+func TestParseServerRoundTripsGenerateServer(t *testing.T) {
+	in := baseServerOptions()
+	in.Mode = "fast2"
+	in.Conn = 3
+	in.LocalFlag = "A"
+	in.RemoteFlag = "S"
+
+	out, err := GenerateServer(in)
+	if err != nil {
+		t.Fatalf("GenerateServer failed: %v", err)
+	}
+	res, err := ParseServer([]byte(out))
+	if err != nil {
+		t.Fatalf("ParseServer failed: %v", err)
+	}
+	if mismatches := CheckCompat(in.ClientOptions(""), res.Options); len(mismatches) != 0 {
+		t.Errorf("generated pair should be compatible, got %+v", mismatches)
+	}
+}
+
+func TestParseServerRejectsClientRole(t *testing.T) {
+	if _, err := ParseServer([]byte("role: client\n")); err == nil {
+		t.Error("expected role error")
+	}
+}
+
+func TestCheckCompatMatching(t *testing.T) {
+	res, err := ParseServer([]byte(sampleServerYAML))
+	if err != nil {
+		t.Fatalf("ParseServer failed: %v", err)
+	}
+	client := &Options{
+		ServerAddr: "203.0.113.7:9999",
+		Key:        "serverkey",
+		Mode:       "fast3",
+		Conn:       2,
+		RemoteFlag: "S",
+	}
+	if mismatches := CheckCompat(client, res.Options); len(mismatches) != 0 {
+		t.Errorf("expected no mismatches, got %+v", mismatches)
+	}
+}
+
+func TestCheckCompatReportsEveryMismatch(t *testing.T) {
+	server := &ServerOptions{
+		Port:       9999,
+		Key:        "serverkey",
+		Mode:       "fast3",
+		Conn:       2,
+		Block:      "salsa20",
+		DShard:     10,
+		PShard:     3,
+		LocalFlag:  "S",
+		RemoteFlag: "A",
+	}
+	client := &Options{
+		ServerAddr: "203.0.113.7:8443",
+		Key:        "clientkey",
+		LocalFlag:  "PA",
+		RemoteFlag: "PA",
+	}
+
+	got := make(map[string]Mismatch)
+	for _, m := range CheckCompat(client, server) {
+		got[m.Field] = m
+	}
+
+	want := map[string][2]string{
+		"key":         {"", ""},
+		"mode":        {"fast", "fast3"},
+		"conn":        {"1", "2"},
+		"block":       {"aes", "salsa20"},
+		"fec":         {"0/0", "10/3"},
+		"remote_flag": {"PA", "S"},
+		"local_flag":  {"PA", "A"},
+		"port":        {"8443", "9999"},
+	}
+	for field, vals := range want {
+		m, ok := got[field]
+		if !ok {
+			t.Errorf("missing mismatch for %s", field)
+			continue
+		}
+		if m.Client != vals[0] || m.Server != vals[1] {
+			t.Errorf("%s: client=%q server=%q, want %q/%q", field, m.Client, m.Server, vals[0], vals[1])
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d mismatches, want %d: %+v", len(got), len(want), got)
+	}
+	if strings.Contains(got["key"].Message, "serverkey") || strings.Contains(got["key"].Message, "clientkey") {
+		t.Error("key mismatch message must not reveal keys")
+	}
+}
+
+func TestCheckCompatInvertedFlags(t *testing.T) {
+	server := &ServerOptions{Key: "k", LocalFlag: "S", RemoteFlag: "A"}
+
+	// Same flags on both sides is wrong when the server uses different local/remote flags.
+	same := &Options{Key: "k", LocalFlag: "S", RemoteFlag: "A"}
+	if len(CheckCompat(same, server)) != 2 {
+		t.Errorf("expected both flags to mismatch, got %+v", CheckCompat(same, server))
+	}
+
+	inverted := &Options{Key: "k", LocalFlag: "A", RemoteFlag: "S"}
+	if mismatches := CheckCompat(inverted, server); len(mismatches) != 0 {
+		t.Errorf("expected inverted flags to match, got %+v", mismatches)
+	}
+}
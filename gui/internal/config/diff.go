@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Diff compares two YAML configurations and returns the dotted key paths
+// whose values differ, sorted. List elements are addressed as key[i]. Only
+// paths are returned, never values, since configs contain secrets.
+func Diff(oldYAML, newYAML string) ([]string, error) {
+	var oldCfg, newCfg interface{}
+	if err := yaml.Unmarshal([]byte(oldYAML), &oldCfg); err != nil {
+		return nil, fmt.Errorf("invalid old config: %w", err)
+	}
+	if err := yaml.Unmarshal([]byte(newYAML), &newCfg); err != nil {
+		return nil, fmt.Errorf("invalid new config: %w", err)
+	}
+
+	var changed []string
+	diffValues("", oldCfg, newCfg, &changed)
+	sort.Strings(changed)
+	return changed, nil
+}
+
+func diffValues(path string, a, b interface{}, changed *[]string) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for k := range av {
+			keys[k] = true
+		}
+		for k := range bv {
+			keys[k] = true
+		}
+		for k := range keys {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			diffValues(child, av[k], bv[k], changed)
+		}
+		return
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		n := len(av)
+		if len(bv) > n {
+			n = len(bv)
+		}
+		for i := 0; i < n; i++ {
+			var ai, bi interface{}
+			if i < len(av) {
+				ai = av[i]
+			}
+			if i < len(bv) {
+				bi = bv[i]
+			}
+			diffValues(fmt.Sprintf("%s[%d]", path, i), ai, bi, changed)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*changed = append(*changed, path)
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func diffBaseOptions() *Options {
+	return &Options{
+		ServerAddr:    "1.2.3.4:8080",
+		Key:           "mysecret",
+		InterfaceName: "eth0",
+		LocalAddr:     "192.168.1.100:12345",
+		GatewayMAC:    "aa:bb:cc:dd:ee:ff",
+		SocksUser:     "user",
+		SocksPass:     "pass",
+	}
+}
+
+func TestDiffGeneratedConfigs(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *Options)
+		want   []string
+	}{
+		{"identical", func(o *Options) {}, nil},
+		{"socks credentials", func(o *Options) { o.SocksUser = "other"; o.SocksPass = "secret2" },
+			[]string{"socks5[0].password", "socks5[0].username"}},
+		{"kcp mode and conn", func(o *Options) { o.Mode = "fast3"; o.Conn = 2 },
+			[]string{"transport.conn", "transport.kcp.mode"}},
+		{"added mtu", func(o *Options) { o.MTU = 1400 }, []string{"transport.kcp.mtu"}},
+		{"added forward", func(o *Options) {
+			o.Forward = []ForwardRule{{Listen: "127.0.0.1:8080", Target: "internal:80"}}
+		}, []string{"forward"}},
+		{"flag", func(o *Options) { o.RemoteFlag = "S" }, []string{"network.tcp.remote_flag[0]"}},
+	}
+
+	oldYAML, err := Generate(diffBaseOptions())
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := diffBaseOptions()
+			tc.modify(opts)
+			newYAML, err := Generate(opts)
+			if err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+			got, err := Diff(oldYAML, newYAML)
+			if err != nil {
+				t.Fatalf("Diff failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Diff = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDiffListLengthChange(t *testing.T) {
+	got, err := Diff("a: [1, 2]\n", "a: [1, 2, 3]\nb: {c: 1}\n")
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	want := []string{"a[2]", "b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff = %v, want %v", got, want)
+	}
+}
+
+func TestDiffInvalidYAML(t *testing.T) {
+	if _, err := Diff("a: [", "a: 1"); err == nil {
+		t.Error("expected error for invalid old config")
+	}
+	if _, err := Diff("a: 1", "a: ["); err == nil {
+		t.Error("expected error for invalid new config")
+	}
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/omid3098/autopaqet/gui/internal/validate"
+)
+
+// ForwardRule forwards a local listen address through the tunnel to a target
+// reachable from the server.
+type ForwardRule struct {
+	Listen   string `json:"listen" yaml:"listen"`                         // host:port on the client
+	Target   string `json:"target" yaml:"target"`                         // host:port as seen by the server
+	Protocol string `json:"protocol,omitempty" yaml:"protocol,omitempty"` // tcp (default) or udp
+}
+
+// ParseForwardRule parses the text form of a rule, "proto:listen=target",
+// for example "tcp:127.0.0.1:8080=internal:80". The protocol prefix is
+// optional. The older "proto:port:host:port" form is also accepted and
+// listens on 127.0.0.1.
+func ParseForwardRule(s string) (ForwardRule, error) {
+	var r ForwardRule
+	rest := strings.TrimSpace(s)
+	if proto, tail, ok := strings.Cut(rest, ":"); ok && (proto == "tcp" || proto == "udp") {
+		r.Protocol = proto
+		rest = tail
+	}
+
+	if listen, target, ok := strings.Cut(rest, "="); ok {
+		r.Listen, r.Target = listen, target
+	} else {
+		parts := strings.Split(rest, ":")
+		if len(parts) != 3 {
+			return ForwardRule{}, fmt.Errorf("invalid forward rule %q, expected proto:listen=target", s)
+		}
+		r.Listen = net.JoinHostPort("127.0.0.1", parts[0])
+		r.Target = net.JoinHostPort(parts[1], parts[2])
+	}
+
+	if err := r.Validate(""); err != nil {
+		return ForwardRule{}, err
+	}
+	return r, nil
+}
+
+// String returns the text form accepted by ParseForwardRule.
+func (r ForwardRule) String() string {
+	return r.protocol() + ":" + r.Listen + "=" + r.Target
+}
+
+// Validate checks the rule. Field names in errors are prefixed with field,
+// e.g. "forward[0].listen".
+func (r ForwardRule) Validate(field string) error {
+	prefix := ""
+	if field != "" {
+		prefix = field + "."
+	}
+	var errs validate.Errors
+	if r.Listen == "" {
+		errs.Add(&validate.FieldError{Field: prefix + "listen", Message: "listen address is required"})
+	} else {
+		errs.Add(validate.ListenAddr(prefix+"listen", r.Listen))
+	}
+	errs.Add(validate.HostPort(prefix+"target", r.Target))
+	errs.Add(validate.Protocol(prefix+"protocol", r.Protocol))
+	return errs.Err()
+}
+
+// UnmarshalJSON accepts either the object form or a text rule, so profiles
+// saved before rules were typed still load.
+func (r *ForwardRule) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		rule, err := ParseForwardRule(s)
+		if err != nil {
+			return err
+		}
+		*r = rule
+		return nil
+	}
+
+	type plain ForwardRule
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+func (r ForwardRule) protocol() string {
+	if r.Protocol == "" {
+		return "tcp"
+	}
+	return r.Protocol
+}
+
+// ValidateForward checks a list of rules, naming each by its index under field.
+// Two rules may not share a listen address and protocol.
+func ValidateForward(field string, rules []ForwardRule) error {
+	var errs validate.Errors
+	seen := make(map[string]bool)
+	for i, r := range rules {
+		name := fmt.Sprintf("%s[%d]", field, i)
+		errs.Add(r.Validate(name))
+		key := r.protocol() + " " + r.Listen
+		if seen[key] {
+			errs.Add(&validate.FieldError{Field: name + ".listen", Value: r.Listen, Message: fmt.Sprintf("%s %s is already forwarded", r.protocol(), r.Listen)})
+		}
+		seen[key] = true
+	}
+	return errs.Err()
+}
+
+// FormatForward joins rules into a comma-separated list of text rules.
+func FormatForward(rules []ForwardRule) string {
+	parts := make([]string, len(rules))
+	for i, r := range rules {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseForward parses a comma-separated list of text rules.
+func ParseForward(s string) ([]ForwardRule, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var rules []ForwardRule
+	for _, part := range strings.Split(s, ",") {
+		r, err := ParseForwardRule(part)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/omid3098/autopaqet/gui/internal/validate"
+)
+
+func TestParseForwardRule(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ForwardRule
+		wantErr bool
+	}{
+		{"tcp:127.0.0.1:8080=internal:80", ForwardRule{Listen: "127.0.0.1:8080", Target: "internal:80", Protocol: "tcp"}, false},
+		{"udp:[::1]:5353=10.0.0.53:53", ForwardRule{Listen: "[::1]:5353", Target: "10.0.0.53:53", Protocol: "udp"}, false},
+		{"127.0.0.1:8080=internal:80", ForwardRule{Listen: "127.0.0.1:8080", Target: "internal:80"}, false},
+		{"tcp:8080:internal:80", ForwardRule{Listen: "127.0.0.1:8080", Target: "internal:80", Protocol: "tcp"}, false},
+		{"8080:internal:80", ForwardRule{Listen: "127.0.0.1:8080", Target: "internal:80"}, false},
+		{"tcp:8080", ForwardRule{}, true},
+		{"tcp:127.0.0.1:8080=internal", ForwardRule{}, true},
+		{"tcp:example.com:8080=internal:80", ForwardRule{}, true},
+		{"tcp:70000:internal:80", ForwardRule{}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseForwardRule(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestForwardRuleStringRoundTrip(t *testing.T) {
+	rules := []ForwardRule{
+		{Listen: "127.0.0.1:8080", Target: "internal:80", Protocol: "tcp"},
+		{Listen: "[::1]:5353", Target: "[2001:db8::53]:53", Protocol: "udp"},
+	}
+	s := FormatForward(rules)
+	if s != "tcp:127.0.0.1:8080=internal:80,udp:[::1]:5353=[2001:db8::53]:53" {
+		t.Errorf("FormatForward = %q", s)
+	}
+	got, err := ParseForward(s)
+	if err != nil {
+		t.Fatalf("ParseForward failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, rules) {
+		t.Errorf("round trip = %+v, want %+v", got, rules)
+	}
+
+	if got, err := ParseForward(""); err != nil || got != nil {
+		t.Errorf("ParseForward(\"\") = %v, %v", got, err)
+	}
+}
+
+func TestValidateForward(t *testing.T) {
+	rules := []ForwardRule{
+		{Listen: "127.0.0.1:8080", Target: "internal:80"},
+		{Listen: "127.0.0.1:8080", Target: "other:80", Protocol: "tcp"},
+		{Listen: "127.0.0.1:8080", Target: "other:80", Protocol: "udp"},
+		{Target: "x:1", Protocol: "icmp"},
+	}
+	err := ValidateForward("forward", rules)
+	var errs validate.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validate.Errors, got %v", err)
+	}
+
+	fields := make([]string, len(errs))
+	for i, fe := range errs {
+		fields[i] = fe.Field
+	}
+	want := []string{"forward[1].listen", "forward[3].listen", "forward[3].protocol"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %v, want %v", fields, want)
+	}
+}
+
+func TestForwardRuleUnmarshalJSON(t *testing.T) {
+	data := `["tcp:8080:internal:80", {"listen": "127.0.0.1:5353", "target": "10.0.0.53:53", "protocol": "udp"}]`
+	var rules []ForwardRule
+	if err := json.Unmarshal([]byte(data), &rules); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want := []ForwardRule{
+		{Listen: "127.0.0.1:8080", Target: "internal:80", Protocol: "tcp"},
+		{Listen: "127.0.0.1:5353", Target: "10.0.0.53:53", Protocol: "udp"},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("rules = %+v, want %+v", rules, want)
+	}
+
+	if err := json.Unmarshal([]byte(`["nonsense"]`), &rules); err == nil {
+		t.Error("expected error for invalid text rule")
+	}
+}
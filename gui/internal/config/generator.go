@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"net"
 
+	"github.com/omid3098/autopaqet/gui/internal/validate"
 	"gopkg.in/yaml.v3"
 )
 
@@ -12,7 +14,7 @@ type Options struct {
 	ServerAddr    string
 	Key           string
 	InterfaceName string
-	LocalAddr     string // ip:port
+	LocalAddr     string // ip:port, [ipv6]:port selects the ipv6 section
 	GatewayMAC    string
 
 	// Windows only
@@ -29,6 +31,14 @@ type Options struct {
 	MTU   int
 	Block string // default aes
 
+	// Manual KCP tuning, only emitted when Mode is manual
+	NoDelay      int // 0 or 1
+	Interval     int // ms, default paqet's
+	Resend       int // fast resend threshold, 0 disables
+	NoCongestion int // 0 or 1
+	WDelay       bool
+	AckNoDelay   bool
+
 	// KCP windows
 	RcvWnd int
 	SndWnd int
@@ -52,7 +62,7 @@ type Options struct {
 	RemoteFlag string // default PA
 
 	// Forwarding rules
-	Forward []string
+	Forward []ForwardRule
 
 	// Logging
 	LogLevel string // default none
@@ -75,24 +85,15 @@ func Generate(opts *Options) (string, error) {
 	if opts.GatewayMAC == "" {
 		return "", fmt.Errorf("gateway MAC is required")
 	}
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
 
 	// Apply defaults
 	socksListen := opts.SocksListen
 	if socksListen == "" {
 		socksListen = "127.0.0.1:1080"
 	}
-	mode := opts.Mode
-	if mode == "" {
-		mode = "fast"
-	}
-	conn := opts.Conn
-	if conn == 0 {
-		conn = 1
-	}
-	block := opts.Block
-	if block == "" {
-		block = "aes"
-	}
 	localFlag := opts.LocalFlag
 	if localFlag == "" {
 		localFlag = "PA"
@@ -129,7 +130,7 @@ func Generate(opts *Options) (string, error) {
 	// Network
 	network := map[string]interface{}{
 		"interface": opts.InterfaceName,
-		"ipv4": map[string]interface{}{
+		addrFamily(opts.LocalAddr): map[string]interface{}{
 			"addr":       opts.LocalAddr,
 			"router_mac": opts.GatewayMAC,
 		},
@@ -149,11 +150,101 @@ func Generate(opts *Options) (string, error) {
 	}
 
 	// Transport
+	cfg["transport"] = transportSection(opts)
+
+	// Forward rules
+	if len(opts.Forward) > 0 {
+		cfg["forward"] = opts.Forward
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// Validate checks option values against paqet's accepted values. Errors are
+// reported per YAML key path.
+func (opts *Options) Validate() error {
+	var errs validate.Errors
+	errs.Add(validate.ListenAddr("socks5.listen", opts.SocksListen))
+	errs.Add(validate.Flag("network.tcp.local_flag", opts.LocalFlag))
+	errs.Add(validate.Flag("network.tcp.remote_flag", opts.RemoteFlag))
+	errs.Add(opts.validateTransport())
+	errs.Add(ValidateForward("forward", opts.Forward))
+	return errs.Err()
+}
+
+// validateTransport checks the transport settings shared by client and
+// server configs.
+func (opts *Options) validateTransport() error {
+	var errs validate.Errors
+	errs.Add(validate.Conn("transport.conn", opts.Conn))
+	errs.Add(validate.Mode("transport.kcp.mode", opts.Mode))
+	errs.Add(validate.Block("transport.kcp.block", opts.Block))
+	errs.Add(validate.MTU("transport.kcp.mtu", opts.MTU))
+	errs.Add(validate.Toggle("transport.kcp.nodelay", opts.NoDelay))
+	errs.Add(validate.Interval("transport.kcp.interval", opts.Interval))
+	errs.Add(validate.NonNegative("transport.kcp.resend", opts.Resend))
+	errs.Add(validate.Toggle("transport.kcp.nocongestion", opts.NoCongestion))
+	errs.Add(validate.NonNegative("transport.kcp.rcvwnd", opts.RcvWnd))
+	errs.Add(validate.NonNegative("transport.kcp.sndwnd", opts.SndWnd))
+	errs.Add(validate.Shards("transport.kcp.datashard", opts.DShard, "transport.kcp.parityshard", opts.PShard))
+	errs.Add(validate.DSCP("transport.kcp.dscp", opts.DSCP))
+	errs.Add(validate.NonNegative("transport.smuxbuf", opts.SmuxBuf))
+	errs.Add(validate.NonNegative("transport.streambuf", opts.StreamBuf))
+	errs.Add(validate.NonNegative("transport.tcpbuf", opts.TCPBuf))
+	errs.Add(validate.NonNegative("transport.udpbuf", opts.UDPBuf))
+	errs.Add(validate.NonNegative("transport.sockbuf", opts.SockBuf))
+	return errs.Err()
+}
+
+// addrFamily returns the network section ("ipv4" or "ipv6") that a host:port
+// address belongs in.
+func addrFamily(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+// transportSection builds the transport block shared by client and server
+// configs, so both sides always serialize KCP settings the same way.
+func transportSection(opts *Options) map[string]interface{} {
+	mode := opts.Mode
+	if mode == "" {
+		mode = "fast"
+	}
+	conn := opts.Conn
+	if conn == 0 {
+		conn = 1
+	}
+	block := opts.Block
+	if block == "" {
+		block = "aes"
+	}
+
 	kcpSection := map[string]interface{}{
 		"mode":  mode,
 		"key":   opts.Key,
 		"block": block,
 	}
+	if mode == "manual" {
+		kcpSection["nodelay"] = opts.NoDelay
+		if opts.Interval != 0 {
+			kcpSection["interval"] = opts.Interval
+		}
+		kcpSection["resend"] = opts.Resend
+		kcpSection["nocongestion"] = opts.NoCongestion
+		kcpSection["wdelay"] = opts.WDelay
+		kcpSection["acknodelay"] = opts.AckNoDelay
+	}
 	if opts.MTU != 0 {
 		kcpSection["mtu"] = opts.MTU
 	}
@@ -193,17 +284,5 @@ func Generate(opts *Options) (string, error) {
 	if opts.SockBuf != 0 {
 		transport["sockbuf"] = opts.SockBuf
 	}
-	cfg["transport"] = transport
-
-	// Forward rules
-	if len(opts.Forward) > 0 {
-		cfg["forward"] = opts.Forward
-	}
-
-	data, err := yaml.Marshal(cfg)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal config: %w", err)
-	}
-
-	return string(data), nil
+	return transport
 }
@@ -364,6 +364,99 @@ func TestGenerateWithDSCP(t *testing.T) {
 	}
 }
 
+func TestGenerateIPv6Network(t *testing.T) {
+	opts := &Options{
+		ServerAddr:    "[2001:db8::1]:8080",
+		Key:           "mysecret",
+		InterfaceName: "eth0",
+		LocalAddr:     "[2001:db8::100]:12345",
+		GatewayMAC:    "aa:bb:cc:dd:ee:ff",
+	}
+
+	out, err := Generate(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var parsed map[string]interface{}
+	if err := yaml.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("generated config is not valid YAML: %v", err)
+	}
+
+	network := parsed["network"].(map[string]interface{})
+	if _, ok := network["ipv4"]; ok {
+		t.Error("IPv6 local address should not produce an ipv4 section")
+	}
+	ipv6, ok := network["ipv6"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("missing ipv6 section:\n%s", out)
+	}
+	if ipv6["addr"] != "[2001:db8::100]:12345" || ipv6["router_mac"] != "aa:bb:cc:dd:ee:ff" {
+		t.Errorf("ipv6 = %v", ipv6)
+	}
+	if parsed["server"].(map[string]interface{})["addr"] != "[2001:db8::1]:8080" {
+		t.Errorf("server.addr = %v", parsed["server"])
+	}
+}
+
+func TestGenerateManualKCPParams(t *testing.T) {
+	opts := &Options{
+		ServerAddr:    "1.2.3.4:8080",
+		Key:           "mysecret",
+		InterfaceName: "eth0",
+		LocalAddr:     "192.168.1.100:12345",
+		GatewayMAC:    "aa:bb:cc:dd:ee:ff",
+		Mode:          "manual",
+		NoDelay:       1,
+		Interval:      20,
+		Resend:        2,
+		NoCongestion:  1,
+		AckNoDelay:    true,
+	}
+
+	out, err := Generate(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var parsed map[string]interface{}
+	if err := yaml.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("generated config is not valid YAML: %v", err)
+	}
+
+	kcp := parsed["transport"].(map[string]interface{})["kcp"].(map[string]interface{})
+	if toInt(kcp["nodelay"]) != 1 || toInt(kcp["interval"]) != 20 || toInt(kcp["resend"]) != 2 || toInt(kcp["nocongestion"]) != 1 {
+		t.Errorf("manual params = %v", kcp)
+	}
+	if kcp["wdelay"] != false || kcp["acknodelay"] != true {
+		t.Errorf("wdelay/acknodelay = %v/%v, want false/true", kcp["wdelay"], kcp["acknodelay"])
+	}
+}
+
+func TestGenerateOmitsManualParamsForPresetModes(t *testing.T) {
+	opts := &Options{
+		ServerAddr:    "1.2.3.4:8080",
+		Key:           "mysecret",
+		InterfaceName: "eth0",
+		LocalAddr:     "192.168.1.100:12345",
+		GatewayMAC:    "aa:bb:cc:dd:ee:ff",
+		Mode:          "fast2",
+		NoDelay:       1,
+		Interval:      20,
+		AckNoDelay:    true,
+	}
+
+	out, err := Generate(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, key := range []string{"nodelay", "interval", "resend", "nocongestion", "wdelay", "acknodelay"} {
+		if strings.Contains(out, key+":") {
+			t.Errorf("%s should only be emitted in manual mode:\n%s", key, out)
+		}
+	}
+}
+
 func TestGenerateWithForwardRules(t *testing.T) {
 	opts := &Options{
 		ServerAddr:    "1.2.3.4:8080",
@@ -371,7 +464,10 @@ func TestGenerateWithForwardRules(t *testing.T) {
 		InterfaceName: "eth0",
 		LocalAddr:     "192.168.1.100:12345",
 		GatewayMAC:    "aa:bb:cc:dd:ee:ff",
-		Forward:       []string{"tcp:8080:internal:80", "udp:9090:internal:9090"},
+		Forward: []ForwardRule{
+			{Listen: "127.0.0.1:8080", Target: "internal:80"},
+			{Listen: "127.0.0.1:9090", Target: "internal:9090", Protocol: "udp"},
+		},
 	}
 
 	out, err := Generate(opts)
@@ -389,7 +485,28 @@ func TestGenerateWithForwardRules(t *testing.T) {
 		t.Fatal("missing forward section")
 	}
 	if len(fwd) != 2 {
-		t.Errorf("forward has %d entries, want 2", len(fwd))
+		t.Fatalf("forward has %d entries, want 2", len(fwd))
+	}
+	second := fwd[1].(map[string]interface{})
+	if second["listen"] != "127.0.0.1:9090" || second["target"] != "internal:9090" || second["protocol"] != "udp" {
+		t.Errorf("forward[1] = %v", second)
+	}
+	if _, ok := fwd[0].(map[string]interface{})["protocol"]; ok {
+		t.Error("default protocol should be omitted")
+	}
+}
+
+func TestGenerateRejectsInvalidForwardRule(t *testing.T) {
+	opts := &Options{
+		ServerAddr:    "1.2.3.4:8080",
+		Key:           "mysecret",
+		InterfaceName: "eth0",
+		LocalAddr:     "192.168.1.100:12345",
+		GatewayMAC:    "aa:bb:cc:dd:ee:ff",
+		Forward:       []ForwardRule{{Listen: "127.0.0.1:8080", Target: "internal", Protocol: "sctp"}},
+	}
+	if _, err := Generate(opts); err == nil {
+		t.Error("expected error for invalid forward rule")
 	}
 }
 
@@ -441,6 +558,41 @@ func TestGenerateMissingRequiredFields(t *testing.T) {
 	}
 }
 
+func TestGenerateRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *Options)
+	}{
+		{"bad mode", func(o *Options) { o.Mode = "turbo" }},
+		{"bad block", func(o *Options) { o.Block = "rot13" }},
+		{"conn too high", func(o *Options) { o.Conn = 300 }},
+		{"bad local flag", func(o *Options) { o.LocalFlag = "SYN" }},
+		{"bad remote flag", func(o *Options) { o.RemoteFlag = "x" }},
+		{"mtu too large", func(o *Options) { o.MTU = 9000 }},
+		{"too many shards", func(o *Options) { o.DShard = 200; o.PShard = 100 }},
+		{"bad socks listen", func(o *Options) { o.SocksListen = "1080" }},
+		{"bad nodelay", func(o *Options) { o.Mode = "manual"; o.NoDelay = 2 }},
+		{"interval too low", func(o *Options) { o.Mode = "manual"; o.Interval = 1 }},
+		{"negative resend", func(o *Options) { o.Mode = "manual"; o.Resend = -1 }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := &Options{
+				ServerAddr:    "1.2.3.4:8080",
+				Key:           "mysecret",
+				InterfaceName: "eth0",
+				LocalAddr:     "192.168.1.100:12345",
+				GatewayMAC:    "aa:bb:cc:dd:ee:ff",
+			}
+			tc.modify(opts)
+			if _, err := Generate(opts); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+}
+
 // helper to convert yaml-parsed numbers to int
 func toInt(v interface{}) int {
 	switch n := v.(type) {
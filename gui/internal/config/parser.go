@@ -0,0 +1,532 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseResult holds a parsed paqet client configuration along with any keys
+// that could not be carried over into Options.
+type ParseResult struct {
+	Options *Options
+
+	// Unknown lists dotted key paths that are not part of the paqet config format.
+	Unknown []string
+	// Unsupported lists dotted key paths that paqet understands but Options
+	// cannot represent (extra SOCKS5 listeners, additional flags, etc).
+	Unsupported []string
+}
+
+// ServerParseResult holds a parsed paqet server configuration along with any
+// keys that could not be carried over into ServerOptions.
+type ServerParseResult struct {
+	Options     *ServerOptions
+	Unknown     []string
+	Unsupported []string
+}
+
+// unsupportedKeys are paqet client keys that are recognized but not mapped.
+var unsupportedKeys = map[string]bool{
+	"transport.kcp.smuxver":       true,
+	"transport.kcp.keepalive":     true,
+	"transport.kcp.keepalive_ttl": true,
+}
+
+// Parse reads a paqet client YAML configuration into Options.
+// Machine-specific fields (interface, local address, router MAC, Npcap GUID)
+// are populated as found; callers decide whether to keep them.
+func Parse(data []byte) (*ParseResult, error) {
+	p, err := parseRole(data, "client")
+	if err != nil {
+		return nil, err
+	}
+	return &ParseResult{
+		Options:     p.opts,
+		Unknown:     p.unknownKeys,
+		Unsupported: p.unsupportedKeys,
+	}, nil
+}
+
+// ParseServer reads a paqet server YAML configuration into ServerOptions.
+// The port is taken from listen.addr, falling back to the network.ipv4 (or
+// ipv6) address.
+func ParseServer(data []byte) (*ServerParseResult, error) {
+	p, err := parseRole(data, "server")
+	if err != nil {
+		return nil, err
+	}
+
+	opts := p.opts
+	server := &ServerOptions{
+		Key:           opts.Key,
+		InterfaceName: opts.InterfaceName,
+		GatewayMAC:    opts.GatewayMAC,
+		Mode:          opts.Mode,
+		Conn:          opts.Conn,
+		MTU:           opts.MTU,
+		Block:         opts.Block,
+		NoDelay:       opts.NoDelay,
+		Interval:      opts.Interval,
+		Resend:        opts.Resend,
+		NoCongestion:  opts.NoCongestion,
+		WDelay:        opts.WDelay,
+		AckNoDelay:    opts.AckNoDelay,
+		RcvWnd:        opts.RcvWnd,
+		SndWnd:        opts.SndWnd,
+		DShard:        opts.DShard,
+		PShard:        opts.PShard,
+		DSCP:          opts.DSCP,
+		SmuxBuf:       opts.SmuxBuf,
+		StreamBuf:     opts.StreamBuf,
+		TCPBuf:        opts.TCPBuf,
+		UDPBuf:        opts.UDPBuf,
+		SockBuf:       opts.SockBuf,
+		LocalFlag:     opts.LocalFlag,
+		RemoteFlag:    opts.RemoteFlag,
+		LogLevel:      opts.LogLevel,
+	}
+
+	portStr := ""
+	if p.listenAddr != "" {
+		_, portStr, err = net.SplitHostPort(p.listenAddr)
+		if err != nil {
+			return nil, fmt.Errorf("listen.addr: invalid address %q", p.listenAddr)
+		}
+	}
+	if opts.LocalAddr != "" {
+		host, port, err := net.SplitHostPort(opts.LocalAddr)
+		if err != nil {
+			return nil, fmt.Errorf("network.%s.addr: invalid address %q", addrFamily(opts.LocalAddr), opts.LocalAddr)
+		}
+		server.ServerIP = host
+		if portStr == "" {
+			portStr = port
+		}
+	}
+	if portStr != "" {
+		server.Port, err = strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("listen.addr: invalid port %q", portStr)
+		}
+	}
+
+	return &ServerParseResult{
+		Options:     server,
+		Unknown:     p.unknownKeys,
+		Unsupported: p.unsupportedKeys,
+	}, nil
+}
+
+// parseRole walks a client or server config. Sections that only apply to the
+// other role are reported as unsupported.
+func parseRole(data []byte, role string) (*parser, error) {
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("config is empty")
+	}
+
+	if r := scalar(raw["role"]); r != "" && r != role {
+		return nil, fmt.Errorf("unsupported role %q, expected %q", r, role)
+	}
+
+	p := &parser{opts: &Options{}}
+	isServer := role == "server"
+	for _, key := range sortedKeys(raw) {
+		val := raw[key]
+		switch key {
+		case "role":
+		case "log":
+			p.walk(key, val, func(path string, v interface{}) bool {
+				switch path {
+				case "log.level":
+					p.opts.LogLevel = scalar(v)
+				default:
+					return false
+				}
+				return true
+			})
+		case "socks5":
+			if isServer {
+				p.unsupported(key)
+				continue
+			}
+			p.parseSocks(key, val)
+		case "network":
+			p.parseNetwork(key, val)
+		case "server":
+			if isServer {
+				p.unsupported(key)
+				continue
+			}
+			p.walk(key, val, func(path string, v interface{}) bool {
+				switch path {
+				case "server.addr":
+					p.opts.ServerAddr = scalar(v)
+				default:
+					return false
+				}
+				return true
+			})
+		case "transport":
+			p.parseTransport(key, val)
+		case "forward":
+			if isServer {
+				p.unsupported(key)
+				continue
+			}
+			p.parseForward(key, val)
+		case "listen":
+			if !isServer {
+				p.unsupported(key)
+				continue
+			}
+			p.walk(key, val, func(path string, v interface{}) bool {
+				switch path {
+				case "listen.addr":
+					p.listenAddr = scalar(v)
+				default:
+					return false
+				}
+				return true
+			})
+		default:
+			p.unknown(key)
+		}
+	}
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	sort.Strings(p.unknownKeys)
+	sort.Strings(p.unsupportedKeys)
+	return p, nil
+}
+
+// parser accumulates Options and key diagnostics while walking a config tree.
+type parser struct {
+	opts            *Options
+	listenAddr      string // server only
+	unknownKeys     []string
+	unsupportedKeys []string
+	err             error
+}
+
+// walk visits every key of a mapping, calling fn with its dotted path.
+// Keys fn does not handle are reported as unknown or unsupported.
+func (p *parser) walk(path string, v interface{}, fn func(path string, v interface{}) bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		p.fail(fmt.Errorf("%s: expected a mapping", path))
+		return
+	}
+	for _, key := range sortedKeys(m) {
+		child := path + "." + key
+		if !fn(child, m[key]) {
+			p.unknown(child)
+		}
+	}
+}
+
+func (p *parser) parseSocks(path string, v interface{}) {
+	list, ok := v.([]interface{})
+	if !ok {
+		p.fail(fmt.Errorf("%s: expected a list", path))
+		return
+	}
+	for i, entry := range list {
+		entryPath := fmt.Sprintf("%s[%d]", path, i)
+		if i > 0 {
+			p.unsupported(entryPath)
+			continue
+		}
+		p.walk(entryPath, entry, func(child string, v interface{}) bool {
+			switch child {
+			case entryPath + ".listen":
+				p.opts.SocksListen = scalar(v)
+			case entryPath + ".username":
+				p.opts.SocksUser = scalar(v)
+			case entryPath + ".password":
+				p.opts.SocksPass = scalar(v)
+			default:
+				return false
+			}
+			return true
+		})
+	}
+}
+
+func (p *parser) parseNetwork(path string, v interface{}) {
+	// Both families may be present; Options holds one, so prefer IPv4 and
+	// report the other as unsupported once the whole section is read.
+	var ipv4, ipv6 struct{ addr, mac string }
+	hasIPv4, hasIPv6 := false, false
+	family := func(child string, v interface{}, dst *struct{ addr, mac string }) {
+		p.walk(child, v, func(key string, v interface{}) bool {
+			switch key {
+			case child + ".addr":
+				dst.addr = scalar(v)
+			case child + ".router_mac":
+				dst.mac = scalar(v)
+			default:
+				return false
+			}
+			return true
+		})
+	}
+
+	p.walk(path, v, func(child string, v interface{}) bool {
+		switch child {
+		case "network.interface":
+			p.opts.InterfaceName = scalar(v)
+		case "network.guid":
+			p.opts.NpcapGUID = scalar(v)
+		case "network.ipv4":
+			hasIPv4 = true
+			family(child, v, &ipv4)
+		case "network.ipv6":
+			hasIPv6 = true
+			family(child, v, &ipv6)
+		case "network.tcp":
+			p.walk(child, v, func(child string, v interface{}) bool {
+				switch child {
+				case "network.tcp.local_flag":
+					p.opts.LocalFlag = p.flag(child, v)
+				case "network.tcp.remote_flag":
+					p.opts.RemoteFlag = p.flag(child, v)
+				default:
+					return false
+				}
+				return true
+			})
+		default:
+			return p.known(child)
+		}
+		return true
+	})
+
+	switch {
+	case hasIPv4:
+		p.opts.LocalAddr, p.opts.GatewayMAC = ipv4.addr, ipv4.mac
+		if hasIPv6 {
+			p.unsupported("network.ipv6")
+		}
+	case hasIPv6:
+		p.opts.LocalAddr, p.opts.GatewayMAC = ipv6.addr, ipv6.mac
+	}
+}
+
+func (p *parser) parseTransport(path string, v interface{}) {
+	p.walk(path, v, func(child string, v interface{}) bool {
+		switch child {
+		case "transport.protocol":
+			if proto := scalar(v); proto != "kcp" {
+				p.unsupported(child)
+			}
+		case "transport.conn":
+			p.opts.Conn = p.integer(child, v)
+		case "transport.smuxbuf":
+			p.opts.SmuxBuf = p.integer(child, v)
+		case "transport.streambuf":
+			p.opts.StreamBuf = p.integer(child, v)
+		case "transport.tcpbuf":
+			p.opts.TCPBuf = p.integer(child, v)
+		case "transport.udpbuf":
+			p.opts.UDPBuf = p.integer(child, v)
+		case "transport.sockbuf":
+			p.opts.SockBuf = p.integer(child, v)
+		case "transport.kcp":
+			p.parseKCP(child, v)
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+func (p *parser) parseKCP(path string, v interface{}) {
+	p.walk(path, v, func(child string, v interface{}) bool {
+		switch child {
+		case "transport.kcp.mode":
+			p.opts.Mode = scalar(v)
+		case "transport.kcp.key":
+			p.opts.Key = scalar(v)
+		case "transport.kcp.block":
+			p.opts.Block = scalar(v)
+		case "transport.kcp.nodelay":
+			p.opts.NoDelay = p.integer(child, v)
+		case "transport.kcp.interval":
+			p.opts.Interval = p.integer(child, v)
+		case "transport.kcp.resend":
+			p.opts.Resend = p.integer(child, v)
+		case "transport.kcp.nocongestion":
+			p.opts.NoCongestion = p.integer(child, v)
+		case "transport.kcp.wdelay":
+			p.opts.WDelay = p.boolean(child, v)
+		case "transport.kcp.acknodelay":
+			p.opts.AckNoDelay = p.boolean(child, v)
+		case "transport.kcp.mtu":
+			p.opts.MTU = p.integer(child, v)
+		case "transport.kcp.rcvwnd":
+			p.opts.RcvWnd = p.integer(child, v)
+		case "transport.kcp.sndwnd":
+			p.opts.SndWnd = p.integer(child, v)
+		case "transport.kcp.datashard":
+			p.opts.DShard = p.integer(child, v)
+		case "transport.kcp.parityshard":
+			p.opts.PShard = p.integer(child, v)
+		case "transport.kcp.dscp":
+			p.opts.DSCP = p.integer(child, v)
+		default:
+			return p.known(child)
+		}
+		return true
+	})
+}
+
+// parseForward reads forward rules in either the mapping form or the
+// "proto:listen=target" text form.
+func (p *parser) parseForward(path string, v interface{}) {
+	list, ok := v.([]interface{})
+	if !ok {
+		p.fail(fmt.Errorf("%s: expected a list", path))
+		return
+	}
+	for i, entry := range list {
+		entryPath := fmt.Sprintf("%s[%d]", path, i)
+		if s, ok := entry.(string); ok {
+			rule, err := ParseForwardRule(s)
+			if err != nil {
+				p.fail(fmt.Errorf("%s: %w", entryPath, err))
+				continue
+			}
+			p.opts.Forward = append(p.opts.Forward, rule)
+			continue
+		}
+		if _, ok := entry.(map[string]interface{}); !ok {
+			p.unsupported(entryPath)
+			continue
+		}
+		var rule ForwardRule
+		p.walk(entryPath, entry, func(child string, v interface{}) bool {
+			switch child {
+			case entryPath + ".listen":
+				rule.Listen = scalar(v)
+			case entryPath + ".target":
+				rule.Target = scalar(v)
+			case entryPath + ".protocol":
+				rule.Protocol = scalar(v)
+			default:
+				return false
+			}
+			return true
+		})
+		if err := rule.Validate(entryPath); err != nil {
+			p.fail(err)
+			continue
+		}
+		p.opts.Forward = append(p.opts.Forward, rule)
+	}
+}
+
+// flag reads a TCP flag list. Only the first flag is kept; the rest are
+// reported as unsupported.
+func (p *parser) flag(path string, v interface{}) string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return scalar(v)
+	}
+	if len(list) > 1 {
+		p.unsupported(path)
+	}
+	if len(list) == 0 {
+		return ""
+	}
+	return scalar(list[0])
+}
+
+func (p *parser) integer(path string, v interface{}) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case string:
+		i, err := strconv.Atoi(n)
+		if err == nil {
+			return i
+		}
+	}
+	p.fail(fmt.Errorf("%s: expected an integer, got %v", path, v))
+	return 0
+}
+
+func (p *parser) boolean(path string, v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case int:
+		if b == 0 || b == 1 {
+			return b == 1
+		}
+	case string:
+		if parsed, err := strconv.ParseBool(b); err == nil {
+			return parsed
+		}
+	}
+	p.fail(fmt.Errorf("%s: expected a boolean, got %v", path, v))
+	return false
+}
+
+// known records path as unsupported if paqet defines it, and reports
+// whether it did.
+func (p *parser) known(path string) bool {
+	if unsupportedKeys[path] {
+		p.unsupported(path)
+		return true
+	}
+	return false
+}
+
+func (p *parser) unknown(path string) {
+	p.unknownKeys = append(p.unknownKeys, path)
+}
+
+func (p *parser) unsupported(path string) {
+	p.unsupportedKeys = append(p.unsupportedKeys, path)
+}
+
+// fail records err unless an earlier one was recorded. Keys are walked in
+// sorted order, so the error reported for a config is always the same.
+func (p *parser) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scalar returns the string form of a YAML scalar value.
+func scalar(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	default:
+		return fmt.Sprint(s)
+	}
+}
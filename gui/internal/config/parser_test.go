@@ -0,0 +1,304 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleClientYAML = `role: "client"
+
+log:
+  level: "info"
+
+socks5:
+  - listen: "127.0.0.1:1080"
+    username: "user1"
+    password: "pass1"
+
+network:
+  interface: "Ethernet"
+  guid: "\\Device\\NPF_{1234}"
+  ipv4:
+    addr: "192.168.1.100:12345"
+    router_mac: "aa:bb:cc:dd:ee:ff"
+  tcp:
+    local_flag: ["S"]
+    remote_flag: ["A"]
+
+server:
+  addr: "10.0.0.1:9999"
+
+transport:
+  protocol: "kcp"
+  conn: 2
+  smuxbuf: 4194304
+  kcp:
+    mode: "fast3"
+    key: "secretkey"
+    block: "salsa20"
+    mtu: 1400
+    rcvwnd: 1024
+    sndwnd: 512
+    datashard: 10
+    parityshard: 3
+    dscp: 46
+`
+
+func TestParseClientConfig(t *testing.T) {
+	res, err := Parse([]byte(sampleClientYAML))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	want := &Options{
+		ServerAddr:    "10.0.0.1:9999",
+		Key:           "secretkey",
+		InterfaceName: "Ethernet",
+		LocalAddr:     "192.168.1.100:12345",
+		GatewayMAC:    "aa:bb:cc:dd:ee:ff",
+		NpcapGUID:     `\Device\NPF_{1234}`,
+		SocksListen:   "127.0.0.1:1080",
+		SocksUser:     "user1",
+		SocksPass:     "pass1",
+		Mode:          "fast3",
+		Conn:          2,
+		MTU:           1400,
+		Block:         "salsa20",
+		RcvWnd:        1024,
+		SndWnd:        512,
+		DShard:        10,
+		PShard:        3,
+		DSCP:          46,
+		SmuxBuf:       4194304,
+		LocalFlag:     "S",
+		RemoteFlag:    "A",
+		LogLevel:      "info",
+	}
+	if !reflect.DeepEqual(res.Options, want) {
+		t.Errorf("Options = %+v\nwant %+v", res.Options, want)
+	}
+	if len(res.Unknown) != 0 {
+		t.Errorf("Unknown = %v, want none", res.Unknown)
+	}
+	if len(res.Unsupported) != 0 {
+		t.Errorf("Unsupported = %v, want none", res.Unsupported)
+	}
+}
+
+func TestParseRoundTripsGenerate(t *testing.T) {
+	opts := &Options{
+		ServerAddr:    "1.2.3.4:8080",
+		Key:           "mysecret",
+		InterfaceName: "eth0",
+		LocalAddr:     "192.168.1.100:12345",
+		GatewayMAC:    "aa:bb:cc:dd:ee:ff",
+		SocksListen:   "127.0.0.1:1081",
+		Mode:          "normal",
+		Conn:          3,
+		Block:         "aes",
+		TCPBuf:        8192,
+		LocalFlag:     "PA",
+		RemoteFlag:    "S",
+		Forward: []ForwardRule{
+			{Listen: "127.0.0.1:8080", Target: "internal:80"},
+			{Listen: "127.0.0.1:5353", Target: "10.0.0.53:53", Protocol: "udp"},
+		},
+		LogLevel: "debug",
+	}
+
+	out, err := Generate(opts)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	res, err := Parse([]byte(out))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !reflect.DeepEqual(res.Options, opts) {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", res.Options, opts)
+	}
+}
+
+func TestParseReportsUnknownAndUnsupportedKeys(t *testing.T) {
+	data := `role: client
+socks5:
+  - listen: "127.0.0.1:1080"
+    extra: true
+  - listen: "127.0.0.1:1081"
+network:
+  interface: eth0
+  tcp:
+    local_flag: ["PA", "S"]
+server:
+  addr: "1.2.3.4:9999"
+transport:
+  conn: 1
+  kcp:
+    key: k
+    smuxver: 2
+mystery: 42
+`
+	res, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	wantUnknown := []string{"mystery", "socks5[0].extra"}
+	if !reflect.DeepEqual(res.Unknown, wantUnknown) {
+		t.Errorf("Unknown = %v, want %v", res.Unknown, wantUnknown)
+	}
+	wantUnsupported := []string{"network.tcp.local_flag", "socks5[1]", "transport.kcp.smuxver"}
+	if !reflect.DeepEqual(res.Unsupported, wantUnsupported) {
+		t.Errorf("Unsupported = %v, want %v", res.Unsupported, wantUnsupported)
+	}
+	if res.Options.LocalFlag != "PA" {
+		t.Errorf("LocalFlag = %q, want first flag %q", res.Options.LocalFlag, "PA")
+	}
+}
+
+func TestParseIPv6Network(t *testing.T) {
+	data := `network:
+  interface: eth0
+  ipv6:
+    addr: "[2001:db8::100]:12345"
+    router_mac: "aa:bb:cc:dd:ee:ff"
+`
+	res, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if res.Options.LocalAddr != "[2001:db8::100]:12345" || res.Options.GatewayMAC != "aa:bb:cc:dd:ee:ff" {
+		t.Errorf("LocalAddr/GatewayMAC = %q/%q", res.Options.LocalAddr, res.Options.GatewayMAC)
+	}
+	if len(res.Unsupported) != 0 {
+		t.Errorf("Unsupported = %v, want none", res.Unsupported)
+	}
+
+	// With both families present, IPv4 wins and IPv6 is reported.
+	data += `  ipv4:
+    addr: "192.168.1.100:12345"
+    router_mac: "11:22:33:44:55:66"
+`
+	res, err = Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if res.Options.LocalAddr != "192.168.1.100:12345" || res.Options.GatewayMAC != "11:22:33:44:55:66" {
+		t.Errorf("LocalAddr/GatewayMAC = %q/%q, want the ipv4 values", res.Options.LocalAddr, res.Options.GatewayMAC)
+	}
+	if !reflect.DeepEqual(res.Unsupported, []string{"network.ipv6"}) {
+		t.Errorf("Unsupported = %v, want [network.ipv6]", res.Unsupported)
+	}
+}
+
+func TestParseManualKCPRoundTrip(t *testing.T) {
+	opts := &Options{
+		ServerAddr:    "1.2.3.4:8080",
+		Key:           "mysecret",
+		InterfaceName: "eth0",
+		LocalAddr:     "192.168.1.100:12345",
+		GatewayMAC:    "aa:bb:cc:dd:ee:ff",
+		SocksListen:   "127.0.0.1:1080",
+		Mode:          "manual",
+		NoDelay:       1,
+		Interval:      10,
+		Resend:        2,
+		NoCongestion:  1,
+		WDelay:        true,
+		AckNoDelay:    true,
+		LocalFlag:     "PA",
+		RemoteFlag:    "PA",
+		LogLevel:      "none",
+	}
+
+	out, err := Generate(opts)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	res, err := Parse([]byte(out))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	// Generate fills in the transport defaults.
+	opts.Conn, opts.Block = 1, "aes"
+	if !reflect.DeepEqual(res.Options, opts) {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", res.Options, opts)
+	}
+	if len(res.Unsupported) != 0 {
+		t.Errorf("Unsupported = %v, want none", res.Unsupported)
+	}
+}
+
+func TestParseForwardForms(t *testing.T) {
+	data := `forward:
+  - listen: "127.0.0.1:8080"
+    target: "internal:80"
+  - "udp:5353:10.0.0.53:53"
+  - listen: "127.0.0.1:9000"
+    target: "internal:9000"
+    weight: 2
+  - [1, 2]
+`
+	res, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []ForwardRule{
+		{Listen: "127.0.0.1:8080", Target: "internal:80"},
+		{Listen: "127.0.0.1:5353", Target: "10.0.0.53:53", Protocol: "udp"},
+		{Listen: "127.0.0.1:9000", Target: "internal:9000"},
+	}
+	if !reflect.DeepEqual(res.Options.Forward, want) {
+		t.Errorf("Forward = %+v, want %+v", res.Options.Forward, want)
+	}
+	if !reflect.DeepEqual(res.Unknown, []string{"forward[2].weight"}) {
+		t.Errorf("Unknown = %v", res.Unknown)
+	}
+	if !reflect.DeepEqual(res.Unsupported, []string{"forward[3]"}) {
+		t.Errorf("Unsupported = %v", res.Unsupported)
+	}
+}
+
+func TestParseRejectsServerRole(t *testing.T) {
+	_, err := Parse([]byte("role: server\nlisten:\n  addr: \":9999\"\n"))
+	if err == nil || !strings.Contains(err.Error(), "role") {
+		t.Errorf("expected role error, got %v", err)
+	}
+}
+
+func TestParseInvalidInput(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"not yaml", "role: [client"},
+		{"bad conn", "transport:\n  conn: two\n"},
+		{"network not a map", "network: eth0\n"},
+		{"socks5 not a list", "socks5:\n  listen: x\n"},
+		{"bad wdelay", "transport:\n  kcp:\n    wdelay: maybe\n"},
+		{"bad forward rule", "forward:\n  - \"tcp:8080\"\n"},
+		{"forward rule without target", "forward:\n  - listen: \"127.0.0.1:8080\"\n"},
+		{"bad forward listen", "forward:\n  - listen: \"nowhere\"\n    target: \"internal:80\"\n"},
+		{"bad forward protocol", "forward:\n  - listen: \"127.0.0.1:8080\"\n    target: \"internal:80\"\n    protocol: sctp\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tc.data)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestParseReportsFirstErrorInKeyOrder(t *testing.T) {
+	data := "transport:\n  kcp:\n    wdelay: maybe\n  conn: two\nnetwork: eth0\n"
+	for i := 0; i < 20; i++ {
+		_, err := Parse([]byte(data))
+		if err == nil || !strings.HasPrefix(err.Error(), "network:") {
+			t.Fatalf("error = %v, want the network error every time", err)
+		}
+	}
+}
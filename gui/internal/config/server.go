@@ -0,0 +1,182 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/omid3098/autopaqet/gui/internal/validate"
+	"gopkg.in/yaml.v3"
+)
+
+// ServerOptions holds all fields needed to generate a paqet server configuration.
+type ServerOptions struct {
+	// Required
+	Port          int
+	Key           string
+	InterfaceName string
+	ServerIP      string // local IP paqet binds on the server, IPv4 or IPv6
+	GatewayMAC    string
+
+	// KCP
+	Mode  string // default fast
+	Conn  int    // default 1
+	MTU   int
+	Block string // default aes
+
+	// Manual KCP tuning, only emitted when Mode is manual
+	NoDelay      int
+	Interval     int
+	Resend       int
+	NoCongestion int
+	WDelay       bool
+	AckNoDelay   bool
+
+	// KCP windows
+	RcvWnd int
+	SndWnd int
+
+	// FEC
+	DShard int
+	PShard int
+
+	// DSCP
+	DSCP int
+
+	// Buffers
+	SmuxBuf   int
+	StreamBuf int
+	TCPBuf    int
+	UDPBuf    int
+	SockBuf   int
+
+	// TCP flags, from the server's point of view
+	LocalFlag  string // default PA
+	RemoteFlag string // default PA
+
+	// Logging
+	LogLevel string // default info
+}
+
+// GenerateServer produces a YAML configuration string matching the paqet server format.
+func GenerateServer(opts *ServerOptions) (string, error) {
+	if opts.Port == 0 {
+		return "", fmt.Errorf("port is required")
+	}
+	if opts.Key == "" {
+		return "", fmt.Errorf("key is required")
+	}
+	if opts.InterfaceName == "" {
+		return "", fmt.Errorf("interface name is required")
+	}
+	if opts.ServerIP == "" {
+		return "", fmt.Errorf("server IP is required")
+	}
+	if opts.GatewayMAC == "" {
+		return "", fmt.Errorf("gateway MAC is required")
+	}
+	var errs validate.Errors
+	errs.Add(validate.Port("listen.addr", opts.Port))
+	errs.Add(validate.Flag("network.tcp.local_flag", opts.LocalFlag))
+	errs.Add(validate.Flag("network.tcp.remote_flag", opts.RemoteFlag))
+	errs.Add(opts.ClientOptions("").validateTransport())
+	if err := errs.Err(); err != nil {
+		return "", err
+	}
+
+	// Apply defaults
+	localFlag := opts.LocalFlag
+	if localFlag == "" {
+		localFlag = "PA"
+	}
+	remoteFlag := opts.RemoteFlag
+	if remoteFlag == "" {
+		remoteFlag = "PA"
+	}
+	logLevel := opts.LogLevel
+	if logLevel == "" {
+		logLevel = "info"
+	}
+	port := strconv.Itoa(opts.Port)
+
+	// Build config structure
+	cfg := map[string]interface{}{
+		"role": "server",
+		"log": map[string]interface{}{
+			"level": logLevel,
+		},
+		"listen": map[string]interface{}{
+			"addr": ":" + port,
+		},
+	}
+
+	// Network
+	cfg["network"] = map[string]interface{}{
+		"interface": opts.InterfaceName,
+		addrFamily(opts.ServerIP): map[string]interface{}{
+			"addr":       net.JoinHostPort(opts.ServerIP, port),
+			"router_mac": opts.GatewayMAC,
+		},
+		"tcp": map[string]interface{}{
+			"local_flag":  []string{localFlag},
+			"remote_flag": []string{remoteFlag},
+		},
+	}
+
+	// Transport is built from the matching client options so that both
+	// sides serialize mode, conn, block, key and FEC identically.
+	cfg["transport"] = transportSection(opts.ClientOptions(""))
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// ClientOptions returns the client Options that pair with this server.
+// host is the address clients use to reach the server; if empty, ServerIP is used.
+// Machine-specific fields (interface, local address, gateway MAC) are left
+// empty for the client side to fill in. The TCP flags are mirrored: the
+// client's local flag is the server's remote flag and vice versa.
+func (o *ServerOptions) ClientOptions(host string) *Options {
+	if host == "" {
+		host = o.ServerIP
+	}
+	localFlag := o.RemoteFlag
+	if localFlag == "" {
+		localFlag = "PA"
+	}
+	remoteFlag := o.LocalFlag
+	if remoteFlag == "" {
+		remoteFlag = "PA"
+	}
+
+	return &Options{
+		ServerAddr:   net.JoinHostPort(host, strconv.Itoa(o.Port)),
+		Key:          o.Key,
+		Mode:         o.Mode,
+		Conn:         o.Conn,
+		MTU:          o.MTU,
+		Block:        o.Block,
+		NoDelay:      o.NoDelay,
+		Interval:     o.Interval,
+		Resend:       o.Resend,
+		NoCongestion: o.NoCongestion,
+		WDelay:       o.WDelay,
+		AckNoDelay:   o.AckNoDelay,
+		RcvWnd:       o.RcvWnd,
+		SndWnd:       o.SndWnd,
+		DShard:       o.DShard,
+		PShard:       o.PShard,
+		DSCP:         o.DSCP,
+		SmuxBuf:      o.SmuxBuf,
+		StreamBuf:    o.StreamBuf,
+		TCPBuf:       o.TCPBuf,
+		UDPBuf:       o.UDPBuf,
+		SockBuf:      o.SockBuf,
+		LocalFlag:    localFlag,
+		RemoteFlag:   remoteFlag,
+	}
+}
@@ -0,0 +1,272 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/omid3098/autopaqet/gui/internal/validate"
+	"gopkg.in/yaml.v3"
+)
+
+func baseServerOptions() *ServerOptions {
+	return &ServerOptions{
+		Port:          9999,
+		Key:           "mysecret",
+		InterfaceName: "eth0",
+		ServerIP:      "10.0.0.5",
+		GatewayMAC:    "aa:bb:cc:dd:ee:ff",
+	}
+}
+
+func TestGenerateServerMinimalConfig(t *testing.T) {
+	out, err := GenerateServer(baseServerOptions())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var parsed map[string]interface{}
+	if err := yaml.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("generated config is not valid YAML: %v", err)
+	}
+
+	if parsed["role"] != "server" {
+		t.Errorf("role = %v, want %q", parsed["role"], "server")
+	}
+
+	listen := parsed["listen"].(map[string]interface{})
+	if listen["addr"] != ":9999" {
+		t.Errorf("listen.addr = %v, want %q", listen["addr"], ":9999")
+	}
+
+	network := parsed["network"].(map[string]interface{})
+	if network["interface"] != "eth0" {
+		t.Errorf("network.interface = %v, want %q", network["interface"], "eth0")
+	}
+	ipv4 := network["ipv4"].(map[string]interface{})
+	if ipv4["addr"] != "10.0.0.5:9999" {
+		t.Errorf("network.ipv4.addr = %v, want %q", ipv4["addr"], "10.0.0.5:9999")
+	}
+	if ipv4["router_mac"] != "aa:bb:cc:dd:ee:ff" {
+		t.Errorf("network.ipv4.router_mac = %v, want %q", ipv4["router_mac"], "aa:bb:cc:dd:ee:ff")
+	}
+
+	log := parsed["log"].(map[string]interface{})
+	if log["level"] != "info" {
+		t.Errorf("log.level = %v, want %q", log["level"], "info")
+	}
+
+	transport := parsed["transport"].(map[string]interface{})
+	if toInt(transport["conn"]) != 1 {
+		t.Errorf("conn = %v, want 1", transport["conn"])
+	}
+	kcp := transport["kcp"].(map[string]interface{})
+	if kcp["mode"] != "fast" {
+		t.Errorf("mode = %v, want %q", kcp["mode"], "fast")
+	}
+	if kcp["key"] != "mysecret" {
+		t.Errorf("key = %v, want %q", kcp["key"], "mysecret")
+	}
+	if kcp["block"] != "aes" {
+		t.Errorf("block = %v, want %q", kcp["block"], "aes")
+	}
+
+	if _, ok := parsed["server"]; ok {
+		t.Error("server config should not contain a server section")
+	}
+	if _, ok := parsed["socks5"]; ok {
+		t.Error("server config should not contain a socks5 section")
+	}
+}
+
+func TestGenerateServerFlags(t *testing.T) {
+	opts := baseServerOptions()
+	opts.LocalFlag = "S"
+	opts.RemoteFlag = "A"
+
+	out, err := GenerateServer(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var parsed map[string]interface{}
+	if err := yaml.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("generated config is not valid YAML: %v", err)
+	}
+
+	tcp := parsed["network"].(map[string]interface{})["tcp"].(map[string]interface{})
+	lf := tcp["local_flag"].([]interface{})
+	if len(lf) != 1 || lf[0] != "S" {
+		t.Errorf("local_flag = %v, want [S]", lf)
+	}
+	rf := tcp["remote_flag"].([]interface{})
+	if len(rf) != 1 || rf[0] != "A" {
+		t.Errorf("remote_flag = %v, want [A]", rf)
+	}
+}
+
+func TestGenerateServerReportsAllInvalidFields(t *testing.T) {
+	opts := baseServerOptions()
+	opts.LocalFlag = "X"
+	opts.Mode = "turbo"
+	opts.MTU = 10
+
+	_, err := GenerateServer(opts)
+	var errs validate.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validate.Errors, got %v", err)
+	}
+	fields := make([]string, len(errs))
+	for i, fe := range errs {
+		fields[i] = fe.Field
+	}
+	want := []string{"network.tcp.local_flag", "transport.kcp.mode", "transport.kcp.mtu"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %v, want %v", fields, want)
+	}
+}
+
+func TestGenerateServerMissingRequiredFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *ServerOptions)
+	}{
+		{"missing port", func(o *ServerOptions) { o.Port = 0 }},
+		{"missing key", func(o *ServerOptions) { o.Key = "" }},
+		{"missing interface", func(o *ServerOptions) { o.InterfaceName = "" }},
+		{"missing server ip", func(o *ServerOptions) { o.ServerIP = "" }},
+		{"missing gateway mac", func(o *ServerOptions) { o.GatewayMAC = "" }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := baseServerOptions()
+			tc.modify(opts)
+			if _, err := GenerateServer(opts); err == nil {
+				t.Error("expected error for missing required field")
+			}
+		})
+	}
+}
+
+func TestServerClientOptionsMirrorFlags(t *testing.T) {
+	opts := baseServerOptions()
+	opts.LocalFlag = "S"
+	opts.RemoteFlag = "A"
+
+	client := opts.ClientOptions("")
+	if client.LocalFlag != "A" {
+		t.Errorf("client LocalFlag = %q, want %q", client.LocalFlag, "A")
+	}
+	if client.RemoteFlag != "S" {
+		t.Errorf("client RemoteFlag = %q, want %q", client.RemoteFlag, "S")
+	}
+	if client.ServerAddr != "10.0.0.5:9999" {
+		t.Errorf("client ServerAddr = %q, want %q", client.ServerAddr, "10.0.0.5:9999")
+	}
+
+	client = opts.ClientOptions("203.0.113.7")
+	if client.ServerAddr != "203.0.113.7:9999" {
+		t.Errorf("client ServerAddr = %q, want %q", client.ServerAddr, "203.0.113.7:9999")
+	}
+
+	client = opts.ClientOptions("2001:db8::1")
+	if client.ServerAddr != "[2001:db8::1]:9999" {
+		t.Errorf("client ServerAddr = %q, want %q", client.ServerAddr, "[2001:db8::1]:9999")
+	}
+}
+
+func TestServerAndClientTransportMatch(t *testing.T) {
+	opts := baseServerOptions()
+	opts.Mode = "fast3"
+	opts.Conn = 4
+	opts.Block = "salsa20"
+	opts.MTU = 1400
+	opts.DShard = 10
+	opts.PShard = 3
+
+	serverOut, err := GenerateServer(opts)
+	if err != nil {
+		t.Fatalf("GenerateServer failed: %v", err)
+	}
+
+	client := opts.ClientOptions("203.0.113.7")
+	client.InterfaceName = "wlan0"
+	client.LocalAddr = "192.168.1.100:12345"
+	client.GatewayMAC = "11:22:33:44:55:66"
+	clientOut, err := Generate(client)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	var serverCfg, clientCfg map[string]interface{}
+	if err := yaml.Unmarshal([]byte(serverOut), &serverCfg); err != nil {
+		t.Fatalf("server config is not valid YAML: %v", err)
+	}
+	if err := yaml.Unmarshal([]byte(clientOut), &clientCfg); err != nil {
+		t.Fatalf("client config is not valid YAML: %v", err)
+	}
+
+	serverTransport := serverCfg["transport"].(map[string]interface{})
+	clientTransport := clientCfg["transport"].(map[string]interface{})
+	if toInt(serverTransport["conn"]) != toInt(clientTransport["conn"]) {
+		t.Errorf("conn mismatch: server %v, client %v", serverTransport["conn"], clientTransport["conn"])
+	}
+
+	serverKCP := serverTransport["kcp"].(map[string]interface{})
+	clientKCP := clientTransport["kcp"].(map[string]interface{})
+	for _, key := range []string{"mode", "key", "block", "mtu", "datashard", "parityshard"} {
+		if serverKCP[key] != clientKCP[key] {
+			t.Errorf("kcp.%s mismatch: server %v, client %v", key, serverKCP[key], clientKCP[key])
+		}
+	}
+}
+
+func TestServerManualKCPParams(t *testing.T) {
+	opts := baseServerOptions()
+	opts.Mode = "manual"
+	opts.NoDelay = 1
+	opts.Interval = 20
+	opts.Resend = 2
+	opts.NoCongestion = 1
+
+	out, err := GenerateServer(opts)
+	if err != nil {
+		t.Fatalf("GenerateServer failed: %v", err)
+	}
+	res, err := ParseServer([]byte(out))
+	if err != nil {
+		t.Fatalf("ParseServer failed: %v", err)
+	}
+	got := res.Options
+	if got.NoDelay != 1 || got.Interval != 20 || got.Resend != 2 || got.NoCongestion != 1 {
+		t.Errorf("manual params = %d/%d/%d/%d, want 1/20/2/1", got.NoDelay, got.Interval, got.Resend, got.NoCongestion)
+	}
+
+	client := opts.ClientOptions("")
+	if client.NoDelay != 1 || client.Interval != 20 || client.Resend != 2 || client.NoCongestion != 1 {
+		t.Errorf("ClientOptions did not copy manual params: %+v", client)
+	}
+}
+
+func TestGenerateServerIPv6(t *testing.T) {
+	opts := baseServerOptions()
+	opts.ServerIP = "2001:db8::5"
+
+	out, err := GenerateServer(opts)
+	if err != nil {
+		t.Fatalf("GenerateServer failed: %v", err)
+	}
+	if !strings.Contains(out, "ipv6:") || strings.Contains(out, "ipv4:") {
+		t.Errorf("expected only an ipv6 section:\n%s", out)
+	}
+
+	res, err := ParseServer([]byte(out))
+	if err != nil {
+		t.Fatalf("ParseServer failed: %v", err)
+	}
+	if res.Options.ServerIP != "2001:db8::5" || res.Options.Port != opts.Port {
+		t.Errorf("ServerIP/Port = %q/%d", res.Options.ServerIP, res.Options.Port)
+	}
+}
@@ -1,5 +1,7 @@
 package diag
 
+import "github.com/omid3098/autopaqet/gui/internal/config"
+
 // StepID identifies a diagnostic step.
 type StepID string
 
@@ -46,4 +48,10 @@ type Result struct {
 	Suggestions   []string          `json:"suggestions,omitempty"`
 	Summary       string            `json:"summary"`
 	ConfigSummary string            `json:"config_summary,omitempty"`
+	// ServerChecked is true when a server config was available and compared.
+	ServerChecked bool              `json:"server_checked,omitempty"`
+	Mismatches    []config.Mismatch `json:"mismatches,omitempty"`
+	// SocksReadyMs is how long SOCKS5 took to accept connections after
+	// paqet started, excluding the tunnel check; set when it did.
+	SocksReadyMs int64 `json:"socks_ready_ms,omitempty"`
 }
@@ -3,6 +3,7 @@ package diag
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,6 +14,11 @@ import (
 	"github.com/omid3098/autopaqet/gui/internal/config"
 )
 
+// ConfigFileName is the file in the config directory that paqet is started
+// with. It stays in use after a successful run, so callers that change the
+// running config rewrite this file.
+const ConfigFileName = "paqet-diag.yaml"
+
 // PaqetRunner abstracts paqet process management for testability.
 type PaqetRunner interface {
 	StartPaqet(configPath string) error
@@ -21,7 +27,10 @@ type PaqetRunner interface {
 
 // RunOptions holds parameters for a diagnostic run.
 type RunOptions struct {
-	ConfigOpts     *config.Options
+	ConfigOpts *config.Options
+	// ServerConfig, if known, is compared against ConfigOpts so the report
+	// can name concrete mismatches instead of guessing.
+	ServerConfig   *config.ServerOptions
 	SocksAddr      string
 	ProfileName    string
 	ServerAddr     string
@@ -137,7 +146,7 @@ func (p *Prober) Run(ctx context.Context, opts *RunOptions) *Result {
 	})
 
 	// Generate config and write to temp file
-	configPath := filepath.Join(p.configDir, "paqet-diag.yaml")
+	configPath := filepath.Join(p.configDir, ConfigFileName)
 	yamlStr, err := config.Generate(opts.ConfigOpts)
 	if err != nil {
 		step := StepResult{ID: StepConnect, Status: StatusFail, Message: "Config generation failed", Detail: err.Error()}
@@ -146,6 +155,14 @@ func (p *Prober) Run(ctx context.Context, opts *RunOptions) *Result {
 		result.Summary = "Failed to generate configuration"
 		return result
 	}
+	if err := checkForwardPorts(opts.ConfigOpts.Forward); err != nil {
+		step := StepResult{ID: StepConnect, Status: StatusFail, Message: "Forward port unavailable", Detail: err.Error()}
+		p.emitStep(step)
+		result.Steps = append(result.Steps, step)
+		result.Summary = "A forward rule's listen port is already in use"
+		result.Suggestions = []string{"Close the program using the port or change the forward rule's listen address"}
+		return result
+	}
 	if err := os.WriteFile(configPath, []byte(yamlStr), 0644); err != nil {
 		step := StepResult{ID: StepConnect, Status: StatusFail, Message: "Failed to write config", Detail: err.Error()}
 		p.emitStep(step)
@@ -169,6 +186,10 @@ func (p *Prober) Run(ctx context.Context, opts *RunOptions) *Result {
 	}
 	result.ConfigSummary = fmt.Sprintf("mode=%s conn=%d block=%s flags=%s port=%s",
 		cfgMode, cfgConn, cfgBlock, currentFlags, extractPort(opts.ServerAddr))
+	if opts.ServerConfig != nil {
+		result.ServerChecked = true
+		result.Mismatches = config.CheckCompat(opts.ConfigOpts, opts.ServerConfig)
+	}
 
 	// Start paqet and poll SOCKS5
 	startTime := time.Now()
@@ -186,10 +207,12 @@ func (p *Prober) Run(ctx context.Context, opts *RunOptions) *Result {
 		pollFn = opts.PollFunc
 	}
 	connectErr := pollFn(ctx, opts.SocksAddr, opts.AttemptTimeout)
-	elapsed := time.Since(startTime).Round(100 * time.Millisecond)
+	ready := time.Since(startTime)
+	elapsed := ready.Round(100 * time.Millisecond)
 
 	if connectErr == nil {
 		// Connection successful!
+		result.SocksReadyMs = ready.Milliseconds()
 		step := StepResult{
 			ID:      StepConnect,
 			Status:  StatusPass,
@@ -456,6 +479,17 @@ func (p *Prober) buildSuggestions(opts *RunOptions, result *Result) []string {
 		return suggestions
 	}
 
+	// Known mismatches against the server config beat any guesswork below
+	if len(result.Mismatches) > 0 {
+		for _, m := range result.Mismatches {
+			suggestions = append(suggestions, "Server mismatch: "+m.Message)
+		}
+		suggestions = append(suggestions,
+			"Update the profile (or the server config) so these settings match, then reconnect",
+		)
+		return suggestions
+	}
+
 	// KCP parameter mismatch: SOCKS5 started but tunnel doesn't forward traffic
 	if tunnelVerifyFailed && allPingOK {
 		mode := opts.ConfigOpts.Mode
@@ -555,6 +589,17 @@ func (p *Prober) emitReport(result *Result) {
 		p.onLog("  " + result.ConfigSummary)
 	}
 
+	if result.ServerChecked {
+		p.onLog("")
+		p.onLog("--- Server Config Check ---")
+		if len(result.Mismatches) == 0 {
+			p.onLog("  All shared settings match the server config")
+		}
+		for _, m := range result.Mismatches {
+			p.onLog(fmt.Sprintf("[MISMATCH] %s", m.Message))
+		}
+	}
+
 	if len(result.FlagProbes) > 0 {
 		p.onLog("")
 		p.onLog("--- Flag Probe Results ---")
@@ -587,6 +632,27 @@ func (p *Prober) cancelled(result *Result) *Result {
 	return result
 }
 
+// checkForwardPorts verifies that every forward rule's listen address can be
+// bound, so a port clash is reported before paqet starts instead of failing
+// silently inside it.
+func checkForwardPorts(rules []config.ForwardRule) error {
+	for _, r := range rules {
+		if r.Protocol == "udp" {
+			conn, err := net.ListenPacket("udp", r.Listen)
+			if err != nil {
+				return fmt.Errorf("udp %s: %w", r.Listen, err)
+			}
+			conn.Close()
+			continue
+		}
+		ln, err := net.Listen("tcp", r.Listen)
+		if err != nil {
+			return fmt.Errorf("tcp %s: %w", r.Listen, err)
+		}
+		ln.Close()
+	}
+	return nil
+}
 
 // extractHost extracts the host part from a "host:port" string.
 func extractHost(addr string) string {
@@ -608,17 +674,9 @@ func extractPort(addr string) string {
 
 // splitHostPort splits a host:port string. Handles IPv6 [host]:port.
 func splitHostPort(addr string) (string, string, error) {
-	// Try standard split first
-	if host, port, err := splitAddr(addr); err == nil {
-		return host, port, nil
-	}
-	return addr, "", fmt.Errorf("invalid address: %s", addr)
-}
-
-func splitAddr(addr string) (string, string, error) {
-	last := strings.LastIndex(addr, ":")
-	if last < 0 {
-		return addr, "", fmt.Errorf("no port")
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, "", fmt.Errorf("invalid address: %s", addr)
 	}
-	return addr[:last], addr[last+1:], nil
+	return host, port, nil
 }
@@ -3,6 +3,7 @@ package diag
 import (
 	"context"
 	"fmt"
+	"net"
 	"strings"
 	"testing"
 	"time"
@@ -54,6 +55,8 @@ func TestExtractHost(t *testing.T) {
 		{"1.2.3.4:9999", "1.2.3.4"},
 		{"example.com:443", "example.com"},
 		{"just-host", "just-host"},
+		{"[2001:db8::1]:9999", "2001:db8::1"},
+		{"2001:db8::1", "2001:db8::1"},
 	}
 	for _, tc := range tests {
 		got := extractHost(tc.addr)
@@ -69,6 +72,9 @@ func TestExtractPort(t *testing.T) {
 	}{
 		{"1.2.3.4:9999", "9999"},
 		{"example.com:443", "443"},
+		{"[2001:db8::1]:9999", "9999"},
+		{"[fe80::1%eth0]:443", "443"},
+		{"just-host", ""},
 	}
 	for _, tc := range tests {
 		got := extractPort(tc.addr)
@@ -211,6 +217,52 @@ func TestProber_StartFailure(t *testing.T) {
 	}
 }
 
+func TestProber_ForwardPortInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	runner := &mockRunner{}
+	p := NewProber("/fake/paqet", t.TempDir(), runner, nil, nil)
+
+	opts := baseOpts()
+	opts.ConfigOpts.Forward = []config.ForwardRule{{Listen: ln.Addr().String(), Target: "internal:80"}}
+	result := p.Run(context.Background(), opts)
+
+	if len(runner.startCalls) != 0 {
+		t.Error("paqet should not start when a forward port is taken")
+	}
+	last := result.Steps[len(result.Steps)-1]
+	if last.ID != StepConnect || last.Status != StatusFail {
+		t.Errorf("last step = %+v, want connect failure", last)
+	}
+	if !strings.Contains(last.Detail, ln.Addr().String()) {
+		t.Errorf("Detail = %q, want it to name %s", last.Detail, ln.Addr())
+	}
+}
+
+func TestCheckForwardPorts(t *testing.T) {
+	free := []config.ForwardRule{
+		{Listen: "127.0.0.1:0", Target: "internal:80"},
+		{Listen: "127.0.0.1:0", Target: "internal:53", Protocol: "udp"},
+	}
+	if err := checkForwardPorts(free); err != nil {
+		t.Errorf("expected free ports to pass, got %v", err)
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+	taken := []config.ForwardRule{{Listen: conn.LocalAddr().String(), Target: "internal:53", Protocol: "udp"}}
+	if err := checkForwardPorts(taken); err == nil {
+		t.Error("expected error for a udp port in use")
+	}
+}
+
 func TestProber_Cancellation(t *testing.T) {
 	runner := &mockRunner{}
 	ctx, cancel := context.WithCancel(context.Background())
@@ -309,6 +361,29 @@ func TestVerifyTunnel_FullSuccess(t *testing.T) {
 	}
 }
 
+func TestProber_SocksReadyTime(t *testing.T) {
+	p := NewProber("/fake/paqet", t.TempDir(), &mockRunner{}, func(StepResult) {}, func(string) {})
+
+	opts := baseOpts()
+	opts.PollFunc = func(ctx context.Context, socksAddr string, timeout time.Duration) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}
+	// A slow tunnel check must not count towards the SOCKS5 ready time
+	opts.VerifyFunc = func(ctx context.Context, socksAddr string, timeout time.Duration) (bool, bool, error) {
+		time.Sleep(500 * time.Millisecond)
+		return true, true, nil
+	}
+
+	result := p.Run(context.Background(), opts)
+	if !result.Success {
+		t.Fatalf("expected success, got %q", result.Summary)
+	}
+	if result.SocksReadyMs < 50 || result.SocksReadyMs >= 500 {
+		t.Errorf("SocksReadyMs = %d, want the poll time only", result.SocksReadyMs)
+	}
+}
+
 func TestVerifyTunnel_HTTPFailRunsDiagnostics(t *testing.T) {
 	runner := &mockRunner{}
 	var steps []StepResult
@@ -593,3 +668,68 @@ func TestEmitReport_WithSuggestions(t *testing.T) {
 		t.Error("report missing first suggestion")
 	}
 }
+
+func TestBuildSuggestions_ServerMismatches(t *testing.T) {
+	opts := baseOpts()
+	result := &Result{
+		FlagProbes: []FlagProbeResult{
+			{Flag: "S", Success: true},
+			{Flag: "PA", Success: true},
+			{Flag: "A", Success: true},
+		},
+		ServerChecked: true,
+		Mismatches: []config.Mismatch{
+			{Field: "mode", Client: "fast", Server: "fast3", Message: "KCP mode is fast on the client but fast3 on the server"},
+		},
+	}
+
+	p := &Prober{}
+	suggestions := p.buildSuggestions(opts, result)
+
+	if len(suggestions) == 0 || !strings.Contains(suggestions[0], "fast3 on the server") {
+		t.Fatalf("expected concrete mismatch suggestion first, got %v", suggestions)
+	}
+	for _, s := range suggestions {
+		if strings.Contains(s, "SYN") || strings.Contains(s, "443") {
+			t.Errorf("unexpected guesswork suggestion with known mismatches: %q", s)
+		}
+	}
+}
+
+func TestProber_ServerConfigChecked(t *testing.T) {
+	runner := &mockRunner{}
+	var logLines []string
+	p := NewProber("/fake/paqet", t.TempDir(), runner, nil, func(line string) {
+		logLines = append(logLines, line)
+	})
+
+	opts := baseOpts()
+	opts.ServerConfig = &config.ServerOptions{
+		Port:      9999,
+		Key:       opts.ConfigOpts.Key,
+		Conn:      4,
+		LocalFlag: "PA",
+	}
+	opts.PollFunc = func(ctx context.Context, socksAddr string, timeout time.Duration) error {
+		return nil
+	}
+	opts.VerifyFunc = func(ctx context.Context, socksAddr string, timeout time.Duration) (bool, bool, error) {
+		return true, true, nil
+	}
+
+	result := p.Run(context.Background(), opts)
+
+	if !result.ServerChecked {
+		t.Error("expected ServerChecked when a server config is provided")
+	}
+	if len(result.Mismatches) != 1 || result.Mismatches[0].Field != "conn" {
+		t.Errorf("Mismatches = %+v, want a single conn mismatch", result.Mismatches)
+	}
+	report := strings.Join(logLines, "\n")
+	if !strings.Contains(report, "--- Server Config Check ---") {
+		t.Error("report missing server config section")
+	}
+	if !strings.Contains(report, "[MISMATCH] connection count is 1 on the client but 4 on the server") {
+		t.Errorf("report missing mismatch line:\n%s", report)
+	}
+}
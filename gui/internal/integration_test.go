@@ -127,7 +127,7 @@ func TestURIRoundTripThroughProfile(t *testing.T) {
 	}
 
 	// Export
-	exported, err := store.ExportToURI(p.ID)
+	exported, err := store.ExportToURI(p.ID, false)
 	if err != nil {
 		t.Fatalf("ExportToURI failed: %v", err)
 	}
@@ -305,7 +305,7 @@ func TestFullWorkflow(t *testing.T) {
 	}
 
 	// Step 5: Export
-	exported, _ := store.ExportToURI(imported.ID)
+	exported, _ := store.ExportToURI(imported.ID, false)
 	parsed, _ := uri.Parse(exported)
 	if parsed.Name != "Updated VPN" {
 		t.Errorf("exported name = %q, want Updated VPN", parsed.Name)
@@ -1,9 +1,11 @@
 package network
 
+import "net"
+
 // NetworkInfo holds the auto-detected network configuration.
 type NetworkInfo struct {
 	InterfaceName string `json:"interface_name"`
-	LocalIP       string `json:"local_ip"`
+	LocalIP       string `json:"local_ip"` // IPv6 when detected for an IPv6 server
 	GatewayIP     string `json:"gateway_ip"`
 	GatewayMAC    string `json:"gateway_mac"`
 	NpcapGUID     string `json:"npcap_guid,omitempty"` // Windows only
@@ -11,5 +13,14 @@ type NetworkInfo struct {
 
 // Detector is the interface for network auto-detection.
 type Detector interface {
-	Detect() (*NetworkInfo, error)
+	// Detect finds the interface, source address and next hop used to reach
+	// serverHost. An IPv6 literal selects the IPv6 route; anything else
+	// (including an empty host) detects the default IPv4 route.
+	Detect(serverHost string) (*NetworkInfo, error)
+}
+
+// isIPv6 reports whether host is an IPv6 address literal.
+func isIPv6(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.To4() == nil
 }
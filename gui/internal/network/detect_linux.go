@@ -22,8 +22,17 @@ func NewDetector() *LinuxDetector {
 }
 
 // Detect auto-detects the network configuration using ip route and ip neigh.
-func (d *LinuxDetector) Detect() (*NetworkInfo, error) {
-	routeOutput, err := d.RunCommand("ip", "route", "get", "1.1.1.1")
+// For an IPv6 server host the route to that host is queried with ip -6.
+func (d *LinuxDetector) Detect(serverHost string) (*NetworkInfo, error) {
+	v6 := isIPv6(serverHost)
+
+	var routeOutput string
+	var err error
+	if v6 {
+		routeOutput, err = d.RunCommand("ip", "-6", "route", "get", serverHost)
+	} else {
+		routeOutput, err = d.RunCommand("ip", "route", "get", "1.1.1.1")
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get route: %w", err)
 	}
@@ -44,10 +53,17 @@ func (d *LinuxDetector) Detect() (*NetworkInfo, error) {
 		return nil, fmt.Errorf("could not detect gateway IP")
 	}
 
-	// Ping gateway to populate ARP cache
-	d.RunCommand("ping", "-c", "1", "-W", "1", info.GatewayIP)
-
-	neighOutput, err := d.RunCommand("ip", "neigh", "show", info.GatewayIP)
+	var neighOutput string
+	if v6 {
+		// Ping gateway to populate the neighbor cache. IPv6 gateways are
+		// usually link-local, so the interface must be given explicitly.
+		d.RunCommand("ping", "-6", "-c", "1", "-W", "1", "-I", info.InterfaceName, info.GatewayIP)
+		neighOutput, err = d.RunCommand("ip", "-6", "neigh", "show", info.GatewayIP, "dev", info.InterfaceName)
+	} else {
+		// Ping gateway to populate ARP cache
+		d.RunCommand("ping", "-c", "1", "-W", "1", info.GatewayIP)
+		neighOutput, err = d.RunCommand("ip", "neigh", "show", info.GatewayIP)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get neighbor: %w", err)
 	}
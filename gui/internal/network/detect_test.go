@@ -2,6 +2,7 @@ package network
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -23,7 +24,7 @@ func TestDetectWithMockLinuxCommands(t *testing.T) {
 		},
 	}
 
-	info, err := d.Detect()
+	info, err := d.Detect("")
 	if err != nil {
 		t.Fatalf("Detect failed: %v", err)
 	}
@@ -42,6 +43,67 @@ func TestDetectWithMockLinuxCommands(t *testing.T) {
 	}
 }
 
+func TestDetectIPv6Server(t *testing.T) {
+	var calls []string
+	d := &LinuxDetector{
+		RunCommand: func(name string, args ...string) (string, error) {
+			calls = append(calls, name+" "+strings.Join(args, " "))
+			switch name + " " + strings.Join(args, " ") {
+			case "ip -6 route get 2001:db8::1":
+				return "2001:db8::1 from :: via fe80::1 dev eth0 proto ra src 2001:db8::100 metric 1024 pref medium", nil
+			case "ip -6 neigh show fe80::1 dev eth0":
+				return "fe80::1 lladdr aa:bb:cc:dd:ee:ff router REACHABLE", nil
+			case "ping -6 -c 1 -W 1 -I eth0 fe80::1":
+				return "", nil
+			}
+			return "", fmt.Errorf("unexpected command: %s %v", name, args)
+		},
+	}
+
+	info, err := d.Detect("2001:db8::1")
+	if err != nil {
+		t.Fatalf("Detect failed: %v (calls: %v)", err, calls)
+	}
+	if info.InterfaceName != "eth0" {
+		t.Errorf("InterfaceName = %q, want %q", info.InterfaceName, "eth0")
+	}
+	if info.LocalIP != "2001:db8::100" {
+		t.Errorf("LocalIP = %q, want %q", info.LocalIP, "2001:db8::100")
+	}
+	if info.GatewayIP != "fe80::1" {
+		t.Errorf("GatewayIP = %q, want %q", info.GatewayIP, "fe80::1")
+	}
+	if info.GatewayMAC != "aa:bb:cc:dd:ee:ff" {
+		t.Errorf("GatewayMAC = %q, want %q", info.GatewayMAC, "aa:bb:cc:dd:ee:ff")
+	}
+}
+
+func TestDetectIPv4ServerUsesDefaultRoute(t *testing.T) {
+	var routeArgs []string
+	d := &LinuxDetector{
+		RunCommand: func(name string, args ...string) (string, error) {
+			if name == "ip" && args[0] == "route" {
+				routeArgs = args
+				return "1.1.1.1 via 192.168.1.1 dev eth0 src 192.168.1.100", nil
+			}
+			if name == "ip" && args[0] == "neigh" {
+				return "192.168.1.1 dev eth0 lladdr aa:bb:cc:dd:ee:ff REACHABLE", nil
+			}
+			return "", nil
+		},
+	}
+
+	for _, host := range []string{"203.0.113.7", "vpn.example.com", "::ffff:203.0.113.7"} {
+		routeArgs = nil
+		if _, err := d.Detect(host); err != nil {
+			t.Fatalf("Detect(%q) failed: %v", host, err)
+		}
+		if strings.Join(routeArgs, " ") != "route get 1.1.1.1" {
+			t.Errorf("Detect(%q) route args = %v, want IPv4 default route", host, routeArgs)
+		}
+	}
+}
+
 func TestDetectFailsOnNoInterface(t *testing.T) {
 	d := &LinuxDetector{
 		RunCommand: func(name string, args ...string) (string, error) {
@@ -52,7 +114,7 @@ func TestDetectFailsOnNoInterface(t *testing.T) {
 		},
 	}
 
-	_, err := d.Detect()
+	_, err := d.Detect("")
 	if err == nil {
 		t.Error("expected error for missing interface")
 	}
@@ -71,7 +133,7 @@ func TestDetectFailsOnNoGatewayMAC(t *testing.T) {
 		},
 	}
 
-	_, err := d.Detect()
+	_, err := d.Detect("")
 	if err == nil {
 		t.Error("expected error for missing gateway MAC")
 	}
@@ -87,7 +149,7 @@ func TestDetectFailsOnRouteError(t *testing.T) {
 		},
 	}
 
-	_, err := d.Detect()
+	_, err := d.Detect("")
 	if err == nil {
 		t.Error("expected error for route failure")
 	}
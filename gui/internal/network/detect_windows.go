@@ -23,10 +23,17 @@ func NewDetector() *WindowsDetector {
 
 // Detect auto-detects the network configuration on Windows.
 // Uses PowerShell commands to mirror AutoPaqet.Network.ps1 behavior.
-func (d *WindowsDetector) Detect() (*NetworkInfo, error) {
+// For an IPv6 server host the IPv6 default route and neighbor table are used.
+func (d *WindowsDetector) Detect(serverHost string) (*NetworkInfo, error) {
+	v6 := isIPv6(serverHost)
+	prefix, family := "0.0.0.0/0", "IPv4"
+	if v6 {
+		prefix, family = "::/0", "IPv6"
+	}
+
 	// Get default route interface
 	routeOutput, err := d.RunCommand("powershell", "-NoProfile", "-Command",
-		"(Get-NetRoute -DestinationPrefix '0.0.0.0/0' | Sort-Object RouteMetric | Select-Object -First 1).InterfaceIndex")
+		fmt.Sprintf("(Get-NetRoute -DestinationPrefix '%s' | Sort-Object RouteMetric | Select-Object -First 1).InterfaceIndex", prefix))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get default route: %w", err)
 	}
@@ -43,9 +50,9 @@ func (d *WindowsDetector) Detect() (*NetworkInfo, error) {
 	info := &NetworkInfo{}
 	info.InterfaceName = strings.TrimSpace(ifaceOutput)
 
-	// Get local IP
+	// Get local IP (skipping link-local addresses, which cannot reach the server)
 	ipOutput, err := d.RunCommand("powershell", "-NoProfile", "-Command",
-		fmt.Sprintf("(Get-NetIPAddress -InterfaceIndex %s -AddressFamily IPv4).IPAddress", ifIndex))
+		fmt.Sprintf("(Get-NetIPAddress -InterfaceIndex %s -AddressFamily %s | Where-Object { $_.IPAddress -notlike 'fe80*' }).IPAddress", ifIndex, family))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get local IP: %w", err)
 	}
@@ -53,20 +60,32 @@ func (d *WindowsDetector) Detect() (*NetworkInfo, error) {
 
 	// Get gateway IP
 	gwOutput, err := d.RunCommand("powershell", "-NoProfile", "-Command",
-		fmt.Sprintf("(Get-NetRoute -InterfaceIndex %s -DestinationPrefix '0.0.0.0/0').NextHop", ifIndex))
+		fmt.Sprintf("(Get-NetRoute -InterfaceIndex %s -DestinationPrefix '%s').NextHop", ifIndex, prefix))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get gateway IP: %w", err)
 	}
 	info.GatewayIP = strings.TrimSpace(gwOutput)
 
-	// Ping to populate ARP cache, then get MAC
-	d.RunCommand("ping", "-n", "1", "-w", "1000", info.GatewayIP)
+	if v6 {
+		// arp only covers IPv4; read the IPv6 neighbor cache instead.
+		d.RunCommand("ping", "-6", "-n", "1", "-w", "1000", fmt.Sprintf("%s%%%s", info.GatewayIP, ifIndex))
 
-	arpOutput, err := d.RunCommand("arp", "-a", info.GatewayIP)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get ARP entry: %w", err)
+		neighOutput, err := d.RunCommand("powershell", "-NoProfile", "-Command",
+			fmt.Sprintf("(Get-NetNeighbor -InterfaceIndex %s -IPAddress '%s').LinkLayerAddress", ifIndex, info.GatewayIP))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get neighbor entry: %w", err)
+		}
+		info.GatewayMAC = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(neighOutput), "-", ":"))
+	} else {
+		// Ping to populate ARP cache, then get MAC
+		d.RunCommand("ping", "-n", "1", "-w", "1000", info.GatewayIP)
+
+		arpOutput, err := d.RunCommand("arp", "-a", info.GatewayIP)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get ARP entry: %w", err)
+		}
+		info.GatewayMAC = extractWindowsMAC(arpOutput)
 	}
-	info.GatewayMAC = extractWindowsMAC(arpOutput)
 
 	// Get Npcap GUID
 	guidOutput, err := d.RunCommand("powershell", "-NoProfile", "-Command",
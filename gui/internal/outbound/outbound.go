@@ -0,0 +1,188 @@
+// Package outbound turns a profile's local SOCKS5 listener into outbound
+// config snippets for other proxy clients, so they can be chained through
+// paqet.
+package outbound
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported formats.
+const (
+	SingBox     = "sing-box"
+	Xray        = "xray"
+	Clash       = "clash"
+	Proxychains = "proxychains"
+)
+
+// DefaultListen is the SOCKS5 address paqet listens on when a profile does
+// not set one.
+const DefaultListen = "127.0.0.1:1080"
+
+// DefaultTag names the outbound when Socks.Name is empty.
+const DefaultTag = "paqet"
+
+// exporters maps each format to its exporter, in the order Formats lists them.
+var exporters = []struct {
+	name   string
+	export func(s *Socks, host string, port int) (string, error)
+}{
+	{SingBox, singBox},
+	{Xray, xray},
+	{Clash, clash},
+	{Proxychains, proxychains},
+}
+
+// Socks is the local SOCKS5 endpoint to export.
+type Socks struct {
+	Name   string // outbound tag or proxy name
+	Listen string // host:port, empty means DefaultListen
+	User   string
+	Pass   string
+}
+
+// Formats returns the supported format names.
+func Formats() []string {
+	names := make([]string, len(exporters))
+	for i, e := range exporters {
+		names[i] = e.name
+	}
+	return names
+}
+
+// Export renders s as a config snippet in the given format.
+func Export(format string, s *Socks) (string, error) {
+	host, port, err := dialAddr(s.Listen)
+	if err != nil {
+		return "", err
+	}
+	if (s.User == "") != (s.Pass == "") {
+		return "", fmt.Errorf("SOCKS5 username and password must be set together")
+	}
+	for _, e := range exporters {
+		if e.name == format {
+			return e.export(s, host, port)
+		}
+	}
+	return "", fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(Formats(), ", "))
+}
+
+// dialAddr returns the address a client on the same machine connects to.
+// A wildcard listen address is reachable over loopback.
+func dialAddr(listen string) (string, int, error) {
+	if listen == "" {
+		listen = DefaultListen
+	}
+	host, portStr, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid SOCKS5 listen address %q: %w", listen, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid SOCKS5 listen port %q", portStr)
+	}
+	switch ip := net.ParseIP(host); {
+	case host == "":
+		host = "127.0.0.1"
+	case ip != nil && ip.IsUnspecified():
+		if ip.To4() != nil {
+			host = "127.0.0.1"
+		} else {
+			host = "::1"
+		}
+	}
+	return host, port, nil
+}
+
+func tag(s *Socks) string {
+	if s.Name == "" {
+		return DefaultTag
+	}
+	return s.Name
+}
+
+func marshalJSON(v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// singBox renders a sing-box outbound object.
+func singBox(s *Socks, host string, port int) (string, error) {
+	return marshalJSON(struct {
+		Type       string `json:"type"`
+		Tag        string `json:"tag"`
+		Server     string `json:"server"`
+		ServerPort int    `json:"server_port"`
+		Version    string `json:"version"`
+		Username   string `json:"username,omitempty"`
+		Password   string `json:"password,omitempty"`
+	}{"socks", tag(s), host, port, "5", s.User, s.Pass})
+}
+
+// xray renders an Xray (or V2Ray) outbound object.
+func xray(s *Socks, host string, port int) (string, error) {
+	type user struct {
+		User string `json:"user"`
+		Pass string `json:"pass"`
+	}
+	type server struct {
+		Address string `json:"address"`
+		Port    int    `json:"port"`
+		Users   []user `json:"users,omitempty"`
+	}
+	srv := server{Address: host, Port: port}
+	if s.User != "" {
+		srv.Users = []user{{s.User, s.Pass}}
+	}
+	type settings struct {
+		Servers []server `json:"servers"`
+	}
+	return marshalJSON(struct {
+		Tag      string   `json:"tag"`
+		Protocol string   `json:"protocol"`
+		Settings settings `json:"settings"`
+	}{tag(s), "socks", settings{[]server{srv}}})
+}
+
+// clash renders a Clash (and Clash.Meta/mihomo) proxies list.
+func clash(s *Socks, host string, port int) (string, error) {
+	type proxy struct {
+		Name     string `yaml:"name"`
+		Type     string `yaml:"type"`
+		Server   string `yaml:"server"`
+		Port     int    `yaml:"port"`
+		Username string `yaml:"username,omitempty"`
+		Password string `yaml:"password,omitempty"`
+		UDP      bool   `yaml:"udp"`
+	}
+	data, err := yaml.Marshal(map[string][]proxy{
+		"proxies": {{tag(s), "socks5", host, port, s.User, s.Pass, true}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// proxychains renders a proxychains-ng config. Its format is whitespace
+// separated, so credentials cannot contain spaces.
+func proxychains(s *Socks, host string, port int) (string, error) {
+	if strings.ContainsAny(s.User+s.Pass, " \t\r\n") {
+		return "", fmt.Errorf("proxychains does not support whitespace in SOCKS5 credentials")
+	}
+	line := fmt.Sprintf("socks5 %s %d", host, port)
+	if s.User != "" {
+		line += " " + s.User + " " + s.Pass
+	}
+	name := strings.Join(strings.Fields(tag(s)), " ") // keep the comment on one line
+	return fmt.Sprintf("# %s\nstrict_chain\nproxy_dns\ntcp_read_time_out 15000\ntcp_connect_time_out 8000\n\n[ProxyList]\n%s\n", name, line), nil
+}
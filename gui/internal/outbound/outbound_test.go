@@ -0,0 +1,68 @@
+package outbound
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestExportGolden(t *testing.T) {
+	cases := map[string]*Socks{
+		"default":  {},
+		"auth":     {Name: "Office", Listen: "127.0.0.1:1081", User: "user1", Pass: "p@ss:word"},
+		"wildcard": {Name: "Home Lab", Listen: "[::]:9050"},
+	}
+
+	for _, format := range Formats() {
+		for name, s := range cases {
+			t.Run(format+"/"+name, func(t *testing.T) {
+				got, err := Export(format, s)
+				if err != nil {
+					t.Fatalf("Export failed: %v", err)
+				}
+
+				path := filepath.Join("testdata", format+"-"+name+".golden")
+				if *update {
+					if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("missing golden file (run with -update): %v", err)
+				}
+				if got != string(want) {
+					t.Errorf("output differs from %s:\n%s\nwant:\n%s", path, got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		socks  *Socks
+		want   string
+	}{
+		{"unknown format", "surge", &Socks{}, "unknown format"},
+		{"bad listen", SingBox, &Socks{Listen: "1080"}, "invalid SOCKS5 listen address"},
+		{"bad port", Xray, &Socks{Listen: "127.0.0.1:0"}, "invalid SOCKS5 listen port"},
+		{"user without pass", Clash, &Socks{User: "u"}, "set together"},
+		{"space in credentials", Proxychains, &Socks{User: "a user", Pass: "p"}, "whitespace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Export(tt.format, tt.socks)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -6,8 +6,12 @@ import (
 	"io"
 	"os/exec"
 	"sync"
+	"time"
 )
 
+// restartTimeout bounds how long Restart waits for the old process to exit.
+const restartTimeout = 10 * time.Second
+
 // State represents the process lifecycle state.
 type State string
 
@@ -28,7 +32,8 @@ type Manager struct {
 	lastError     string
 	binaryPath    string
 	onStateChange func(State)
-	stopping      bool // true when Stop() was explicitly called
+	stopping      bool          // true when Stop() was explicitly called
+	exited        chan struct{} // closed once the current process has been reaped
 }
 
 // NewManager creates a new process manager.
@@ -78,8 +83,10 @@ func (m *Manager) Start(configPath string) error {
 		return err
 	}
 
+	exited := make(chan struct{})
 	m.mu.Lock()
 	m.cmd = cmd
+	m.exited = exited
 	m.setState(StateConnected)
 	m.mu.Unlock()
 
@@ -92,6 +99,7 @@ func (m *Manager) Start(configPath string) error {
 		err := cmd.Wait()
 		m.mu.Lock()
 		defer m.mu.Unlock()
+		defer close(exited)
 
 		if m.stopping {
 			// Explicit stop — always go to Idle regardless of exit code
@@ -122,6 +130,28 @@ func (m *Manager) Stop() error {
 	return m.killProcess()
 }
 
+// Restart stops the running paqet process, waits for it to exit, and starts
+// it again with the given config file. If nothing is running it just starts.
+func (m *Manager) Restart(configPath string) error {
+	m.mu.RLock()
+	running := m.cmd != nil
+	exited := m.exited
+	m.mu.RUnlock()
+
+	if running {
+		if err := m.Stop(); err != nil {
+			return fmt.Errorf("failed to stop paqet: %w", err)
+		}
+		select {
+		case <-exited:
+		case <-time.After(restartTimeout):
+			return fmt.Errorf("timed out waiting for paqet to exit")
+		}
+	}
+
+	return m.Start(configPath)
+}
+
 // GetState returns the current process state.
 func (m *Manager) GetState() State {
 	m.mu.RLock()
@@ -157,6 +157,51 @@ func TestManagerStartAndStop(t *testing.T) {
 	}
 }
 
+func TestManagerRestart(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "fake-paqet.sh")
+	os.WriteFile(scriptPath, []byte("#!/bin/sh\necho \"config $3\"\nwhile true; do sleep 0.1; done\n"), 0755)
+	first := filepath.Join(tmpDir, "first.yml")
+	second := filepath.Join(tmpDir, "second.yml")
+
+	m := NewManager(scriptPath)
+	if err := m.Start(first); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer m.Stop()
+	time.Sleep(200 * time.Millisecond)
+
+	if err := m.Restart(second); err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	// The old process's exit must not clobber the new one.
+	if m.GetState() != StateConnected {
+		t.Errorf("state after restart = %q, want %q", m.GetState(), StateConnected)
+	}
+	logs := m.GetLogs(10)
+	if len(logs) < 2 || logs[len(logs)-1] != "config "+second {
+		t.Errorf("logs = %v, want last line %q", logs, "config "+second)
+	}
+}
+
+func TestManagerRestartWhenIdle(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "fake-paqet.sh")
+	os.WriteFile(scriptPath, []byte("#!/bin/sh\nsleep 60\n"), 0755)
+
+	m := NewManager(scriptPath)
+	if err := m.Restart(filepath.Join(tmpDir, "config.yml")); err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+	defer m.Stop()
+
+	if m.GetState() != StateConnected {
+		t.Errorf("state = %q, want %q", m.GetState(), StateConnected)
+	}
+}
+
 func TestManagerCannotStartWhileRunning(t *testing.T) {
 	tmpDir := t.TempDir()
 	scriptPath := filepath.Join(tmpDir, "fake-paqet.sh")
@@ -0,0 +1,343 @@
+package profile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/omid3098/autopaqet/gui/internal/uri"
+)
+
+// A bundle carries profiles between machines. A plain bundle is a
+// profiles.json envelope with a few extra fields:
+//
+//	{"format": "autopaqet-profiles", "exported": ..., "version": N, "profiles": [...]}
+//
+// A passphrase-protected bundle has no "version" or "profiles"; "sealed"
+// holds the plain envelope sealed like the vault's data keys, under a key
+// derived from the passphrase with the Argon2id parameters beside it and
+// the salt as additional data.
+const bundleFormat = "autopaqet-profiles"
+
+// ErrPassphraseRequired is returned when importing a protected bundle
+// without a passphrase.
+var ErrPassphraseRequired = errors.New("bundle is encrypted, passphrase required")
+
+// bundleFile is the contents of a bundle.
+type bundleFile struct {
+	Format   string    `json:"format"`
+	Exported time.Time `json:"exported"`
+
+	// Plain bundles
+	Version  int        `json:"version,omitempty"`
+	Profiles []*Profile `json:"profiles,omitempty"`
+
+	// Protected bundles
+	Salt    []byte `json:"salt,omitempty"`
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+	Sealed  []byte `json:"sealed,omitempty"`
+}
+
+// Duplicates says what importing does with a profile that has the same
+// host, port and key as one already in the store.
+type Duplicates string
+
+const (
+	MergeDuplicates Duplicates = "merge"     // update the existing profile
+	SkipDuplicates  Duplicates = "skip"      // keep the existing profile as is
+	KeepDuplicates  Duplicates = "duplicate" // add the import as another profile
+)
+
+// ImportResult reports what ImportBundle did with each profile it found.
+type ImportResult struct {
+	Added   []*Profile `json:"added"`
+	Merged  []*Profile `json:"merged"`
+	Skipped []string   `json:"skipped"` // names of duplicates left alone
+	Errors  []string   `json:"errors"`  // entries that could not be imported
+}
+
+// ExportBundle returns the profiles with the given IDs, or all profiles if
+// ids is empty, as a bundle for ImportBundle. With a passphrase the bundle
+// is encrypted. Inherited settings are copied into each profile; parents,
+// subscription links and connection history stay behind.
+func (s *Store) ExportBundle(ids []string, passphrase string) ([]byte, error) {
+	s.mu.RLock()
+	profiles, err := s.bundleProfiles(ids)
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &bundleFile{Format: bundleFormat, Exported: time.Now().UTC()}
+	if passphrase == "" {
+		b.Version, b.Profiles = SchemaVersion, profiles
+		return json.MarshalIndent(b, "", "  ")
+	}
+
+	plain, err := json.Marshal(profilesFile{Version: SchemaVersion, Profiles: profiles})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal profiles: %w", err)
+	}
+	v, err := newVault(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	b.Salt, b.Time, b.Memory, b.Threads = v.Salt, v.Time, v.Memory, v.Threads
+	if b.Sealed, err = seal(v.deriveKey(passphrase), plain, v.Salt); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(b, "", "  ")
+}
+
+// bundleProfiles returns copies of the profiles to export, without their
+// store-specific fields. Must be called with s.mu held.
+func (s *Store) bundleProfiles(ids []string) ([]*Profile, error) {
+	if err := s.checkUnlocked(); err != nil {
+		return nil, err
+	}
+
+	var selected []*Profile
+	if len(ids) == 0 {
+		selected = s.profiles
+	}
+	for _, id := range ids {
+		found := false
+		for _, p := range s.profiles {
+			if p.ID == id {
+				selected = append(selected, p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("profile %q not found", id)
+		}
+	}
+
+	result := make([]*Profile, 0, len(selected))
+	for _, p := range selected {
+		r, err := s.resolve(p)
+		if err != nil {
+			return nil, err
+		}
+		cp := *r
+		cp.Parent, cp.Own = "", nil
+		cp.Secrets = ""
+		cp.SubscriptionID, cp.SubscriptionRef, cp.Overrides = "", "", nil
+		cp.Verification = ""
+		result = append(result, &cp)
+	}
+	return result, nil
+}
+
+// ImportBundle imports the profiles in data, which is either a bundle from
+// ExportBundle or plain text with one paqet:// link per line (blank lines
+// and lines starting with # are ignored). The passphrase opens a protected
+// bundle and encrypted links; without one, a protected bundle fails with
+// ErrPassphraseRequired. Entries that cannot be read or are invalid are
+// reported in the result; the rest are imported together.
+func (s *Store) ImportBundle(data []byte, passphrase string, dup Duplicates) (*ImportResult, error) {
+	switch dup {
+	case MergeDuplicates, SkipDuplicates, KeepDuplicates:
+	case "":
+		dup = SkipDuplicates
+	default:
+		return nil, fmt.Errorf("invalid duplicate handling %q", dup)
+	}
+
+	res := &ImportResult{
+		Added:   make([]*Profile, 0),
+		Merged:  make([]*Profile, 0),
+		Skipped: make([]string, 0),
+		Errors:  make([]string, 0),
+	}
+	var incoming []*Profile
+	var err error
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{")) {
+		incoming, err = openBundle(trimmed, passphrase)
+	} else {
+		incoming, err = parseLinks(trimmed, passphrase, res)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(incoming) == 0 && len(res.Errors) == 0 {
+		return nil, fmt.Errorf("no profiles found")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkUnlocked(); err != nil {
+		return nil, err
+	}
+	// Work on s.profiles so that inherit sees profiles merged earlier in the
+	// import
+	old := s.profiles
+	s.profiles = append([]*Profile(nil), old...)
+	for _, in := range incoming {
+		if err := in.Validate(); err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", in.Name, err))
+			continue
+		}
+		cp := *in
+		cp.Secrets = ""
+		cp.SubscriptionID, cp.SubscriptionRef, cp.Overrides = "", "", nil
+		cp.Parent, cp.Own = "", nil
+		cp.LastEndpoint = ""
+		cp.Group, cp.Tags = strings.TrimSpace(cp.Group), normalizeTags(cp.Tags)
+		cp.Verification = Unverified
+		if cp.Signature != "" {
+			cp.Verification = s.verification(uriFromProfile(&cp))
+		}
+
+		i := s.findDuplicate(s.profiles, &cp)
+		switch {
+		case i >= 0 && dup == SkipDuplicates:
+			res.Skipped = append(res.Skipped, cp.Name)
+		case i >= 0 && dup == MergeDuplicates:
+			merged := mergeImport(s.profiles[i], &cp)
+			if err := s.inherit(merged); err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", in.Name, err))
+				continue
+			}
+			s.profiles[i] = merged
+			res.Merged = append(res.Merged, merged)
+		default:
+			cp.ID = uuid.New().String()
+			s.profiles = append(s.profiles, &cp)
+			res.Added = append(res.Added, &cp)
+		}
+	}
+	if len(res.Added) == 0 && len(res.Merged) == 0 {
+		s.profiles = old
+		return res, nil
+	}
+
+	if err := s.save(); err != nil {
+		s.profiles = old // Roll back
+		return nil, err
+	}
+
+	// Return copies, like Create
+	for i, p := range res.Added {
+		cp := *p
+		res.Added[i] = &cp
+	}
+	for i, p := range res.Merged {
+		cp := *s.resolved(p)
+		res.Merged[i] = &cp
+	}
+	return res, nil
+}
+
+// openBundle decodes a bundle, decrypting it if it is protected.
+func openBundle(data []byte, passphrase string) ([]*Profile, error) {
+	var b bundleFile
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle: %w", err)
+	}
+	if b.Format != bundleFormat {
+		return nil, fmt.Errorf("not a profile bundle")
+	}
+	if b.Sealed == nil {
+		profiles, _, err := decodeProfiles(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bundle: %w", err)
+		}
+		return profiles, nil
+	}
+
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	v := &vaultFile{Salt: b.Salt, Time: b.Time, Memory: b.Memory, Threads: b.Threads}
+	if err := v.checkKDF(); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	plain, err := unseal(v.deriveKey(passphrase), b.Sealed, b.Salt)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	profiles, _, err := decodeProfiles(plain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse bundle: %w", err)
+	}
+	return profiles, nil
+}
+
+// parseLinks reads one paqet:// link per line. Lines that fail are added to
+// res.Errors.
+func parseLinks(data []byte, passphrase string, res *ImportResult) ([]*Profile, error) {
+	var profiles []*Profile
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var u *uri.PaqetURI
+		var err error
+		if uri.IsEncrypted(line) {
+			u, err = uri.Decrypt(line, passphrase)
+		} else {
+			u, err = uri.Parse(line)
+		}
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("line %d: %v", n, err))
+			continue
+		}
+		p := profileFromURI(u)
+		p.Verification = Unverified
+		profiles = append(profiles, p)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read links: %w", err)
+	}
+	return profiles, nil
+}
+
+// findDuplicate returns the index of the profile with the same host, port
+// and key as p, or -1. Profiles are compared with their inherited settings
+// filled in. Must be called with s.mu held.
+func (s *Store) findDuplicate(profiles []*Profile, p *Profile) int {
+	for i, existing := range profiles {
+		existing = s.resolved(existing)
+		if strings.EqualFold(existing.Host, p.Host) && existing.Port == p.Port && existing.Key == p.Key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mergeImport returns the existing profile updated with the imported
+// settings. It keeps the existing ID, parent and subscription link, records
+// edited fields of synced profiles as overrides, like Update, and combines
+// the organization of both. The endpoint last connected through is kept while
+// the import still lists it. A profile with a parent must then go through
+// inherit.
+func mergeImport(existing, in *Profile) *Profile {
+	cp := *in
+	cp.ID, cp.Parent = existing.ID, existing.Parent
+	if cp.Group == "" {
+		cp.Group = existing.Group
+	}
+	cp.Tags = normalizeTags(append(append([]string(nil), existing.Tags...), in.Tags...))
+	cp.Favorite = existing.Favorite || in.Favorite
+	keepLastEndpoint(&cp, existing.LastEndpoint)
+	if existing.SubscriptionID != "" {
+		cp.SubscriptionID = existing.SubscriptionID
+		cp.SubscriptionRef = existing.SubscriptionRef
+		cp.Overrides = mergeOverrides(existing.Overrides, changedFields(existing, &cp))
+	}
+	return &cp
+}
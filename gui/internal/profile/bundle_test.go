@@ -0,0 +1,302 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/omid3098/autopaqet/gui/internal/config"
+	"github.com/omid3098/autopaqet/gui/internal/uri"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	src := tempStore(t)
+	home, err := src.Create(&Profile{
+		Name: "Home", Host: "1.2.3.4", Port: 8080, Key: "home-key",
+		Fallbacks: []string{"1.2.3.5:8080"}, SocksUser: "alice", SocksPass: "hunter2",
+		Forward: []config.ForwardRule{{Listen: "127.0.0.1:8080", Target: "internal:80"}},
+		Group:   "Personal", Tags: []string{"fast"}, Favorite: true,
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := src.Create(&Profile{Name: "Work", Host: "work.example.com", Port: 443, Key: "work-key"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	for _, passphrase := range []string{"", "moving day"} {
+		t.Run(fmt.Sprintf("passphrase=%q", passphrase), func(t *testing.T) {
+			data, err := src.ExportBundle(nil, passphrase)
+			if err != nil {
+				t.Fatalf("ExportBundle failed: %v", err)
+			}
+			if passphrase != "" && bytes.Contains(data, []byte("home-key")) {
+				t.Error("protected bundle contains a key in plain text")
+			}
+
+			dst := tempStore(t)
+			res, err := dst.ImportBundle(data, passphrase, SkipDuplicates)
+			if err != nil {
+				t.Fatalf("ImportBundle failed: %v", err)
+			}
+			if len(res.Added) != 2 || len(res.Errors) != 0 {
+				t.Fatalf("result = %+v, want 2 added", res)
+			}
+			got := dst.List()[0]
+			if got.ID == home.ID {
+				t.Error("imported profile kept the exported ID")
+			}
+			want := *home
+			want.ID = got.ID
+			want.Verification = Unverified // imported, like a link
+			if fmt.Sprintf("%+v", *got) != fmt.Sprintf("%+v", want) {
+				t.Errorf("imported = %+v\nwant %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestImportProtectedBundle(t *testing.T) {
+	src := tempStore(t)
+	if _, err := src.Create(&Profile{Name: "Home", Host: "1.2.3.4", Port: 8080, Key: "k"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	data, err := src.ExportBundle(nil, "right")
+	if err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+
+	dst := tempStore(t)
+	if _, err := dst.ImportBundle(data, "", SkipDuplicates); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("without passphrase: err = %v, want ErrPassphraseRequired", err)
+	}
+	if _, err := dst.ImportBundle(data, "wrong", SkipDuplicates); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("wrong passphrase: err = %v, want ErrWrongPassphrase", err)
+	}
+	if len(dst.List()) != 0 {
+		t.Error("failed imports added profiles")
+	}
+}
+
+func TestImportBundleChecksKDFParameters(t *testing.T) {
+	src := tempStore(t)
+	if _, err := src.Create(&Profile{Name: "Home", Host: "1.2.3.4", Port: 8080, Key: "k"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	data, err := src.ExportBundle(nil, "right")
+	if err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(b *bundleFile)
+	}{
+		{"no parameters", func(b *bundleFile) { *b = bundleFile{Format: bundleFormat, Sealed: b.Sealed} }},
+		{"zero time", func(b *bundleFile) { b.Time = 0 }},
+		{"zero threads", func(b *bundleFile) { b.Threads = 0 }},
+		{"short salt", func(b *bundleFile) { b.Salt = b.Salt[:4] }},
+		{"huge memory", func(b *bundleFile) { b.Memory = 1 << 31 }},
+		{"huge time", func(b *bundleFile) { b.Time = 1 << 30 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bundleFile
+			if err := json.Unmarshal(data, &b); err != nil {
+				t.Fatal(err)
+			}
+			tt.modify(&b)
+			crafted, err := json.Marshal(&b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tempStore(t).ImportBundle(crafted, "right", SkipDuplicates); err == nil || !strings.Contains(err.Error(), "invalid bundle") {
+				t.Errorf("err = %v, want an invalid bundle error", err)
+			}
+		})
+	}
+}
+
+func TestImportBundleIgnoresClaimedVerification(t *testing.T) {
+	data := []byte(`{"format": "autopaqet-profiles", "version": 1, "profiles": [
+		{"name": "Fake", "host": "1.2.3.4", "port": 8080, "key": "k", "verification": "verified"}]}`)
+
+	s := tempStore(t)
+	res, err := s.ImportBundle(data, "", SkipDuplicates)
+	if err != nil {
+		t.Fatalf("ImportBundle failed: %v", err)
+	}
+	if len(res.Added) != 1 || res.Added[0].Verification != Unverified {
+		t.Errorf("result = %+v, want 1 unverified profile", res)
+	}
+}
+
+func TestExportBundleSelected(t *testing.T) {
+	s := tempStore(t)
+	a, _ := s.Create(&Profile{Name: "A", Host: "1.1.1.1", Port: 1, Key: "a"})
+	s.Create(&Profile{Name: "B", Host: "2.2.2.2", Port: 2, Key: "b"})
+
+	data, err := s.ExportBundle([]string{a.ID}, "")
+	if err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+	profiles, _, err := decodeProfiles(data)
+	if err != nil || len(profiles) != 1 || profiles[0].Name != "A" {
+		t.Errorf("bundle holds %d profiles, err = %v; want only A", len(profiles), err)
+	}
+
+	if _, err := s.ExportBundle([]string{"missing"}, ""); err == nil {
+		t.Error("exporting an unknown profile should fail")
+	}
+}
+
+func TestImportDuplicates(t *testing.T) {
+	bundle := func(t *testing.T) []byte {
+		src := tempStore(t)
+		src.Create(&Profile{Name: "Renamed", Host: "1.2.3.4", Port: 8080, Key: "k", Mode: "fast3", Tags: []string{"new"}})
+		src.Create(&Profile{Name: "Other", Host: "5.6.7.8", Port: 8080, Key: "k"})
+		data, err := src.ExportBundle(nil, "")
+		if err != nil {
+			t.Fatalf("ExportBundle failed: %v", err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		dup                    Duplicates
+		added, merged, skipped int
+		names                  []string
+	}{
+		{SkipDuplicates, 1, 0, 1, []string{"Home", "Other"}},
+		{MergeDuplicates, 1, 1, 0, []string{"Renamed", "Other"}},
+		{KeepDuplicates, 2, 0, 0, []string{"Home", "Renamed", "Other"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.dup), func(t *testing.T) {
+			s := tempStore(t)
+			home, _ := s.Create(&Profile{Name: "Home", Host: "1.2.3.4", Port: 8080, Key: "k", Group: "Personal", Tags: []string{"old"}})
+
+			res, err := s.ImportBundle(bundle(t), "", tt.dup)
+			if err != nil {
+				t.Fatalf("ImportBundle failed: %v", err)
+			}
+			if len(res.Added) != tt.added || len(res.Merged) != tt.merged || len(res.Skipped) != tt.skipped {
+				t.Errorf("result = %d added, %d merged, %d skipped; want %d, %d, %d",
+					len(res.Added), len(res.Merged), len(res.Skipped), tt.added, tt.merged, tt.skipped)
+			}
+			if got := names(s.List()); strings.Join(got, ",") != strings.Join(tt.names, ",") {
+				t.Errorf("profiles = %v, want %v", got, tt.names)
+			}
+
+			if tt.dup == MergeDuplicates {
+				merged, err := s.Get(home.ID)
+				if err != nil {
+					t.Fatalf("merged profile lost its ID: %v", err)
+				}
+				if merged.Mode != "fast3" || merged.Group != "Personal" || strings.Join(merged.Tags, ",") != "old,new" {
+					t.Errorf("merged = %+v, want imported settings with both tags and the local group", merged)
+				}
+			}
+		})
+	}
+
+	// A profile that inherits its port and key is still a duplicate
+	s := tempStore(t)
+	base, _ := s.Create(&Profile{Name: "Base", Host: "9.9.9.9", Port: 8080, Key: "k"})
+	home, err := s.Create(&Profile{Name: "Home", Host: "1.2.3.4", Port: 8080, Key: "k", Parent: base.ID})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	res, err := s.ImportBundle(bundle(t), "", SkipDuplicates)
+	if err != nil {
+		t.Fatalf("ImportBundle failed: %v", err)
+	}
+	if strings.Join(res.Skipped, ",") != "Renamed" {
+		t.Errorf("skipped = %v, want [Renamed]", res.Skipped)
+	}
+
+	// Merging into it keeps the parent and inherits what still matches
+	res, err = s.ImportBundle(bundle(t), "", MergeDuplicates)
+	if err != nil {
+		t.Fatalf("ImportBundle failed: %v", err)
+	}
+	var merged *Profile
+	for _, p := range res.Merged {
+		if p.ID == home.ID {
+			merged = p
+		}
+	}
+	if merged == nil || merged.Parent != base.ID || merged.Port != 8080 {
+		t.Fatalf("merged = %+v, want the effective profile under Base", merged)
+	}
+	if got := strings.Join(merged.Own, ","); got != "host,mode" {
+		t.Errorf("Own = %q, want host,mode", got)
+	}
+	if stored := s.find(home.ID); stored.Port != 0 || stored.Key != "" || stored.Mode != "fast3" {
+		t.Errorf("stored = %+v, want only its own fields", stored)
+	}
+
+	if _, err := s.ImportBundle(bundle(t), "", "replace"); err == nil {
+		t.Error("unknown duplicate handling should fail")
+	}
+}
+
+func TestImportLinks(t *testing.T) {
+	enc, err := uri.Encrypt(&uri.PaqetURI{Key: "k2", Host: "5.6.7.8", Port: 443, Name: "Secret"}, "pass")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	text := strings.Join([]string{
+		"# exported links",
+		"paqet://k1@1.2.3.4:8080#Plain",
+		"",
+		"https://example.com/not-a-link",
+		enc,
+	}, "\n")
+
+	s := tempStore(t)
+	res, err := s.ImportBundle([]byte(text), "pass", SkipDuplicates)
+	if err != nil {
+		t.Fatalf("ImportBundle failed: %v", err)
+	}
+	if got := names(s.List()); strings.Join(got, ",") != "Plain,Secret" {
+		t.Errorf("profiles = %v, want [Plain Secret]", got)
+	}
+	if len(res.Errors) != 1 || !strings.HasPrefix(res.Errors[0], "line 4:") {
+		t.Errorf("errors = %v, want one for line 4", res.Errors)
+	}
+	if s.List()[0].Verification != Unverified {
+		t.Errorf("Verification = %q, want %q", s.List()[0].Verification, Unverified)
+	}
+
+	// Without the passphrase only the encrypted link fails
+	s = tempStore(t)
+	res, err = s.ImportBundle([]byte(text), "", SkipDuplicates)
+	if err != nil {
+		t.Fatalf("ImportBundle failed: %v", err)
+	}
+	if len(res.Added) != 1 || len(res.Errors) != 2 {
+		t.Errorf("result = %+v, want 1 added and 2 errors", res)
+	}
+
+	if _, err := s.ImportBundle([]byte("# nothing here\n"), "", SkipDuplicates); err == nil {
+		t.Error("importing an empty file should fail")
+	}
+}
+
+func TestBundleLocked(t *testing.T) {
+	dir, _ := encryptedStore(t)
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if _, err := s.ExportBundle(nil, ""); !errors.Is(err, ErrLocked) {
+		t.Errorf("ExportBundle err = %v, want ErrLocked", err)
+	}
+	if _, err := s.ImportBundle([]byte("paqet://k@1.2.3.4:8080#A"), "", SkipDuplicates); !errors.Is(err, ErrLocked) {
+		t.Errorf("ImportBundle err = %v, want ErrLocked", err)
+	}
+}
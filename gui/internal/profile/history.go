@@ -0,0 +1,215 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+)
+
+// maxAttempts is how many recent connection attempts each history keeps.
+const maxAttempts = 20
+
+// Attempt is one connection attempt of a profile.
+type Attempt struct {
+	Time     time.Time `json:"time"`
+	Success  bool      `json:"success"`
+	Endpoint string    `json:"endpoint,omitempty"` // the one that connected
+	Summary  string    `json:"summary,omitempty"`  // diagnostic summary of a failure
+	ReadyMs  int64     `json:"ready_ms,omitempty"` // until the SOCKS5 proxy was ready
+}
+
+// History records how a profile behaved across connections. It is kept in
+// history.json, apart from the profile, so edits and syncs never reset it.
+type History struct {
+	Attempts      int       `json:"attempts"`
+	Successes     int       `json:"successes"`
+	Failures      int       `json:"failures"`
+	LastConnected time.Time `json:"last_connected,omitempty"`
+	LastFailure   string    `json:"last_failure,omitempty"`
+	ReadyTotalMs  int64     `json:"ready_total_ms"`   // summed over successes
+	ConnectedSecs int64     `json:"connected_secs"`   // total session time
+	Recent        []Attempt `json:"recent,omitempty"` // newest last
+
+	// Derived, filled in when the history is returned.
+	AvgReadyMs  int64   `json:"avg_ready_ms,omitempty"`
+	Reliability float64 `json:"reliability,omitempty"`
+}
+
+// reliability estimates the chance the next attempt succeeds. It is the
+// success rate with one success and one failure added, so profiles with few
+// attempts rank near the middle instead of at either end.
+func (h *History) reliability() float64 {
+	return float64(h.Successes+1) / float64(h.Attempts+2)
+}
+
+// withDerived returns a copy with the derived fields filled in.
+func (h *History) withDerived() *History {
+	cp := *h
+	cp.Recent = append([]Attempt(nil), h.Recent...)
+	if h.Successes > 0 {
+		cp.AvgReadyMs = h.ReadyTotalMs / int64(h.Successes)
+	}
+	cp.Reliability = h.reliability()
+	return &cp
+}
+
+// RecordAttempt adds a connection attempt to a profile's history.
+func (s *Store) RecordAttempt(id string, a Attempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.exists(id) {
+		return fmt.Errorf("profile %q not found", id)
+	}
+	h, existed := s.history[id]
+	if !existed {
+		h = &History{}
+		s.history[id] = h
+	}
+	old := *h
+
+	h.Attempts++
+	if a.Success {
+		h.Successes++
+		h.LastConnected = a.Time
+		h.ReadyTotalMs += a.ReadyMs
+	} else {
+		h.Failures++
+		h.LastFailure = a.Summary
+	}
+	h.Recent = append(h.Recent, a)
+	if len(h.Recent) > maxAttempts {
+		h.Recent = append([]Attempt(nil), h.Recent[len(h.Recent)-maxAttempts:]...)
+	}
+
+	if err := s.saveHistory(); err != nil {
+		// Roll back
+		if existed {
+			*h = old
+		} else {
+			delete(s.history, id)
+		}
+		return err
+	}
+	return nil
+}
+
+// RecordSession adds the duration of a finished connection to a profile's
+// total connected time.
+func (s *Store) RecordSession(id string, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := s.history[id]
+	if h == nil {
+		return fmt.Errorf("no history for profile %q", id)
+	}
+	h.ConnectedSecs += int64(d / time.Second)
+	if err := s.saveHistory(); err != nil {
+		h.ConnectedSecs -= int64(d / time.Second) // Roll back
+		return err
+	}
+	return nil
+}
+
+// History returns a profile's history, empty if it was never connected.
+func (s *Store) History(id string) *History {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if h := s.history[id]; h != nil {
+		return h.withDerived()
+	}
+	return (&History{}).withDerived()
+}
+
+// Histories returns the history of every profile that has one, by profile ID.
+func (s *Store) Histories() map[string]*History {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]*History, len(s.history))
+	for id, h := range s.history {
+		if s.exists(id) {
+			result[id] = h.withDerived()
+		}
+	}
+	return result
+}
+
+// ListByReliability returns the profiles most likely to connect first. Ties
+// go to the most recently connected, then to the stored order.
+func (s *Store) ListByReliability() []*Profile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	empty := &History{}
+	history := func(p *Profile) *History {
+		if h := s.history[p.ID]; h != nil {
+			return h
+		}
+		return empty
+	}
+
+	result := make([]*Profile, len(s.profiles))
+	for i, p := range s.profiles {
+		cp := *s.resolved(p)
+		result[i] = &cp
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		hi, hj := history(result[i]), history(result[j])
+		if ri, rj := hi.reliability(), hj.reliability(); ri != rj {
+			return ri > rj
+		}
+		return hi.LastConnected.After(hj.LastConnected)
+	})
+	return result
+}
+
+// exists reports whether a profile ID is in the store. Must be called with
+// s.mu held.
+func (s *Store) exists(id string) bool {
+	for _, p := range s.profiles {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) loadHistory() error {
+	s.history = make(map[string]*History)
+	data, err := os.ReadFile(s.historyPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.history); err != nil {
+		s.history = make(map[string]*History)
+		return s.setAside(s.historyPath, fmt.Errorf("failed to parse history: %w", err))
+	}
+	return nil
+}
+
+// saveHistory writes history.json, dropping deleted profiles. Must be called
+// with s.mu held.
+func (s *Store) saveHistory() error {
+	for id := range s.history {
+		if !s.exists(id) {
+			delete(s.history, id)
+		}
+	}
+	data, err := json.MarshalIndent(s.history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+
+	return writeFileAtomic(s.historyPath, data, 0644)
+}
@@ -0,0 +1,128 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAttempts(t *testing.T) {
+	s, ids := organizedStore(t)
+	id := ids["A"]
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if h := s.History(id); h.Attempts != 0 || h.Reliability != 0.5 {
+		t.Errorf("new history = %+v", h)
+	}
+
+	attempts := []Attempt{
+		{Time: now, Success: true, Endpoint: "a.example.com:1", ReadyMs: 1000},
+		{Time: now.Add(time.Hour), Success: false, Summary: "server unreachable"},
+		{Time: now.Add(2 * time.Hour), Success: true, Endpoint: "a.example.com:1", ReadyMs: 3000},
+	}
+	for _, a := range attempts {
+		if err := s.RecordAttempt(id, a); err != nil {
+			t.Fatalf("RecordAttempt failed: %v", err)
+		}
+	}
+	if err := s.RecordSession(id, 90*time.Second); err != nil {
+		t.Fatalf("RecordSession failed: %v", err)
+	}
+
+	h := s.History(id)
+	if h.Attempts != 3 || h.Successes != 2 || h.Failures != 1 {
+		t.Errorf("counts = %d/%d/%d, want 3/2/1", h.Attempts, h.Successes, h.Failures)
+	}
+	if h.AvgReadyMs != 2000 || h.ConnectedSecs != 90 || h.LastFailure != "server unreachable" {
+		t.Errorf("history = %+v", h)
+	}
+	if !h.LastConnected.Equal(now.Add(2 * time.Hour)) {
+		t.Errorf("LastConnected = %v", h.LastConnected)
+	}
+	if h.Reliability != 0.6 {
+		t.Errorf("Reliability = %v, want 0.6", h.Reliability)
+	}
+
+	// Persisted, and only for existing profiles
+	s2, err := NewStore(s.dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if got := s2.History(id); !reflect.DeepEqual(got, h) {
+		t.Errorf("reloaded history = %+v, want %+v", got, h)
+	}
+	if err := s.RecordAttempt("missing", Attempt{Time: now}); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+	if err := s.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Histories()[id]; ok {
+		t.Error("deleted profile still has history")
+	}
+}
+
+func TestUnreadableHistoryFile(t *testing.T) {
+	s, ids := organizedStore(t)
+	path := filepath.Join(s.dir, "history.json")
+	if err := os.WriteFile(path, []byte(`{"x": {"attem`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s2, err := NewStore(s.dir)
+	if err != nil {
+		t.Fatalf("NewStore should start without history, got: %v", err)
+	}
+	if h := s2.History(ids["A"]); h.Attempts != 0 {
+		t.Errorf("history = %+v, want empty", h)
+	}
+	resets := s2.Resets()
+	if len(resets) != 1 || resets[0].File != "history.json" || !strings.Contains(resets[0].Reason, "failed to parse history") {
+		t.Fatalf("Resets = %+v", resets)
+	}
+
+	// Recording again must not overwrite the kept copy.
+	if err := s2.RecordAttempt(ids["A"], Attempt{Time: time.Now(), Success: true}); err != nil {
+		t.Fatalf("RecordAttempt failed: %v", err)
+	}
+	if data, err := os.ReadFile(resets[0].CorruptPath); err != nil || string(data) != `{"x": {"attem` {
+		t.Errorf("unreadable file not kept at %s: %v", resets[0].CorruptPath, err)
+	}
+}
+
+func TestRecentAttemptsAreCapped(t *testing.T) {
+	s, ids := organizedStore(t)
+	for i := 0; i < maxAttempts+5; i++ {
+		if err := s.RecordAttempt(ids["A"], Attempt{Time: time.Unix(int64(i), 0), Summary: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := s.History(ids["A"])
+	if len(h.Recent) != maxAttempts || h.Recent[0].Summary != "5" || h.Attempts != maxAttempts+5 {
+		t.Errorf("recent = %d entries from %q, attempts %d", len(h.Recent), h.Recent[0].Summary, h.Attempts)
+	}
+}
+
+func TestListByReliability(t *testing.T) {
+	s, ids := organizedStore(t)
+	now := time.Now()
+	record := func(name string, results ...bool) {
+		for i, ok := range results {
+			if err := s.RecordAttempt(ids[name], Attempt{Time: now.Add(time.Duration(i) * time.Minute), Success: ok}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	record("A", false, false)      // 0.25
+	record("B", true, true, false) // 0.6
+	record("D", true, false)       // 0.5, connected more recently than C
+	// C was never tried: 0.5
+
+	if got, want := names(s.ListByReliability()), []string{"B", "D", "C", "A"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListByReliability = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,262 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// A profile with a Parent inherits the parent's settings. It stores only the
+// fields it overrides, listed by JSON name in Own; the others are kept zero
+// in profiles.json and filled in from its ancestors by Get and List.
+// Profiles are created and updated with their effective values, and a field
+// that equals the parent's value is inherited again.
+
+// ErrHasChildren is returned when deleting a profile that other profiles
+// inherit from. DeleteAndFlatten deletes it anyway.
+var ErrHasChildren = errors.New("other profiles inherit from this profile")
+
+// inheritedFields are the profileFields a child can inherit: all but the
+// name.
+var inheritedFields = func() []string {
+	var names []string
+	for name := range profileFields {
+		if name != "name" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}()
+
+// find returns the stored profile with the given ID, or nil. Must be called
+// with s.mu held.
+func (s *Store) find(id string) *Profile {
+	for _, p := range s.profiles {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// resolve returns p with the fields it inherits filled in from its
+// ancestors, or p itself if it has no parent. It fails if an ancestor is
+// missing or the parents form a cycle, which only a hand-edited
+// profiles.json can cause. Must be called with s.mu held.
+func (s *Store) resolve(p *Profile) (*Profile, error) {
+	if p.Parent == "" {
+		return p, nil
+	}
+
+	chain := []*Profile{p}
+	seen := map[string]bool{p.ID: true}
+	for cur := p; cur.Parent != ""; {
+		parent := s.find(cur.Parent)
+		if parent == nil {
+			return nil, fmt.Errorf("profile %q: parent %q not found", p.Name, cur.Parent)
+		}
+		if seen[parent.ID] {
+			return nil, fmt.Errorf("profile %q: inheritance cycle through %q", p.Name, parent.Name)
+		}
+		seen[parent.ID] = true
+		chain = append(chain, parent)
+		cur = parent
+	}
+
+	// Apply from the root down
+	res := *chain[len(chain)-1]
+	for i := len(chain) - 2; i >= 0; i-- {
+		child := *chain[i]
+		copyFields(&child, &res, inherits(chain[i]))
+		res = child
+	}
+	return &res, nil
+}
+
+// resolved is resolve for listings: a profile that cannot be resolved is
+// returned as stored. Must be called with s.mu held.
+func (s *Store) resolved(p *Profile) *Profile {
+	if r, err := s.resolve(p); err == nil {
+		return r
+	}
+	return p
+}
+
+// inherits returns the fields p takes from its parent.
+func inherits(p *Profile) []string {
+	own := make(map[string]bool, len(p.Own))
+	for _, name := range p.Own {
+		own[name] = true
+	}
+	var names []string
+	for _, name := range inheritedFields {
+		if !own[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// inherit prepares p, holding effective values, to be stored under its
+// parent: it records the fields that differ from the parent's in p.Own and
+// clears the rest. Profiles without a parent are stored whole. Must be
+// called with s.mu held.
+func (s *Store) inherit(p *Profile) error {
+	if p.Parent == "" {
+		p.Own = nil
+		return nil
+	}
+	if p.SubscriptionID != "" {
+		return fmt.Errorf("profiles synced from a subscription cannot inherit from another profile")
+	}
+
+	parent := s.find(p.Parent)
+	if parent == nil {
+		return fmt.Errorf("parent profile %q not found", p.Parent)
+	}
+	seen := make(map[string]bool)
+	for cur := parent; cur != nil && !seen[cur.ID]; cur = s.find(cur.Parent) {
+		if cur.ID == p.ID {
+			return fmt.Errorf("profile %q cannot inherit from %q: that would create a cycle", p.Name, parent.Name)
+		}
+		seen[cur.ID] = true
+	}
+	base, err := s.resolve(parent)
+	if err != nil {
+		return err
+	}
+
+	vp, vb := reflect.ValueOf(p).Elem(), reflect.ValueOf(base).Elem()
+	p.Own = nil
+	for _, name := range inheritedFields {
+		f := vp.Field(profileFields[name])
+		if sameValue(f, vb.Field(profileFields[name])) {
+			f.SetZero()
+			continue
+		}
+		p.Own = append(p.Own, name)
+	}
+	return nil
+}
+
+// sameValue compares field values, treating nil and empty slices as equal
+// like their JSON form does.
+func sameValue(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// Children returns the profiles that inherit directly from a profile.
+func (s *Store) Children(id string) []*Profile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*Profile, 0)
+	for _, p := range s.profiles {
+		if p.Parent == id {
+			result = append(result, s.resolved(p))
+		}
+	}
+	return result
+}
+
+// InheritsFrom reports whether profile id inherits, directly or not, from
+// ancestor.
+func (s *Store) InheritsFrom(id, ancestor string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for p := s.find(id); p != nil && p.Parent != "" && !seen[p.ID]; p = s.find(p.Parent) {
+		if p.Parent == ancestor {
+			return true
+		}
+		seen[p.ID] = true
+	}
+	return false
+}
+
+// DeleteAndFlatten deletes a profile and copies the settings its children
+// inherited from it into them, so they keep working unchanged. Children
+// then inherit from the deleted profile's parent, if it had one.
+func (s *Store) DeleteAndFlatten(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.find(id) == nil {
+		return fmt.Errorf("profile %q not found", id)
+	}
+
+	profiles := make([]*Profile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		if p.ID != id {
+			profiles = append(profiles, p)
+		}
+	}
+	profiles, err := s.flatten(profiles)
+	if err != nil {
+		return err
+	}
+
+	old := s.profiles
+	s.profiles = profiles
+	if err := s.save(); err != nil {
+		s.profiles = old // Roll back
+		return err
+	}
+	return nil
+}
+
+// flatten returns profiles, the store's profiles with some removed, with
+// the profiles whose parent was removed replaced by copies that keep their
+// effective settings and inherit from their nearest remaining ancestor.
+// Secrets are copied too, so the store must be unlocked if any profile is
+// replaced. Must be called with s.mu held, before s.profiles is replaced.
+func (s *Store) flatten(profiles []*Profile) ([]*Profile, error) {
+	kept := byID(profiles)
+	result := make([]*Profile, len(profiles))
+	var orphans []*Profile
+	for i, p := range profiles {
+		result[i] = p
+		if p.Parent == "" || kept[p.Parent] != nil {
+			continue
+		}
+		if err := s.checkUnlocked(); err != nil {
+			return nil, err
+		}
+		eff, err := s.resolve(p)
+		if err != nil {
+			return nil, err
+		}
+		cp := *eff
+		cp.Parent = ""
+		for a := s.find(p.Parent); a != nil; a = s.find(a.Parent) {
+			if kept[a.ID] != nil {
+				cp.Parent = a.ID
+				break
+			}
+		}
+		// Own every field until inherit runs, so orphans still to be
+		// processed resolve correctly through this one.
+		cp.Own = inheritedFields
+		result[i] = &cp
+		orphans = append(orphans, &cp)
+	}
+	if len(orphans) == 0 {
+		return result, nil
+	}
+
+	old := s.profiles
+	s.profiles = result
+	defer func() { s.profiles = old }()
+	for _, p := range orphans {
+		if err := s.inherit(p); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,220 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// inheritingStore returns a store with a base profile and a child that
+// overrides its name and mode.
+func inheritingStore(t *testing.T) (*Store, *Profile, *Profile) {
+	t.Helper()
+	s := tempStore(t)
+	base, err := s.Create(&Profile{Name: "Base", Host: "1.2.3.4", Port: 8080, Key: "secret", Mode: "fast", MTU: 1350})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	eff := *base
+	eff.ID, eff.Name, eff.Mode, eff.Parent = "", "Child", "fast3", base.ID
+	child, err := s.Create(&eff)
+	if err != nil {
+		t.Fatalf("Create child failed: %v", err)
+	}
+	return s, base, child
+}
+
+func TestCreateInheritingProfile(t *testing.T) {
+	s, base, child := inheritingStore(t)
+
+	if child.Host != "1.2.3.4" || child.Key != "secret" || child.Mode != "fast3" || child.Parent != base.ID {
+		t.Errorf("Create returned %+v, want the effective profile", child)
+	}
+	if got := strings.Join(child.Own, ","); got != "mode" {
+		t.Errorf("Own = %q, want mode", got)
+	}
+	if stored := s.find(child.ID); stored.Host != "" || stored.MTU != 0 || stored.Mode != "fast3" {
+		t.Errorf("stored child = %+v, want only its own fields", stored)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, "profiles.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), `"1.2.3.4"`); n != 1 {
+		t.Errorf("host saved %d times, want once", n)
+	}
+
+	reopened, err := NewStore(s.dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	got, err := reopened.Get(child.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Host != "1.2.3.4" || got.Port != 8080 || got.MTU != 1350 || got.Mode != "fast3" {
+		t.Errorf("reopened child = %+v", got)
+	}
+}
+
+func TestParentChangesReachChildren(t *testing.T) {
+	s, base, child := inheritingStore(t)
+
+	base.Host, base.Mode = "5.6.7.8", "normal"
+	if _, err := s.Update(base); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	got, _ := s.Get(child.ID)
+	if got.Host != "5.6.7.8" {
+		t.Errorf("Host = %q, want the parent's new host", got.Host)
+	}
+	if got.Mode != "fast3" {
+		t.Errorf("Mode = %q, want the child's own mode", got.Mode)
+	}
+	if listed := names(s.List()); strings.Join(listed, ",") != "Base,Child" {
+		t.Errorf("List = %v", listed)
+	}
+	for _, p := range s.List() {
+		if p.Host != "5.6.7.8" {
+			t.Errorf("listed %s with host %q", p.Name, p.Host)
+		}
+	}
+}
+
+func TestSettingParentValueInheritsAgain(t *testing.T) {
+	s, base, child := inheritingStore(t)
+
+	child.Mode = base.Mode
+	updated, err := s.Update(child)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if len(updated.Own) != 0 {
+		t.Errorf("Own = %v, want nothing", updated.Own)
+	}
+
+	base.Mode = "normal"
+	if _, err := s.Update(base); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got, _ := s.Get(child.ID); got.Mode != "normal" {
+		t.Errorf("Mode = %q, want the parent's", got.Mode)
+	}
+
+	// Detaching keeps the effective settings
+	detached, _ := s.Get(child.ID)
+	detached.Parent = ""
+	if _, err := s.Update(detached); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if stored := s.find(child.ID); stored.Host != "1.2.3.4" || stored.Mode != "normal" || stored.Own != nil {
+		t.Errorf("detached child = %+v", stored)
+	}
+}
+
+func TestInheritanceCycles(t *testing.T) {
+	s, base, child := inheritingStore(t)
+
+	base.Parent = child.ID
+	if _, err := s.Update(base); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Update = %v, want a cycle error", err)
+	}
+	child.Parent = child.ID
+	if _, err := s.Update(child); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Update = %v, want a cycle error", err)
+	}
+	if _, err := s.Create(&Profile{Name: "Orphan", Host: "1.2.3.4", Port: 1, Key: "k", Parent: "missing"}); err == nil {
+		t.Error("Create with a missing parent should fail")
+	}
+
+	// A cycle written by hand is reported instead of followed
+	s.find(base.ID).Parent = child.ID
+	if _, err := s.Get(child.ID); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Get = %v, want a cycle error", err)
+	}
+	if len(s.List()) != 2 {
+		t.Error("List should still return profiles in a cycle")
+	}
+}
+
+func TestDeleteParent(t *testing.T) {
+	s, base, child := inheritingStore(t)
+	eff := *child
+	eff.ID, eff.Name, eff.Parent, eff.MTU = "", "Grandchild", child.ID, 1200
+	grandchild, err := s.Create(&eff)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if err := s.Delete(base.ID); !errors.Is(err, ErrHasChildren) {
+		t.Fatalf("Delete = %v, want ErrHasChildren", err)
+	}
+	if !s.InheritsFrom(grandchild.ID, base.ID) || s.InheritsFrom(base.ID, grandchild.ID) {
+		t.Error("InheritsFrom is wrong")
+	}
+	if kids := s.Children(base.ID); len(kids) != 1 || kids[0].ID != child.ID {
+		t.Errorf("Children = %v", names(kids))
+	}
+
+	if err := s.DeleteAndFlatten(child.ID); err != nil {
+		t.Fatalf("DeleteAndFlatten failed: %v", err)
+	}
+	got, err := s.Get(grandchild.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Parent != base.ID || got.Mode != "fast3" || got.MTU != 1200 || got.Host != "1.2.3.4" {
+		t.Errorf("grandchild = %+v, want its settings under Base", got)
+	}
+
+	if err := s.DeleteAndFlatten(base.ID); err != nil {
+		t.Fatalf("DeleteAndFlatten failed: %v", err)
+	}
+	got, _ = s.Get(grandchild.ID)
+	if got.Parent != "" || got.Own != nil || got.Host != "1.2.3.4" || got.Key != "secret" || got.Mode != "fast3" {
+		t.Errorf("grandchild = %+v, want a standalone copy", got)
+	}
+}
+
+func TestSubscriptionProfilesAndInheritance(t *testing.T) {
+	feed := newFeedServer(t, "paqet://k1@1.2.3.4:8080#One\n")
+	s := tempStore(t)
+	sub, err := s.AddSubscription(&Subscription{URL: feed.URL})
+	if err != nil {
+		t.Fatalf("AddSubscription failed: %v", err)
+	}
+	if _, err := s.SyncSubscription(context.Background(), sub.ID); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	synced, _ := s.Get(s.List()[0].ID)
+
+	eff := *synced
+	eff.ID, eff.Name, eff.Parent, eff.SubscriptionID, eff.SubscriptionRef = "", "Local", synced.ID, "", ""
+	eff.Mode = "fast3"
+	local, err := s.Create(&eff)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	synced.Parent = local.ID
+	if _, err := s.Update(synced); err == nil {
+		t.Error("a synced profile should not inherit")
+	}
+
+	// Removed from the feed: the child keeps its settings
+	feed.set("paqet://k2@5.6.7.8:9090#Two\n", 0)
+	if _, err := s.SyncSubscription(context.Background(), sub.ID); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	got, err := s.Get(local.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Parent != "" || got.Host != "1.2.3.4" || got.Key != "k1" || got.Mode != "fast3" {
+		t.Errorf("local = %+v, want its settings kept", got)
+	}
+}
@@ -0,0 +1,162 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/omid3098/autopaqet/gui/internal/config"
+)
+
+// SchemaVersion is the profiles.json format this build writes:
+//
+//	{"version": N, "profiles": [...]}
+//
+// Version 0 is the bare array written before the file was versioned.
+// Version 2 added inheriting profiles, which store only the fields they
+// override and would look like profiles without a host to older builds.
+// Bump it, and add a migration below, whenever a change to Profile would
+// not load correctly in an older build or from an older file.
+const SchemaVersion = 2
+
+// profilesFile is the versioned profiles.json envelope.
+type profilesFile struct {
+	Version  int        `json:"version"`
+	Profiles []*Profile `json:"profiles"`
+}
+
+// migration upgrades a decoded profiles.json document from version from to
+// from+1. Documents are generic JSON values, so migrations keep working as
+// Profile changes.
+type migration struct {
+	from    int
+	migrate func(doc any) (any, error)
+}
+
+// migrations are applied in order to bring old files up to SchemaVersion.
+var migrations = []migration{
+	{from: 0, migrate: migrateV0},
+	{from: 1, migrate: migrateV1},
+}
+
+// migrateV0 wraps the bare array in the envelope and rewrites forward rules
+// saved in the text form ("tcp:8080:internal:80") as objects.
+func migrateV0(doc any) (any, error) {
+	list, ok := doc.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected a list of profiles")
+	}
+	for _, item := range list {
+		p, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected a profile object")
+		}
+		rules, _ := p["forward"].([]any)
+		for i, rule := range rules {
+			text, ok := rule.(string)
+			if !ok {
+				continue
+			}
+			parsed, err := config.ParseForwardRule(text)
+			if err != nil {
+				return nil, fmt.Errorf("profile %v: %w", p["name"], err)
+			}
+			rules[i] = parsed
+		}
+	}
+	return map[string]any{"version": 1, "profiles": list}, nil
+}
+
+// migrateV1 only bumps the version: version 1 files have no inheriting
+// profiles.
+func migrateV1(doc any) (any, error) {
+	file, ok := doc.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected a profiles object")
+	}
+	file["version"] = 2
+	return file, nil
+}
+
+// decodeProfiles parses profiles.json data of any version and returns the
+// profiles and the version the data was written in. Older data is migrated;
+// newer data is read as far as this build understands it.
+func decodeProfiles(data []byte) ([]*Profile, int, error) {
+	version, err := schemaVersion(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if version < SchemaVersion {
+		var doc any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, 0, err
+		}
+		for v := version; v < SchemaVersion; v++ {
+			m := findMigration(v)
+			if m == nil {
+				return nil, 0, fmt.Errorf("no migration from version %d", v)
+			}
+			if doc, err = m.migrate(doc); err != nil {
+				return nil, 0, fmt.Errorf("migrating from version %d: %w", v, err)
+			}
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var file profilesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, 0, err
+	}
+	if file.Profiles == nil {
+		file.Profiles = make([]*Profile, 0)
+	}
+	return file.Profiles, version, nil
+}
+
+// schemaVersion returns the version of profiles.json data.
+func schemaVersion(data []byte) (int, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) || bytes.Equal(data, []byte("null")) {
+		return 0, nil
+	}
+	var head struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return 0, err
+	}
+	if head.Version == nil || *head.Version < 1 {
+		return 0, fmt.Errorf("missing or invalid version")
+	}
+	return *head.Version, nil
+}
+
+func findMigration(from int) *migration {
+	for i := range migrations {
+		if migrations[i].from == from {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
+
+// keepOriginal copies profiles.json, written in version, before it is
+// rewritten in SchemaVersion, so the build that wrote it can still open the
+// copy. It is named after the version, never rotated, and never replaced;
+// it is only deleted when encryption is enabled or changed, since it holds
+// secrets in the old form.
+func (s *Store) keepOriginal(version int) error {
+	path := fmt.Sprintf("%s.v%d.bak", s.filePath, version)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read profiles: %w", err)
+	}
+	return writeFileAtomic(path, data, 0600)
+}
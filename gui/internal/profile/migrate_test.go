@@ -0,0 +1,182 @@
+package profile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/omid3098/autopaqet/gui/internal/config"
+)
+
+// storeFromFixture opens a store whose profiles.json is a copy of a file in
+// testdata, and returns the store directory and the original contents.
+func storeFromFixture(t *testing.T, name string) (*Store, string, []byte) {
+	t.Helper()
+	original, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "profiles.json"), original, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if rec := s.Recovery(); rec != nil {
+		t.Fatalf("fixture was not loaded: %+v", rec)
+	}
+	return s, dir, original
+}
+
+func TestLoadEveryVersion(t *testing.T) {
+	forward := []config.ForwardRule{
+		{Listen: "127.0.0.1:8080", Target: "internal:80", Protocol: "tcp"},
+		{Listen: "127.0.0.1:5353", Target: "10.0.0.1:53", Protocol: "udp"},
+	}
+
+	tests := []struct {
+		fixture string
+		version int
+		home    Profile
+	}{
+		{
+			fixture: "profiles-v0.json",
+			version: 0,
+			home: Profile{
+				ID: "home", Name: "Home", Host: "203.0.113.10", Port: 9999, Key: "home-key",
+				SocksListen: "127.0.0.1:1080", Mode: "fast", Conn: 2,
+				Forward: forward, SystemProxy: true,
+			},
+		},
+		{
+			fixture: "profiles-v0-rules.json",
+			version: 0,
+			home: Profile{
+				ID: "home", Name: "Home", Host: "203.0.113.10", Port: 9999, Key: "home-key",
+				Fallbacks:   []string{"203.0.113.11:9999"},
+				SocksListen: "127.0.0.1:1080", Mode: "fast", Conn: 2,
+				Forward: forward, SystemProxy: true,
+				Group: "Personal", Tags: []string{"fast"}, Favorite: true,
+			},
+		},
+		{
+			fixture: "profiles-v1.json",
+			version: 1,
+			home: Profile{
+				ID: "home", Name: "Home", Host: "203.0.113.10", Port: 9999, Key: "home-key",
+				Fallbacks:   []string{"203.0.113.11:9999"},
+				SocksListen: "127.0.0.1:1080", Mode: "fast", Conn: 2,
+				Forward: forward, SystemProxy: true,
+				Group: "Personal", Tags: []string{"fast"}, Favorite: true,
+			},
+		},
+		{
+			fixture: "profiles-v2.json",
+			version: 2,
+			home: Profile{
+				ID: "home", Name: "Home", Host: "203.0.113.10", Port: 9999, Key: "home-key",
+				Fallbacks:   []string{"203.0.113.11:9999"},
+				SocksListen: "127.0.0.1:1080", Mode: "fast", Conn: 2,
+				Forward: forward, SystemProxy: true,
+				Group: "Personal", Tags: []string{"fast"}, Favorite: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			s, dir, original := storeFromFixture(t, tt.fixture)
+
+			profiles := s.List()
+			if len(profiles) != 2 {
+				t.Fatalf("loaded %d profiles, want 2", len(profiles))
+			}
+			if !reflect.DeepEqual(*profiles[0], tt.home) {
+				t.Errorf("profile = %+v\nwant %+v", *profiles[0], tt.home)
+			}
+			if profiles[1].ID != "work" || profiles[1].Port != 443 {
+				t.Errorf("second profile = %+v", *profiles[1])
+			}
+
+			// Upgraded in place
+			data, err := os.ReadFile(filepath.Join(dir, "profiles.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v, err := schemaVersion(data); err != nil || v != SchemaVersion {
+				t.Errorf("profiles.json version = %d, err = %v; want %d", v, err, SchemaVersion)
+			}
+			if _, err := NewStore(dir); err != nil {
+				t.Errorf("reopening the upgraded store failed: %v", err)
+			}
+
+			// The original is kept, unless it needed no migration
+			bak := filepath.Join(dir, fmt.Sprintf("profiles.json.v%d.bak", tt.version))
+			kept, err := os.ReadFile(bak)
+			if tt.version < SchemaVersion {
+				if err != nil || !bytes.Equal(kept, original) {
+					t.Errorf("%s does not hold the original file (err = %v)", bak, err)
+				}
+			} else if err == nil {
+				t.Errorf("%s written for a current file", bak)
+			}
+		})
+	}
+}
+
+func TestLoadInheritingProfiles(t *testing.T) {
+	s, _, _ := storeFromFixture(t, "profiles-v2.json")
+	work, err := s.Get("work")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if work.Host != "work.example.com" || work.Mode != "normal" || work.SocksListen != "127.0.0.1:1080" || len(work.Forward) != 2 {
+		t.Errorf("work = %+v, want its own host and mode and the rest from home", work)
+	}
+}
+
+func TestLoadNewerVersion(t *testing.T) {
+	dir := t.TempDir()
+	original := []byte(`{"version": 99, "profiles": [{"id": "p1", "name": "Future", "host": "1.2.3.4", "port": 8080, "key": "k", "unknown": true}]}`)
+	path := filepath.Join(dir, "profiles.json")
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if p, err := s.Get("p1"); err != nil || p.Name != "Future" {
+		t.Fatalf("Get = %+v, %v; want the profile", p, err)
+	}
+
+	// Left alone until the next save, and copied before it
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, original) {
+		t.Error("profiles.json rewritten on load")
+	}
+	if data, _ := os.ReadFile(path + ".v99.bak"); !bytes.Equal(data, original) {
+		t.Error("profiles.json.v99.bak does not hold the original file")
+	}
+}
+
+func TestLoadInvalidVersion(t *testing.T) {
+	for _, data := range []string{`{"profiles": []}`, `{"version": 0, "profiles": []}`, `{"version": "1"}`} {
+		if _, _, err := decodeProfiles([]byte(data)); err == nil {
+			t.Errorf("decodeProfiles(%s) succeeded, want an error", data)
+		}
+	}
+}
+
+func TestMigrationsAreComplete(t *testing.T) {
+	for v := 0; v < SchemaVersion; v++ {
+		if findMigration(v) == nil {
+			t.Errorf("no migration from version %d", v)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package profile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Query filters profiles in Search. Empty fields match everything; all
+// non-empty fields must match. Matching is case-insensitive.
+type Query struct {
+	Text      string   `json:"text"`  // substring of the name or any endpoint host
+	Group     string   `json:"group"` // exact group
+	Tags      []string `json:"tags"`  // profiles must have every tag
+	Favorites bool     `json:"favorites"`
+}
+
+// Search returns the profiles matching q, in stored order.
+func (s *Store) Search(q *Query) []*Profile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	text := strings.ToLower(strings.TrimSpace(q.Text))
+	result := make([]*Profile, 0)
+	for _, p := range s.profiles {
+		if q.Favorites && !p.Favorite {
+			continue
+		}
+		if q.Group != "" && !strings.EqualFold(p.Group, strings.TrimSpace(q.Group)) {
+			continue
+		}
+		if !hasTags(p, q.Tags) {
+			continue
+		}
+		r := s.resolved(p)
+		if text != "" && !matchesText(r, text) {
+			continue
+		}
+		cp := *r
+		result = append(result, &cp)
+	}
+	return result
+}
+
+// matchesText reports whether lowercase text is part of the profile's name
+// or one of its server hosts.
+func matchesText(p *Profile, text string) bool {
+	if strings.Contains(strings.ToLower(p.Name), text) || strings.Contains(strings.ToLower(p.Host), text) {
+		return true
+	}
+	for _, ep := range p.Fallbacks {
+		if strings.Contains(strings.ToLower(ep), text) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTags(p *Profile, tags []string) bool {
+	for _, want := range tags {
+		found := false
+		for _, tag := range p.Tags {
+			if strings.EqualFold(tag, strings.TrimSpace(want)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Groups returns the distinct profile groups, sorted.
+func (s *Store) Groups() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var groups []string
+	for _, p := range s.profiles {
+		groups = append(groups, p.Group)
+	}
+	return distinct(groups)
+}
+
+// Tags returns the distinct profile tags, sorted.
+func (s *Store) Tags() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tags []string
+	for _, p := range s.profiles {
+		tags = append(tags, p.Tags...)
+	}
+	return distinct(tags)
+}
+
+// distinct returns the non-empty values sorted, without duplicates.
+func distinct(values []string) []string {
+	set := make(map[string]bool)
+	result := make([]string, 0)
+	for _, v := range values {
+		if v != "" && !set[v] {
+			set[v] = true
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// normalizeTags trims tags and drops empty and duplicate ones, comparing
+// case-insensitively and keeping the first spelling.
+func normalizeTags(tags []string) []string {
+	var result []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		dup := false
+		for _, seen := range result {
+			if strings.EqualFold(seen, tag) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// SetFavorite marks or unmarks a profile as a favorite. Unlike Update, this
+// works while the store is locked.
+func (s *Store) SetFavorite(id string, favorite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, p := range s.profiles {
+		if p.ID == id {
+			cp := *p
+			cp.Favorite = favorite
+			s.profiles[i] = &cp
+			if err := s.save(); err != nil {
+				s.profiles[i] = p // Roll back
+				return err
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("profile %q not found", id)
+}
+
+// Move moves a profile by offset positions in the stored order: -1 moves it
+// up one, 1 down one. It stops at either end.
+func (s *Store) Move(id string, offset int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from := -1
+	for i, p := range s.profiles {
+		if p.ID == id {
+			from = i
+		}
+	}
+	if from < 0 {
+		return fmt.Errorf("profile %q not found", id)
+	}
+	to := min(max(from+offset, 0), len(s.profiles)-1)
+	if to == from {
+		return nil
+	}
+
+	old := s.profiles
+	profiles := make([]*Profile, len(old))
+	copy(profiles, old)
+	if to < from {
+		copy(profiles[to+1:from+1], old[to:from])
+	} else {
+		copy(profiles[from:to], old[from+1:to+1])
+	}
+	profiles[to] = old[from]
+	s.profiles = profiles
+	if err := s.save(); err != nil {
+		s.profiles = old // Roll back
+		return err
+	}
+	return nil
+}
+
+// Reorder puts the given profiles in the order of ids. Profiles not listed
+// keep their positions, so a filtered view can be reordered on its own: the
+// listed profiles swap among the positions they already occupy.
+func (s *Store) Reorder(ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := make(map[string]int, len(s.profiles))
+	for i, p := range s.profiles {
+		index[p.ID] = i
+	}
+	from := make([]int, 0, len(ids))
+	for _, id := range ids {
+		i, ok := index[id]
+		if !ok {
+			return fmt.Errorf("profile %q not found", id)
+		}
+		if i < 0 {
+			return fmt.Errorf("profile %q listed twice", id)
+		}
+		from = append(from, i)
+		index[id] = -1
+	}
+	slots := append([]int(nil), from...)
+	sort.Ints(slots)
+
+	old := s.profiles
+	profiles := make([]*Profile, len(old))
+	copy(profiles, old)
+	for n, i := range from {
+		profiles[slots[n]] = old[i]
+	}
+	s.profiles = profiles
+	if err := s.save(); err != nil {
+		s.profiles = old // Roll back
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package profile
+
+import (
+	"reflect"
+	"testing"
+)
+
+// organizedStore creates profiles A, B, C and D in that order.
+func organizedStore(t *testing.T) (*Store, map[string]string) {
+	t.Helper()
+	s := tempStore(t)
+	ids := make(map[string]string)
+	for _, p := range []*Profile{
+		{Name: "A", Host: "a.example.com", Port: 1, Key: "k", Group: "Work", Tags: []string{"EU", "fast"}},
+		{Name: "B", Host: "10.0.0.2", Port: 1, Key: "k", Group: "work", Tags: []string{"us"}, Favorite: true},
+		{Name: "C", Host: "10.0.0.3", Port: 1, Key: "k", Fallbacks: []string{"backup.example.com:443"}},
+		{Name: "D", Host: "10.0.0.4", Port: 1, Key: "k", Group: "Home", Tags: []string{"eu"}},
+	} {
+		created, err := s.Create(p)
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		ids[p.Name] = created.ID
+	}
+	return s, ids
+}
+
+func names(profiles []*Profile) []string {
+	result := make([]string, len(profiles))
+	for i, p := range profiles {
+		result[i] = p.Name
+	}
+	return result
+}
+
+func TestSearch(t *testing.T) {
+	s, _ := organizedStore(t)
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"empty", Query{}, []string{"A", "B", "C", "D"}},
+		{"name", Query{Text: "d"}, []string{"D"}},
+		{"host", Query{Text: "10.0.0"}, []string{"B", "C", "D"}},
+		{"fallback host", Query{Text: "BACKUP"}, []string{"C"}},
+		{"group", Query{Group: "work"}, []string{"A", "B"}},
+		{"tag", Query{Tags: []string{"eu"}}, []string{"A", "D"}},
+		{"all tags", Query{Tags: []string{"eu", "fast"}}, []string{"A"}},
+		{"favorites", Query{Favorites: true}, []string{"B"}},
+		{"combined", Query{Text: "example", Group: "Work", Tags: []string{"EU"}}, []string{"A"}},
+		{"no match", Query{Group: "Other"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(s.Search(&tt.query)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got, want := s.Groups(), []string{"Home", "Work", "work"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Groups = %v, want %v", got, want)
+	}
+	if got, want := s.Tags(), []string{"EU", "eu", "fast", "us"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Tags = %v, want %v", got, want)
+	}
+}
+
+func TestTagsAreNormalized(t *testing.T) {
+	s := tempStore(t)
+	p, err := s.Create(&Profile{Name: "A", Host: "1.2.3.4", Port: 1, Key: "k", Group: " Work ", Tags: []string{" eu", "EU", "", "fast"}})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if p.Group != "Work" || !reflect.DeepEqual(p.Tags, []string{"eu", "fast"}) {
+		t.Errorf("group %q, tags %q", p.Group, p.Tags)
+	}
+}
+
+func TestMove(t *testing.T) {
+	s, ids := organizedStore(t)
+
+	steps := []struct {
+		name   string
+		offset int
+		want   []string
+	}{
+		{"C", -1, []string{"A", "C", "B", "D"}},
+		{"A", 2, []string{"C", "B", "A", "D"}},
+		{"D", -10, []string{"D", "C", "B", "A"}},
+		{"D", -1, []string{"D", "C", "B", "A"}}, // already first
+	}
+	for _, step := range steps {
+		if err := s.Move(ids[step.name], step.offset); err != nil {
+			t.Fatalf("Move(%s, %d) failed: %v", step.name, step.offset, err)
+		}
+		if got := names(s.List()); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("after Move(%s, %d): %v, want %v", step.name, step.offset, got, step.want)
+		}
+	}
+
+	// The order persists
+	s2, err := NewStore(s.dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if got := names(s2.List()); !reflect.DeepEqual(got, []string{"D", "C", "B", "A"}) {
+		t.Errorf("reloaded order = %v", got)
+	}
+	if err := s.Move("missing", 1); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestReorder(t *testing.T) {
+	s, ids := organizedStore(t)
+
+	if err := s.Reorder([]string{ids["D"], ids["C"], ids["B"], ids["A"]}); err != nil {
+		t.Fatalf("Reorder failed: %v", err)
+	}
+	if got := names(s.List()); !reflect.DeepEqual(got, []string{"D", "C", "B", "A"}) {
+		t.Errorf("order = %v", got)
+	}
+
+	// A subset swaps among its own positions (1 and 3)
+	if err := s.Reorder([]string{ids["A"], ids["C"]}); err != nil {
+		t.Fatalf("Reorder failed: %v", err)
+	}
+	if got := names(s.List()); !reflect.DeepEqual(got, []string{"D", "A", "B", "C"}) {
+		t.Errorf("order after subset = %v", got)
+	}
+
+	if err := s.Reorder([]string{ids["A"], "missing"}); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+	if err := s.Reorder([]string{ids["A"], ids["A"]}); err == nil {
+		t.Error("expected error for duplicate ID")
+	}
+}
+
+func TestSetFavoriteWhileLocked(t *testing.T) {
+	dir, p := encryptedStore(t)
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if err := s.SetFavorite(p.ID, true); err != nil {
+		t.Fatalf("SetFavorite failed: %v", err)
+	}
+	if got := s.Search(&Query{Favorites: true}); len(got) != 1 {
+		t.Errorf("favorites = %v", names(got))
+	}
+	if err := s.Unlock("master"); err != nil {
+		t.Fatalf("Unlock after SetFavorite failed: %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// maxBackups is how many profile backups are kept.
+	maxBackups = 10
+
+	// backupInterval is the minimum time between backups. The first save
+	// of each session always makes one.
+	backupInterval = time.Hour
+
+	// backupTimeFormat sorts lexically in time order.
+	backupTimeFormat = "20060102T150405.000Z"
+)
+
+// Recovery describes how NewStore recovered from an unreadable profiles.json,
+// or how a save did when another program had written one. It also describes
+// other files found unreadable and started without; see Store.Resets and
+// PresetStore.Reset.
+type Recovery struct {
+	File        string `json:"file"`         // base name of the unreadable file
+	Reason      string `json:"reason"`       // why the file could not be loaded
+	CorruptPath string `json:"corrupt_path"` // where the unreadable file was moved
+	Backup      string `json:"backup"`       // profiles.json backup restored from, empty if none was valid
+	Profiles    int    `json:"profiles"`     // number of profiles recovered, for profiles.json
+	External    bool   `json:"external"`     // profiles.json written by another program; the app's profiles were kept
+}
+
+// writeFileAtomic replaces path with data so that a crash leaves either the
+// old or the new contents, never a partial file: it writes a temp file in
+// the same directory, syncs it, and renames it over path.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s: %w", filepath.Base(path), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(path), err)
+	}
+
+	// Persist the rename itself. Directories cannot be synced on Windows,
+	// where the rename is durable once it returns, so errors are ignored.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// backupsDir holds timestamped copies of profiles.json.
+func (s *Store) backupsDir() string {
+	return filepath.Join(s.dir, "backups")
+}
+
+// backup saves data as a new backup if the last one is older than
+// backupInterval, then deletes all but the newest maxBackups.
+func (s *Store) backup(data []byte, now time.Time) error {
+	if !s.lastBackup.IsZero() && now.Sub(s.lastBackup) < backupInterval {
+		return nil
+	}
+	if err := os.MkdirAll(s.backupsDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	name := "profiles-" + now.UTC().Format(backupTimeFormat) + ".json"
+	if err := writeFileAtomic(filepath.Join(s.backupsDir(), name), data, 0600); err != nil {
+		return err
+	}
+	s.lastBackup = now
+
+	backups := s.listBackups()
+	for _, old := range backups[min(len(backups), maxBackups):] {
+		os.Remove(old)
+	}
+	return nil
+}
+
+// listBackups returns the backup paths, newest first.
+func (s *Store) listBackups() []string {
+	entries, err := os.ReadDir(s.backupsDir())
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), "profiles-") && strings.HasSuffix(e.Name(), ".json") {
+			paths = append(paths, filepath.Join(s.backupsDir(), e.Name()))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	return paths
+}
+
+// listCopies returns the copies of profiles.json kept beside it: originals
+// kept for older builds and unreadable files moved aside.
+func (s *Store) listCopies() []string {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+	base := filepath.Base(s.filePath)
+	var paths []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, base+".") {
+			continue
+		}
+		if strings.HasPrefix(name, base+".corrupt-") || (strings.HasPrefix(name, base+".v") && strings.HasSuffix(name, ".bak")) {
+			paths = append(paths, filepath.Join(s.dir, name))
+		}
+	}
+	return paths
+}
+
+// restoreBackup is called when profiles.json cannot be loaded. It moves the
+// file aside, restores the newest backup that parses, and records what
+// happened in s.recovery. Without a valid backup the store starts empty; the
+// unreadable file is kept for manual repair either way.
+func (s *Store) restoreBackup(cause error) error {
+	rec := &Recovery{
+		File:        filepath.Base(s.filePath),
+		Reason:      cause.Error(),
+		CorruptPath: s.filePath + ".corrupt-" + time.Now().UTC().Format(backupTimeFormat),
+	}
+	if err := os.Rename(s.filePath, rec.CorruptPath); err != nil {
+		return fmt.Errorf("%w (and failed to move it aside: %v)", cause, err)
+	}
+
+	s.profiles = make([]*Profile, 0)
+	for _, path := range s.listBackups() {
+		profiles, _, err := readProfiles(path)
+		if err != nil {
+			continue
+		}
+		s.profiles = profiles
+		rec.Backup = path
+		break
+	}
+	rec.Profiles = len(s.profiles)
+	s.recovery = rec
+
+	return s.save()
+}
+
+// Recovery reports how profiles were recovered when the store was opened or
+// a save found profiles.json unreadable, or nil if it always loaded normally.
+func (s *Store) Recovery() *Recovery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.recovery == nil {
+		return nil
+	}
+	cp := *s.recovery
+	return &cp
+}
+
+// Resets reports the files other than profiles.json that were unreadable
+// when the store was opened. Each was moved aside for manual repair and the
+// store started without its contents.
+func (s *Store) Resets() []*Recovery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*Recovery, len(s.resets))
+	for i, rec := range s.resets {
+		cp := *rec
+		result[i] = &cp
+	}
+	return result
+}
+
+// setAside moves an unreadable file other than profiles.json aside and
+// records it in s.resets, so that the store can open without it. Profiles
+// that refer to its contents are kept, so restoring the file links them
+// again.
+func (s *Store) setAside(path string, cause error) error {
+	rec, err := moveAside(path, cause)
+	if err != nil {
+		return err
+	}
+	s.resets = append(s.resets, rec)
+	return nil
+}
+
+// moveAside renames an unreadable file to a timestamped .corrupt name and
+// describes what was done. It returns cause, annotated, if the rename fails.
+// It is for files whose contents the user can add again, such as
+// subscriptions, trusted publishers and presets, or can do without, such as
+// connection history: opening without them beats failing to start, and the
+// file is kept for manual repair.
+func moveAside(path string, cause error) (*Recovery, error) {
+	rec := &Recovery{
+		File:        filepath.Base(path),
+		Reason:      cause.Error(),
+		CorruptPath: path + ".corrupt-" + time.Now().UTC().Format(backupTimeFormat),
+	}
+	if err := os.Rename(path, rec.CorruptPath); err != nil {
+		return nil, fmt.Errorf("%w (and failed to move it aside: %v)", cause, err)
+	}
+	return rec, nil
+}
+
+// readProfiles reads and parses a profiles file of any schema version, and
+// returns the version it was written in.
+func readProfiles(path string) ([]*Profile, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read profiles: %w", err)
+	}
+	profiles, version, err := decodeProfiles(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to parse profiles: %w", err)
+	}
+	return profiles, version, nil
+}
@@ -0,0 +1,129 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveIsAtomicAndBacksUp(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	for _, name := range []string{"One", "Two"} {
+		if _, err := s.Create(&Profile{Name: name, Host: "1.2.3.4", Port: 8080, Key: "k"}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("temp file %s left behind", e.Name())
+		}
+	}
+
+	// The first save of a session makes a backup; the second is too soon
+	backups := s.listBackups()
+	if len(backups) != 1 {
+		t.Fatalf("backups = %v, want 1", backups)
+	}
+	restored, _, err := readProfiles(backups[0])
+	if err != nil || len(restored) != 1 {
+		t.Errorf("backup holds %d profiles, err = %v; want 1", len(restored), err)
+	}
+}
+
+func TestBackupRotation(t *testing.T) {
+	s := tempStore(t)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < maxBackups+3; i++ {
+		if err := s.backup([]byte("[]"), now.Add(time.Duration(i)*backupInterval)); err != nil {
+			t.Fatalf("backup failed: %v", err)
+		}
+	}
+
+	backups := s.listBackups()
+	if len(backups) != maxBackups {
+		t.Fatalf("kept %d backups, want %d", len(backups), maxBackups)
+	}
+	newest := "profiles-" + now.Add((maxBackups+2)*backupInterval).Format(backupTimeFormat) + ".json"
+	if filepath.Base(backups[0]) != newest {
+		t.Errorf("newest backup = %s, want %s", filepath.Base(backups[0]), newest)
+	}
+}
+
+func TestRecoverFromBackup(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if _, err := s.Create(&Profile{Name: "Saved", Host: "1.2.3.4", Port: 8080, Key: "k"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if s.Recovery() != nil {
+		t.Error("Recovery should be nil for a normal load")
+	}
+
+	// A newer but corrupt backup is skipped
+	bad := filepath.Join(s.backupsDir(), "profiles-99991231T000000.000Z.json")
+	if err := os.WriteFile(bad, []byte("[{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a torn write
+	if err := os.WriteFile(filepath.Join(dir, "profiles.json"), []byte(`[{"id": "x", "na`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s2, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore should recover, got: %v", err)
+	}
+	list := s2.List()
+	if len(list) != 1 || list[0].Name != "Saved" {
+		t.Errorf("recovered profiles = %+v, want [Saved]", list)
+	}
+
+	rec := s2.Recovery()
+	if rec == nil {
+		t.Fatal("Recovery() = nil, want a report")
+	}
+	if rec.Profiles != 1 || rec.Backup == "" || rec.Backup == bad || !strings.Contains(rec.Reason, "failed to parse profiles") {
+		t.Errorf("Recovery = %+v", rec)
+	}
+	if data, err := os.ReadFile(rec.CorruptPath); err != nil || !strings.HasPrefix(string(data), `[{"id": "x"`) {
+		t.Errorf("corrupt file not kept at %s: %v", rec.CorruptPath, err)
+	}
+
+	// profiles.json is valid again
+	if s3, err := NewStore(dir); err != nil || s3.Recovery() != nil || len(s3.List()) != 1 {
+		t.Errorf("reopened store: err = %v", err)
+	}
+}
+
+func TestRecoverWithoutBackup(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "profiles.json"), []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore should start empty, got: %v", err)
+	}
+	if len(s.List()) != 0 {
+		t.Errorf("expected no profiles, got %d", len(s.List()))
+	}
+	if rec := s.Recovery(); rec == nil || rec.Backup != "" || rec.Profiles != 0 {
+		t.Errorf("Recovery = %+v", rec)
+	}
+}
@@ -0,0 +1,359 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/omid3098/autopaqet/gui/internal/validate"
+)
+
+// Tuning holds the KCP and buffer settings a preset controls. Zero values
+// mean paqet's defaults, as in Profile. Mode, conn and the FEC shards must
+// match the server, so they are not part of a preset.
+type Tuning struct {
+	// KCP
+	MTU int `json:"mtu,omitempty"`
+
+	// Manual KCP tuning (mode "manual")
+	NoDelay      int  `json:"nodelay,omitempty"`
+	Interval     int  `json:"interval,omitempty"`
+	Resend       int  `json:"resend,omitempty"`
+	NoCongestion int  `json:"nocongestion,omitempty"`
+	WDelay       bool `json:"wdelay,omitempty"`
+	AckNoDelay   bool `json:"acknodelay,omitempty"`
+
+	// KCP windows
+	RcvWnd int `json:"rcvwnd,omitempty"`
+	SndWnd int `json:"sndwnd,omitempty"`
+
+	// DSCP
+	DSCP int `json:"dscp,omitempty"`
+
+	// Buffers
+	SmuxBuf   int `json:"smuxbuf,omitempty"`
+	StreamBuf int `json:"streambuf,omitempty"`
+	TCPBuf    int `json:"tcpbuf,omitempty"`
+	UDPBuf    int `json:"udpbuf,omitempty"`
+	SockBuf   int `json:"sockbuf,omitempty"`
+}
+
+// TuningOf returns the tuning settings of a profile.
+func TuningOf(p *Profile) Tuning {
+	return Tuning{
+		MTU:          p.MTU,
+		NoDelay:      p.NoDelay,
+		Interval:     p.Interval,
+		Resend:       p.Resend,
+		NoCongestion: p.NoCongestion,
+		WDelay:       p.WDelay,
+		AckNoDelay:   p.AckNoDelay,
+		RcvWnd:       p.RcvWnd,
+		SndWnd:       p.SndWnd,
+		DSCP:         p.DSCP,
+		SmuxBuf:      p.SmuxBuf,
+		StreamBuf:    p.StreamBuf,
+		TCPBuf:       p.TCPBuf,
+		UDPBuf:       p.UDPBuf,
+		SockBuf:      p.SockBuf,
+	}
+}
+
+// Apply overwrites every tuning field of p, so settings left over from a
+// previous preset do not leak through. The server-matched mode, conn and FEC
+// shards are left alone, so applying a preset never breaks a connection.
+func (t Tuning) Apply(p *Profile) {
+	p.MTU = t.MTU
+	p.NoDelay = t.NoDelay
+	p.Interval = t.Interval
+	p.Resend = t.Resend
+	p.NoCongestion = t.NoCongestion
+	p.WDelay = t.WDelay
+	p.AckNoDelay = t.AckNoDelay
+	p.RcvWnd = t.RcvWnd
+	p.SndWnd = t.SndWnd
+	p.DSCP = t.DSCP
+	p.SmuxBuf = t.SmuxBuf
+	p.StreamBuf = t.StreamBuf
+	p.TCPBuf = t.TCPBuf
+	p.UDPBuf = t.UDPBuf
+	p.SockBuf = t.SockBuf
+}
+
+// Validate checks the tuning values. Errors are reported per JSON field name.
+func (t Tuning) Validate() error {
+	var errs validate.Errors
+	errs.Add(validate.MTU("mtu", t.MTU))
+	errs.Add(validate.Toggle("nodelay", t.NoDelay))
+	errs.Add(validate.Interval("interval", t.Interval))
+	errs.Add(validate.NonNegative("resend", t.Resend))
+	errs.Add(validate.Toggle("nocongestion", t.NoCongestion))
+	errs.Add(validate.NonNegative("rcvwnd", t.RcvWnd))
+	errs.Add(validate.NonNegative("sndwnd", t.SndWnd))
+	errs.Add(validate.DSCP("dscp", t.DSCP))
+	errs.Add(validate.NonNegative("smuxbuf", t.SmuxBuf))
+	errs.Add(validate.NonNegative("streambuf", t.StreamBuf))
+	errs.Add(validate.NonNegative("tcpbuf", t.TCPBuf))
+	errs.Add(validate.NonNegative("udpbuf", t.UDPBuf))
+	errs.Add(validate.NonNegative("sockbuf", t.SockBuf))
+	return errs.Err()
+}
+
+// Preset is a named set of tuning values that can be applied to a profile.
+type Preset struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	BuiltIn     bool   `json:"builtin,omitempty"`
+	Tuning
+}
+
+// builtInPresets is the catalog shipped with the app. Built-in presets cannot
+// be modified or deleted.
+var builtInPresets = []Preset{
+	{
+		ID:          "low-latency",
+		Name:        "Low latency",
+		Description: "Small windows for interactive use (SSH, gaming, calls). In manual mode, also retransmits aggressively.",
+		BuiltIn:     true,
+		Tuning: Tuning{
+			NoDelay:      1,
+			Interval:     10,
+			Resend:       2,
+			NoCongestion: 1,
+			RcvWnd:       512,
+			SndWnd:       512,
+		},
+	},
+	{
+		ID:          "high-throughput",
+		Name:        "High throughput",
+		Description: "Large windows and buffers for downloads and streaming on stable links.",
+		BuiltIn:     true,
+		Tuning: Tuning{
+			RcvWnd:    4096,
+			SndWnd:    4096,
+			SmuxBuf:   4194304,
+			StreamBuf: 2097152,
+			SockBuf:   4194304,
+		},
+	},
+	{
+		ID:          "lossy-mobile",
+		Name:        "Lossy mobile",
+		Description: "A smaller MTU and larger windows for mobile or congested links with packet loss. In manual mode, also acknowledges immediately.",
+		BuiltIn:     true,
+		Tuning: Tuning{
+			MTU:          1200,
+			NoDelay:      1,
+			Interval:     20,
+			Resend:       2,
+			NoCongestion: 1,
+			AckNoDelay:   true,
+			RcvWnd:       1024,
+			SndWnd:       1024,
+		},
+	},
+	{
+		ID:          "conservative",
+		Name:        "Conservative",
+		Description: "paqet's defaults with a safe MTU. Light on bandwidth and least likely to be throttled.",
+		BuiltIn:     true,
+		Tuning: Tuning{
+			MTU: 1200,
+		},
+	},
+}
+
+// BuiltInPresets returns a copy of the built-in preset catalog.
+func BuiltInPresets() []*Preset {
+	result := make([]*Preset, len(builtInPresets))
+	for i := range builtInPresets {
+		cp := builtInPresets[i]
+		result[i] = &cp
+	}
+	return result
+}
+
+func builtInPreset(id string) *Preset {
+	for i := range builtInPresets {
+		if builtInPresets[i].ID == id {
+			cp := builtInPresets[i]
+			return &cp
+		}
+	}
+	return nil
+}
+
+// PresetStore manages user presets on disk as a JSON file alongside the
+// built-in catalog.
+type PresetStore struct {
+	mu       sync.RWMutex
+	filePath string
+	presets  []*Preset // user presets only
+	reset    *Recovery // set if presets.json was found unreadable
+}
+
+// NewPresetStore creates or loads a preset store from the given directory.
+func NewPresetStore(dir string) (*PresetStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	s := &PresetStore{
+		filePath: filepath.Join(dir, "presets.json"),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Reset reports whether presets.json was unreadable when the store was
+// opened. If so, it was moved aside for manual repair and the store started
+// with no user presets. Returns nil otherwise.
+func (s *PresetStore) Reset() *Recovery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.reset == nil {
+		return nil
+	}
+	cp := *s.reset
+	return &cp
+}
+
+// List returns the built-in presets followed by the user presets.
+func (s *PresetStore) List() []*Preset {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := BuiltInPresets()
+	for _, p := range s.presets {
+		cp := *p
+		result = append(result, &cp)
+	}
+	return result
+}
+
+// Get returns a built-in or user preset by ID.
+func (s *PresetStore) Get(id string) (*Preset, error) {
+	if p := builtInPreset(id); p != nil {
+		return p, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.presets {
+		if p.ID == id {
+			cp := *p
+			return &cp, nil
+		}
+	}
+	return nil, fmt.Errorf("preset %q not found", id)
+}
+
+// Save creates a user preset, or replaces it if p.ID names an existing one,
+// and persists to disk. Built-in presets cannot be overwritten.
+func (s *PresetStore) Save(p *Preset) (*Preset, error) {
+	if p.Name == "" {
+		return nil, &validate.FieldError{Field: "name", Message: "name is required"}
+	}
+	if err := p.Tuning.Validate(); err != nil {
+		return nil, err
+	}
+	if builtInPreset(p.ID) != nil {
+		return nil, fmt.Errorf("preset %q is built in and cannot be modified", p.ID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := *p
+	cp.BuiltIn = false
+	if cp.ID == "" {
+		cp.ID = uuid.New().String()
+	}
+
+	for i, existing := range s.presets {
+		if existing.ID == cp.ID {
+			s.presets[i] = &cp
+			if err := s.save(); err != nil {
+				s.presets[i] = existing // Roll back
+				return nil, err
+			}
+			ret := cp
+			return &ret, nil
+		}
+	}
+
+	s.presets = append(s.presets, &cp)
+	if err := s.save(); err != nil {
+		// Roll back
+		s.presets = s.presets[:len(s.presets)-1]
+		return nil, err
+	}
+
+	ret := cp
+	return &ret, nil
+}
+
+// Delete removes a user preset by ID and persists to disk.
+func (s *PresetStore) Delete(id string) error {
+	if builtInPreset(id) != nil {
+		return fmt.Errorf("preset %q is built in and cannot be deleted", id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, p := range s.presets {
+		if p.ID == id {
+			s.presets = append(s.presets[:i], s.presets[i+1:]...)
+			return s.save()
+		}
+	}
+	return fmt.Errorf("preset %q not found", id)
+}
+
+func (s *PresetStore) load() error {
+	data, err := os.ReadFile(s.filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.presets = make([]*Preset, 0)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read presets: %w", err)
+	}
+
+	var presets []*Preset
+	if err := json.Unmarshal(data, &presets); err != nil {
+		s.presets = make([]*Preset, 0)
+		rec, err := moveAside(s.filePath, fmt.Errorf("failed to parse presets: %w", err))
+		if err != nil {
+			return err
+		}
+		s.reset = rec
+		return nil
+	}
+
+	s.presets = presets
+	return nil
+}
+
+func (s *PresetStore) save() error {
+	data, err := json.MarshalIndent(s.presets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal presets: %w", err)
+	}
+
+	return writeFileAtomic(s.filePath, data, 0644)
+}
@@ -0,0 +1,209 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltInPresetsAreValid(t *testing.T) {
+	presets := BuiltInPresets()
+	if len(presets) < 4 {
+		t.Fatalf("got %d built-in presets, want at least 4", len(presets))
+	}
+	seen := make(map[string]bool)
+	for _, p := range presets {
+		if seen[p.ID] {
+			t.Errorf("duplicate preset ID %q", p.ID)
+		}
+		seen[p.ID] = true
+		if !p.BuiltIn {
+			t.Errorf("%s: BuiltIn = false", p.ID)
+		}
+		if err := p.Tuning.Validate(); err != nil {
+			t.Errorf("%s: invalid tuning: %v", p.ID, err)
+		}
+	}
+	for _, id := range []string{"low-latency", "high-throughput", "lossy-mobile", "conservative"} {
+		if !seen[id] {
+			t.Errorf("missing built-in preset %q", id)
+		}
+	}
+}
+
+func TestBuiltInPresetsAreCopies(t *testing.T) {
+	BuiltInPresets()[0].RcvWnd = 1
+	if BuiltInPresets()[0].RcvWnd == 1 {
+		t.Error("modifying a returned preset changed the catalog")
+	}
+}
+
+func TestTuningApplyOverwritesAllFields(t *testing.T) {
+	p := &Profile{
+		Name:       "Test",
+		Host:       "1.2.3.4",
+		Port:       8080,
+		Key:        "secret",
+		Block:      "salsa20",
+		Mode:       "manual",
+		NoDelay:    1,
+		AckNoDelay: true,
+		SockBuf:    4096,
+	}
+
+	preset, err := (&PresetStore{}).Get("conservative")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	preset.Apply(p)
+
+	if got := TuningOf(p); got != preset.Tuning {
+		t.Errorf("tuning = %+v, want %+v", got, preset.Tuning)
+	}
+	if p.Block != "salsa20" || p.Key != "secret" || p.Host != "1.2.3.4" {
+		t.Error("Apply should not touch non-tuning fields")
+	}
+}
+
+func TestTuningApplyKeepsServerMatchedFields(t *testing.T) {
+	for _, preset := range BuiltInPresets() {
+		p := &Profile{
+			Name:   "Connected",
+			Host:   "1.2.3.4",
+			Port:   8080,
+			Key:    "secret",
+			Mode:   "fast2",
+			Conn:   3,
+			DShard: 5,
+			PShard: 2,
+		}
+		preset.Apply(p)
+		if p.Mode != "fast2" || p.Conn != 3 || p.DShard != 5 || p.PShard != 2 {
+			t.Errorf("%s: mode/conn/dshard/pshard = %q/%d/%d/%d, want fast2/3/5/2",
+				preset.ID, p.Mode, p.Conn, p.DShard, p.PShard)
+		}
+	}
+
+	// A user preset saved by an older version may still carry them.
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "presets.json"),
+		[]byte(`[{"id":"old","name":"Old","mode":"fast3","conn":4,"dshard":10,"pshard":3,"rcvwnd":2048}]`), 0644)
+	s, err := NewPresetStore(dir)
+	if err != nil {
+		t.Fatalf("NewPresetStore failed: %v", err)
+	}
+	old, err := s.Get("old")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	p := &Profile{Mode: "fast2", Conn: 3, DShard: 5, PShard: 2}
+	old.Apply(p)
+	if p.Mode != "fast2" || p.Conn != 3 || p.DShard != 5 || p.PShard != 2 {
+		t.Errorf("old preset changed mode/conn/dshard/pshard to %q/%d/%d/%d", p.Mode, p.Conn, p.DShard, p.PShard)
+	}
+	if p.RcvWnd != 2048 {
+		t.Errorf("RcvWnd = %d, want 2048", p.RcvWnd)
+	}
+}
+
+func TestPresetStoreSaveAndPersist(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewPresetStore(dir)
+	if err != nil {
+		t.Fatalf("NewPresetStore failed: %v", err)
+	}
+
+	saved, err := s.Save(&Preset{
+		Name:   "Office",
+		Tuning: Tuning{MTU: 1300, RcvWnd: 2048},
+	})
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if saved.ID == "" {
+		t.Error("expected an ID to be assigned")
+	}
+
+	saved.RcvWnd = 4096
+	if _, err := s.Save(saved); err != nil {
+		t.Fatalf("Save (update) failed: %v", err)
+	}
+
+	s2, err := NewPresetStore(dir)
+	if err != nil {
+		t.Fatalf("second NewPresetStore failed: %v", err)
+	}
+	list := s2.List()
+	if len(list) != len(BuiltInPresets())+1 {
+		t.Fatalf("List has %d presets, want %d", len(list), len(BuiltInPresets())+1)
+	}
+	got, err := s2.Get(saved.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Name != "Office" || got.RcvWnd != 4096 || got.BuiltIn {
+		t.Errorf("persisted preset = %+v", got)
+	}
+
+	if err := s2.Delete(saved.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s2.Get(saved.ID); err == nil {
+		t.Error("expected deleted preset to be gone")
+	}
+}
+
+func TestPresetStoreRejects(t *testing.T) {
+	s, err := NewPresetStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewPresetStore failed: %v", err)
+	}
+
+	if _, err := s.Save(&Preset{Tuning: Tuning{RcvWnd: 1024}}); err == nil {
+		t.Error("expected error for missing name")
+	}
+	if _, err := s.Save(&Preset{Name: "Bad", Tuning: Tuning{NoDelay: 2}}); err == nil {
+		t.Error("expected error for invalid nodelay")
+	}
+	if _, err := s.Save(&Preset{ID: "low-latency", Name: "Mine"}); err == nil {
+		t.Error("expected error when overwriting a built-in preset")
+	}
+	if err := s.Delete("conservative"); err == nil {
+		t.Error("expected error when deleting a built-in preset")
+	}
+	if err := s.Delete("missing"); err == nil {
+		t.Error("expected error for unknown preset")
+	}
+}
+
+func TestUnreadablePresetsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "presets.json")
+	if err := os.WriteFile(path, []byte(`[{"id": "x", "na`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewPresetStore(dir)
+	if err != nil {
+		t.Fatalf("NewPresetStore should start without user presets, got: %v", err)
+	}
+	if list := s.List(); len(list) != len(BuiltInPresets()) {
+		t.Errorf("List has %d presets, want only the %d built-in ones", len(list), len(BuiltInPresets()))
+	}
+	rec := s.Reset()
+	if rec == nil || rec.File != "presets.json" || !strings.Contains(rec.Reason, "failed to parse presets") {
+		t.Fatalf("Reset = %+v", rec)
+	}
+	if data, err := os.ReadFile(rec.CorruptPath); err != nil || string(data) != `[{"id": "x", "na` {
+		t.Errorf("unreadable file not kept at %s: %v", rec.CorruptPath, err)
+	}
+
+	if _, err := s.Save(&Preset{Name: "Mine", Tuning: Tuning{RcvWnd: 1024}}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	s2, err := NewPresetStore(dir)
+	if err != nil || s2.Reset() != nil || len(s2.List()) != len(BuiltInPresets())+1 {
+		t.Errorf("reopened store: err = %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/omid3098/autopaqet/gui/internal/uri"
+	"github.com/omid3098/autopaqet/gui/internal/validate"
+)
+
+// Verification states of a profile imported from a link.
+const (
+	// Verified: signed by a trusted publisher and unchanged since.
+	Verified = "verified"
+	// Unverified: unsigned, signed by an unknown key, or edited locally.
+	Unverified = "unverified"
+	// Tampered: the signature does not match the link's contents.
+	Tampered = "tampered"
+)
+
+// Publisher is a trusted signing key, typically a server admin's.
+type Publisher struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key"`
+}
+
+// ListPublishers returns the trusted publishers.
+func (s *Store) ListPublishers() []*Publisher {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*Publisher, len(s.publishers))
+	for i, pub := range s.publishers {
+		cp := *pub
+		result[i] = &cp
+	}
+	return result
+}
+
+// AddPublisher trusts a public key from uri.GenerateSigningKey and updates
+// the verification state of profiles it signed.
+func (s *Store) AddPublisher(name, publicKey string) (*Publisher, error) {
+	if name == "" {
+		return nil, &validate.FieldError{Field: "name", Message: "name is required"}
+	}
+	if !uri.ValidPublicKey(publicKey) {
+		return nil, &validate.FieldError{Field: "public_key", Value: publicKey, Message: "invalid public key"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Verifying signed profiles needs their secrets
+	if err := s.checkUnlocked(); err != nil {
+		return nil, err
+	}
+	for _, pub := range s.publishers {
+		if pub.PublicKey == publicKey {
+			return nil, fmt.Errorf("publisher key is already trusted as %q", pub.Name)
+		}
+	}
+
+	pub := &Publisher{Name: name, PublicKey: publicKey}
+	s.publishers = append(s.publishers, pub)
+	if err := s.savePublishers(); err != nil {
+		// Roll back
+		s.publishers = s.publishers[:len(s.publishers)-1]
+		return nil, err
+	}
+	if err := s.reverify(); err != nil {
+		return nil, err
+	}
+
+	ret := *pub
+	return &ret, nil
+}
+
+// RemovePublisher stops trusting a public key. Profiles it signed become
+// unverified.
+func (s *Store) RemovePublisher(publicKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkUnlocked(); err != nil {
+		return err
+	}
+
+	for i, pub := range s.publishers {
+		if pub.PublicKey == publicKey {
+			s.publishers = append(s.publishers[:i], s.publishers[i+1:]...)
+			if err := s.savePublishers(); err != nil {
+				return err
+			}
+			return s.reverify()
+		}
+	}
+	return fmt.Errorf("publisher %q not found", publicKey)
+}
+
+// verification returns the verification state of a link. Must be called
+// with s.mu held.
+func (s *Store) verification(u *uri.PaqetURI) string {
+	err := u.Verify()
+	switch {
+	case errors.Is(err, uri.ErrBadSignature):
+		return Tampered
+	case err != nil:
+		return Unverified
+	}
+	for _, pub := range s.publishers {
+		if pub.PublicKey == u.PublicKey {
+			return Verified
+		}
+	}
+	return Unverified
+}
+
+// reverify recomputes the verification state of signed profiles after the
+// trusted keys change. Must be called with s.mu held.
+func (s *Store) reverify() error {
+	changed := false
+	for i, p := range s.profiles {
+		if p.Signature == "" {
+			continue
+		}
+		if state := s.verification(uriFromProfile(s.resolved(p))); state != p.Verification {
+			cp := *p
+			cp.Verification = state
+			s.profiles[i] = &cp
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
+
+// verifyEdit keeps the signature of an edited profile only if the edit left
+// the signed link unchanged (for example, toggling the system proxy).
+func verifyEdit(existing, edited *Profile) {
+	edited.Signature = existing.Signature
+	edited.Publisher = existing.Publisher
+	edited.Verification = existing.Verification
+	if existing.Signature == "" {
+		return
+	}
+	if uriFromProfile(existing).String() != uriFromProfile(edited).String() {
+		edited.Signature = ""
+		edited.Verification = Unverified
+	}
+}
+
+func (s *Store) loadPublishers() error {
+	data, err := os.ReadFile(s.publishersPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.publishers = make([]*Publisher, 0)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read publishers: %w", err)
+	}
+
+	var publishers []*Publisher
+	if err := json.Unmarshal(data, &publishers); err != nil {
+		s.publishers = make([]*Publisher, 0)
+		return s.setAside(s.publishersPath, fmt.Errorf("failed to parse publishers: %w", err))
+	}
+
+	s.publishers = publishers
+	return nil
+}
+
+func (s *Store) savePublishers() error {
+	data, err := json.MarshalIndent(s.publishers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal publishers: %w", err)
+	}
+
+	return writeFileAtomic(s.publishersPath, data, 0644)
+}
@@ -0,0 +1,256 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/omid3098/autopaqet/gui/internal/uri"
+)
+
+// signedLink returns a link signed by a new key, and that key's public half.
+func signedLink(t *testing.T, raw string) (link, publicKey string) {
+	t.Helper()
+	pub, priv, err := uri.GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey failed: %v", err)
+	}
+	u, err := uri.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := u.Sign(priv); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	return u.String(), pub
+}
+
+func TestImportVerification(t *testing.T) {
+	s := tempStore(t)
+	link, pub := signedLink(t, "paqet://key@1.2.3.4:8080?mode=fast#Office")
+
+	unsigned, err := s.ImportFromURI("paqet://key@1.2.3.4:8080#Plain")
+	if err != nil {
+		t.Fatalf("ImportFromURI failed: %v", err)
+	}
+	if unsigned.Verification != Unverified {
+		t.Errorf("unsigned: Verification = %q, want %q", unsigned.Verification, Unverified)
+	}
+
+	untrusted, err := s.ImportFromURI(link)
+	if err != nil {
+		t.Fatalf("ImportFromURI failed: %v", err)
+	}
+	if untrusted.Verification != Unverified || untrusted.Publisher != pub {
+		t.Errorf("untrusted: Verification = %q, Publisher = %q", untrusted.Verification, untrusted.Publisher)
+	}
+
+	// Trusting the key upgrades the existing profile
+	if _, err := s.AddPublisher("Admin", pub); err != nil {
+		t.Fatalf("AddPublisher failed: %v", err)
+	}
+	if got, _ := s.Get(untrusted.ID); got.Verification != Verified {
+		t.Errorf("after AddPublisher: Verification = %q, want %q", got.Verification, Verified)
+	}
+
+	tampered, err := s.ImportFromURI(strings.Replace(link, "1.2.3.4", "6.6.6.6", 1))
+	if err != nil {
+		t.Fatalf("ImportFromURI failed: %v", err)
+	}
+	if tampered.Verification != Tampered {
+		t.Errorf("tampered: Verification = %q, want %q", tampered.Verification, Tampered)
+	}
+
+	if err := s.RemovePublisher(pub); err != nil {
+		t.Fatalf("RemovePublisher failed: %v", err)
+	}
+	if got, _ := s.Get(untrusted.ID); got.Verification != Unverified {
+		t.Errorf("after RemovePublisher: Verification = %q, want %q", got.Verification, Unverified)
+	}
+}
+
+func TestCreateIgnoresClaimedVerification(t *testing.T) {
+	s := tempStore(t)
+	p, err := s.Create(&Profile{Name: "Fake", Host: "1.2.3.4", Port: 8080, Key: "k", Verification: Verified})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if p.Verification != "" {
+		t.Errorf("Verification = %q, want none for an unsigned profile", p.Verification)
+	}
+}
+
+func TestEditingSignedProfile(t *testing.T) {
+	s := tempStore(t)
+	link, pub := signedLink(t, "paqet://key@1.2.3.4:8080#Office")
+	if _, err := s.AddPublisher("Admin", pub); err != nil {
+		t.Fatalf("AddPublisher failed: %v", err)
+	}
+	p, err := s.ImportFromURI(link)
+	if err != nil {
+		t.Fatalf("ImportFromURI failed: %v", err)
+	}
+
+	// Local-only settings keep the signature, and re-sharing keeps it valid
+	p.SystemProxy = true
+	p, err = s.Update(p)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if p.Verification != Verified || p.Signature == "" {
+		t.Errorf("after local-only edit: Verification = %q", p.Verification)
+	}
+	shared, err := s.ExportToURI(p.ID, true)
+	if err != nil {
+		t.Fatalf("ExportToURI failed: %v", err)
+	}
+	if reimported, err := s.ImportFromURI(shared); err != nil || reimported.Verification != Verified {
+		t.Errorf("re-shared link: Verification = %v, err = %v", reimported, err)
+	}
+
+	// Connection settings drop it
+	p.Host = "5.6.7.8"
+	p, err = s.Update(p)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if p.Verification != Unverified || p.Signature != "" {
+		t.Errorf("after host edit: Verification = %q, Signature = %q", p.Verification, p.Signature)
+	}
+}
+
+func TestExportToSignedURI(t *testing.T) {
+	s := tempStore(t)
+	pub, priv, err := uri.GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey failed: %v", err)
+	}
+	p, err := s.Create(&Profile{Name: "Office", Host: "1.2.3.4", Port: 8080, Key: "secret"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if p.Verification != "" {
+		t.Errorf("local profile: Verification = %q, want empty", p.Verification)
+	}
+
+	link, err := s.ExportToSignedURI(p.ID, priv, false)
+	if err != nil {
+		t.Fatalf("ExportToSignedURI failed: %v", err)
+	}
+	u, err := uri.Parse(link)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := u.Verify(); err != nil || u.PublicKey != pub {
+		t.Errorf("Verify = %v, PublicKey = %q", err, u.PublicKey)
+	}
+	if _, err := s.ExportToSignedURI(p.ID, "bad", false); err == nil {
+		t.Error("expected error for invalid private key")
+	}
+}
+
+func TestPublisherPersistenceAndValidation(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	pub, _, err := uri.GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey failed: %v", err)
+	}
+
+	if _, err := s.AddPublisher("", pub); err == nil {
+		t.Error("expected error for missing name")
+	}
+	if _, err := s.AddPublisher("Admin", "not-a-key"); err == nil {
+		t.Error("expected error for invalid key")
+	}
+	if _, err := s.AddPublisher("Admin", pub); err != nil {
+		t.Fatalf("AddPublisher failed: %v", err)
+	}
+	if _, err := s.AddPublisher("Again", pub); err == nil {
+		t.Error("expected error for duplicate key")
+	}
+
+	s2, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("second NewStore failed: %v", err)
+	}
+	if list := s2.ListPublishers(); len(list) != 1 || list[0].Name != "Admin" {
+		t.Errorf("persisted publishers = %+v", list)
+	}
+	if err := s2.RemovePublisher("missing"); err == nil {
+		t.Error("expected error for unknown publisher")
+	}
+}
+
+func TestUnreadablePublishersFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"publishers.json", "subscriptions.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("not json"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore should start without publishers, got: %v", err)
+	}
+	if list := s.ListPublishers(); len(list) != 0 {
+		t.Errorf("publishers = %+v, want none", list)
+	}
+	var files []string
+	for _, rec := range s.Resets() {
+		files = append(files, rec.File)
+		if _, err := os.Stat(rec.CorruptPath); err != nil {
+			t.Errorf("unreadable %s not kept: %v", rec.File, err)
+		}
+	}
+	if got := strings.Join(files, ","); got != "subscriptions.json,publishers.json" {
+		t.Errorf("Resets files = %q", got)
+	}
+
+	pub, _, err := uri.GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey failed: %v", err)
+	}
+	if _, err := s.AddPublisher("Admin", pub); err != nil {
+		t.Fatalf("AddPublisher failed: %v", err)
+	}
+	if s2, err := NewStore(dir); err != nil || len(s2.ListPublishers()) != 1 {
+		t.Errorf("reopened store: err = %v", err)
+	}
+}
+
+func TestReverifyInheritingProfile(t *testing.T) {
+	s := tempStore(t)
+	link, pub := signedLink(t, "paqet://key@1.2.3.4:8080?mode=fast#Office")
+	signed, err := s.ImportFromURI(link)
+	if err != nil {
+		t.Fatalf("ImportFromURI failed: %v", err)
+	}
+	base := *signed
+	base.Name, base.Signature, base.Publisher, base.Verification = "Base", "", "", ""
+	parent, err := s.Create(&base)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Inheriting every setting leaves the signed link unchanged
+	signed.Parent = parent.ID
+	if signed, err = s.Update(signed); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if signed.Signature == "" || len(signed.Own) != 0 {
+		t.Fatalf("child = %+v, want a signed profile inheriting everything", signed)
+	}
+
+	if _, err := s.AddPublisher("Admin", pub); err != nil {
+		t.Fatalf("AddPublisher failed: %v", err)
+	}
+	if got, _ := s.Get(signed.ID); got.Verification != Verified {
+		t.Errorf("Verification = %q, want %q", got.Verification, Verified)
+	}
+}
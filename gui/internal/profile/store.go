@@ -2,14 +2,22 @@ package profile
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/omid3098/autopaqet/gui/internal/config"
 	"github.com/omid3098/autopaqet/gui/internal/uri"
+	"github.com/omid3098/autopaqet/gui/internal/validate"
 )
 
 // Profile represents a saved connection profile with all configuration options.
@@ -22,6 +30,13 @@ type Profile struct {
 	Port int    `json:"port"`
 	Key  string `json:"key"`
 
+	// Failover endpoints (host:port), tried in order when Host:Port fails
+	Fallbacks []string `json:"fallbacks,omitempty"`
+
+	// Endpoint the last successful connection verified through. Local only:
+	// set by SetLastEndpoint rather than by edits, and not shared in links.
+	LastEndpoint string `json:"last_endpoint,omitempty"`
+
 	// SOCKS5
 	SocksListen string `json:"socks_listen,omitempty"`
 	SocksUser   string `json:"socks_user,omitempty"`
@@ -33,6 +48,14 @@ type Profile struct {
 	MTU   int    `json:"mtu,omitempty"`
 	Block string `json:"block,omitempty"`
 
+	// Manual KCP tuning (mode "manual")
+	NoDelay      int  `json:"nodelay,omitempty"`
+	Interval     int  `json:"interval,omitempty"`
+	Resend       int  `json:"resend,omitempty"`
+	NoCongestion int  `json:"nocongestion,omitempty"`
+	WDelay       bool `json:"wdelay,omitempty"`
+	AckNoDelay   bool `json:"acknodelay,omitempty"`
+
 	// KCP windows
 	RcvWnd int `json:"rcvwnd,omitempty"`
 	SndWnd int `json:"sndwnd,omitempty"`
@@ -56,21 +79,125 @@ type Profile struct {
 	RemoteFlag string `json:"remote_flag,omitempty"`
 
 	// Forwarding
-	Forward []string `json:"forward,omitempty"`
+	Forward []config.ForwardRule `json:"forward,omitempty"`
 
 	// Logging
 	LogLevel string `json:"log_level,omitempty"`
 
 	// System proxy preference
 	SystemProxy bool `json:"system_proxy,omitempty"`
+
+	// Organization. Local only: not shared in links and kept across
+	// subscription syncs. Profiles are listed in the order they are stored.
+	Group    string   `json:"group,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Favorite bool     `json:"favorite,omitempty"`
+
+	// Parent profile this one inherits its settings from, if any, and the
+	// fields set on this profile instead (see inherit.go).
+	Parent string   `json:"parent,omitempty"`
+	Own    []string `json:"own,omitempty"`
+
+	// Subscription the profile was synced from, if any. SubscriptionRef
+	// identifies the entry within the feed across syncs, and Overrides lists
+	// the JSON fields edited locally, which syncs leave untouched.
+	SubscriptionID  string   `json:"subscription_id,omitempty"`
+	SubscriptionRef string   `json:"subscription_ref,omitempty"`
+	Overrides       []string `json:"overrides,omitempty"`
+
+	// Publisher signature from the imported link, if any, and whether it
+	// checks out against the trusted publishers (Verified, Unverified or
+	// Tampered). Verification is empty for profiles created locally.
+	Signature    string `json:"signature,omitempty"`
+	Publisher    string `json:"publisher,omitempty"` // public key
+	Verification string `json:"verification,omitempty"`
+
+	// Key, SocksUser and SocksPass sealed with the store's data key, while
+	// the store is locked and in profiles.json (see vault.go).
+	Secrets string `json:"secrets,omitempty"`
+}
+
+// Validate checks all fields against paqet's accepted values. Errors are
+// reported per JSON field name.
+func (p *Profile) Validate() error {
+	var errs validate.Errors
+	if p.Host == "" {
+		errs.Add(&validate.FieldError{Field: "host", Message: "host is required"})
+	}
+	errs.Add(validate.Port("port", p.Port))
+	errs.Add(validate.Fallbacks("fallbacks", net.JoinHostPort(p.Host, strconv.Itoa(p.Port)), p.Fallbacks))
+	if p.Key == "" {
+		errs.Add(&validate.FieldError{Field: "key", Message: "key is required"})
+	}
+	errs.Add(validate.ListenAddr("socks_listen", p.SocksListen))
+	errs.Add(validate.Mode("mode", p.Mode))
+	errs.Add(validate.Conn("conn", p.Conn))
+	errs.Add(validate.MTU("mtu", p.MTU))
+	errs.Add(validate.Block("block", p.Block))
+	errs.Add(validate.Toggle("nodelay", p.NoDelay))
+	errs.Add(validate.Interval("interval", p.Interval))
+	errs.Add(validate.NonNegative("resend", p.Resend))
+	errs.Add(validate.Toggle("nocongestion", p.NoCongestion))
+	errs.Add(validate.NonNegative("rcvwnd", p.RcvWnd))
+	errs.Add(validate.NonNegative("sndwnd", p.SndWnd))
+	errs.Add(validate.Shards("dshard", p.DShard, "pshard", p.PShard))
+	errs.Add(validate.DSCP("dscp", p.DSCP))
+	errs.Add(validate.NonNegative("smuxbuf", p.SmuxBuf))
+	errs.Add(validate.NonNegative("streambuf", p.StreamBuf))
+	errs.Add(validate.NonNegative("tcpbuf", p.TCPBuf))
+	errs.Add(validate.NonNegative("udpbuf", p.UDPBuf))
+	errs.Add(validate.NonNegative("sockbuf", p.SockBuf))
+	errs.Add(validate.Flag("local_flag", p.LocalFlag))
+	errs.Add(validate.Flag("remote_flag", p.RemoteFlag))
+	errs.Add(config.ValidateForward("forward", p.Forward))
+	return errs.Err()
 }
 
-// Store manages profiles on disk as a JSON file.
+// Endpoints returns the server endpoints in the order they should be tried:
+// Host:Port followed by the fallbacks.
+func (p *Profile) Endpoints() []string {
+	return append([]string{net.JoinHostPort(p.Host, strconv.Itoa(p.Port))}, p.Fallbacks...)
+}
+
+// keepLastEndpoint carries the endpoint last connected through over to an
+// updated profile, as long as the profile still lists it.
+func keepLastEndpoint(p *Profile, last string) {
+	p.LastEndpoint = ""
+	for _, ep := range p.Endpoints() {
+		if ep == last {
+			p.LastEndpoint = last
+		}
+	}
+}
+
+// Store manages profiles and subscriptions on disk as JSON files.
 type Store struct {
 	mu       sync.RWMutex
 	dir      string
 	filePath string
 	profiles []*Profile
+
+	subsPath   string
+	subs       []*Subscription
+	httpClient *http.Client
+
+	publishersPath string
+	publishers     []*Publisher
+
+	lastBackup time.Time   // of profiles.json, zero until the first save
+	recovery   *Recovery   // set if profiles.json was found unreadable
+	resets     []*Recovery // other files found unreadable when opened
+
+	vaultPath string
+	vault     *vaultFile // nil unless secrets are encrypted at rest
+	dataKey   []byte     // nil while locked
+
+	historyPath string
+	history     map[string]*History // by profile ID
+
+	disk     []*Profile // profiles as last read from or written to profiles.json
+	diskHash [32]byte   // SHA-256 of profiles.json then
+	onChange func(*Change, error)
 }
 
 // NewStore creates or loads a profile store from the given directory.
@@ -80,48 +207,103 @@ func NewStore(dir string) (*Store, error) {
 	}
 
 	s := &Store{
-		dir:      dir,
-		filePath: filepath.Join(dir, "profiles.json"),
+		dir:        dir,
+		filePath:   filepath.Join(dir, "profiles.json"),
+		subsPath:   filepath.Join(dir, "subscriptions.json"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+
+		publishersPath: filepath.Join(dir, "publishers.json"),
+		vaultPath:      filepath.Join(dir, "vault.json"),
+		historyPath:    filepath.Join(dir, "history.json"),
 	}
 
+	// The vault first: recovering profiles.json from a backup saves it
+	if err := s.loadVault(); err != nil {
+		return nil, err
+	}
 	if err := s.load(); err != nil {
 		return nil, err
 	}
+	if err := s.loadSubscriptions(); err != nil {
+		return nil, err
+	}
+	if err := s.loadPublishers(); err != nil {
+		return nil, err
+	}
+	if err := s.loadHistory(); err != nil {
+		return nil, err
+	}
 
 	return s, nil
 }
 
-// List returns all profiles.
+// List returns all profiles, with inherited settings filled in.
 func (s *Store) List() []*Profile {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	result := make([]*Profile, len(s.profiles))
-	copy(result, s.profiles)
+	for i, p := range s.profiles {
+		result[i] = s.resolved(p)
+	}
 	return result
 }
 
-// Get returns a profile by ID.
+// Get returns a profile by ID, with inherited settings filled in. It fails
+// with ErrLocked while the store is locked.
 func (s *Store) Get(id string) (*Profile, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	for _, p := range s.profiles {
-		if p.ID == id {
-			cp := *p
-			return &cp, nil
-		}
+	if err := s.checkUnlocked(); err != nil {
+		return nil, err
+	}
+
+	p := s.find(id)
+	if p == nil {
+		return nil, fmt.Errorf("profile %q not found", id)
+	}
+	r, err := s.resolve(p)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("profile %q not found", id)
+	cp := *r
+	return &cp, nil
 }
 
-// Create adds a new profile and persists to disk.
+// Create adds a new profile and persists to disk. A profile with a Parent is
+// given with its effective settings; only those that differ from the
+// parent's are stored. The verification state is worked out from the
+// signature; any given with p is ignored.
 func (s *Store) Create(p *Profile) (*Profile, error) {
+	return s.create(p, "")
+}
+
+// create is Create, with unsigned as the verification state if p has no
+// signature.
+func (s *Store) create(p *Profile, unsigned string) (*Profile, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.checkUnlocked(); err != nil {
+		return nil, err
+	}
 	cp := *p
 	cp.ID = uuid.New().String()
+	cp.Secrets = ""
+	cp.LastEndpoint = ""
+	cp.Group, cp.Tags = strings.TrimSpace(cp.Group), normalizeTags(cp.Tags)
+	cp.Verification = unsigned
+	if cp.Signature != "" {
+		cp.Verification = s.verification(uriFromProfile(&cp))
+	}
+	if err := s.inherit(&cp); err != nil {
+		return nil, err
+	}
 
 	s.profiles = append(s.profiles, &cp)
 	if err := s.save(); err != nil {
@@ -130,35 +312,90 @@ func (s *Store) Create(p *Profile) (*Profile, error) {
 		return nil, err
 	}
 
-	ret := cp
+	ret := *s.resolved(&cp)
 	return &ret, nil
 }
 
-// Update replaces an existing profile and persists to disk.
+// Update replaces an existing profile and persists to disk. For profiles
+// synced from a subscription, the subscription link is kept and edited fields
+// are recorded as overrides so later syncs preserve them. Editing a signed
+// profile's connection settings drops the signature. Like Create, a profile
+// with a Parent is given with its effective settings.
 func (s *Store) Update(p *Profile) (*Profile, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.checkUnlocked(); err != nil {
+		return nil, err
+	}
 	for i, existing := range s.profiles {
 		if existing.ID == p.ID {
 			cp := *p
+			cp.Secrets = ""
+			cp.Group, cp.Tags = strings.TrimSpace(cp.Group), normalizeTags(cp.Tags)
+			keepLastEndpoint(&cp, existing.LastEndpoint)
+			if existing.SubscriptionID != "" {
+				cp.SubscriptionID = existing.SubscriptionID
+				cp.SubscriptionRef = existing.SubscriptionRef
+				cp.Overrides = mergeOverrides(existing.Overrides, changedFields(existing, &cp))
+			}
+			verifyEdit(s.resolved(existing), &cp)
+			if err := s.inherit(&cp); err != nil {
+				return nil, err
+			}
 			s.profiles[i] = &cp
 			if err := s.save(); err != nil {
 				s.profiles[i] = existing // Roll back
 				return nil, err
 			}
-			ret := cp
+			ret := *s.resolved(&cp)
 			return &ret, nil
 		}
 	}
 	return nil, fmt.Errorf("profile %q not found", p.ID)
 }
 
-// Delete removes a profile by ID and persists to disk.
+// SetLastEndpoint records the endpoint a connection to the profile verified
+// through. Like SetFavorite, this works while the store is locked.
+func (s *Store) SetLastEndpoint(id, endpoint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, p := range s.profiles {
+		if p.ID == id {
+			cp := *p
+			cp.LastEndpoint = endpoint
+			s.profiles[i] = &cp
+			if err := s.save(); err != nil {
+				s.profiles[i] = p // Roll back
+				return err
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("profile %q not found", id)
+}
+
+// Delete removes a profile by ID and persists to disk. It fails with
+// ErrHasChildren if other profiles inherit from it.
 func (s *Store) Delete(id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	children := 0
+	for _, p := range s.profiles {
+		if p.Parent == id {
+			children++
+		}
+	}
+	if children > 0 {
+		return fmt.Errorf("%w (%d profiles)", ErrHasChildren, children)
+	}
+
 	for i, p := range s.profiles {
 		if p.ID == id {
 			s.profiles = append(s.profiles[:i], s.profiles[i+1:]...)
@@ -169,111 +406,273 @@ func (s *Store) Delete(id string) error {
 }
 
 // ImportFromURI parses a paqet:// URI and creates a profile from it.
+// Encrypted links fail with uri.ErrPassphraseRequired so the caller can ask
+// for the passphrase and retry with ImportFromEncryptedURI.
 func (s *Store) ImportFromURI(raw string) (*Profile, error) {
 	u, err := uri.Parse(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse URI: %w", err)
 	}
 
+	return s.importURI(u)
+}
+
+// ImportFromEncryptedURI opens an encrypted paqet:// link with a passphrase
+// and creates a profile from it. Plain links are accepted too.
+func (s *Store) ImportFromEncryptedURI(raw, passphrase string) (*Profile, error) {
+	if !uri.IsEncrypted(raw) {
+		return s.ImportFromURI(raw)
+	}
+	u, err := uri.Decrypt(raw, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt URI: %w", err)
+	}
+
+	return s.importURI(u)
+}
+
+// importURI creates a profile from a parsed link. Create checks the
+// signature, if there is one; unsigned links are unverified.
+func (s *Store) importURI(u *uri.PaqetURI) (*Profile, error) {
+	return s.create(profileFromURI(u), Unverified)
+}
+
+// profileFromURI maps a parsed URI onto a new, unsaved profile.
+func profileFromURI(u *uri.PaqetURI) *Profile {
+	return &Profile{
+		Name:         u.Name,
+		Host:         u.Host,
+		Port:         u.Port,
+		Fallbacks:    u.Fallbacks,
+		Key:          u.Key,
+		SocksListen:  u.Socks,
+		SocksUser:    u.SocksUser,
+		SocksPass:    u.SocksPass,
+		Mode:         u.Mode,
+		Conn:         u.Conn,
+		MTU:          u.MTU,
+		Block:        u.Block,
+		NoDelay:      u.NoDelay,
+		Interval:     u.Interval,
+		Resend:       u.Resend,
+		NoCongestion: u.NoCongestion,
+		WDelay:       u.WDelay,
+		AckNoDelay:   u.AckNoDelay,
+		RcvWnd:       u.RcvWnd,
+		SndWnd:       u.SndWnd,
+		DShard:       u.DShard,
+		PShard:       u.PShard,
+		DSCP:         u.DSCP,
+		SmuxBuf:      u.SmuxBuf,
+		StreamBuf:    u.StreamBuf,
+		TCPBuf:       u.TCPBuf,
+		UDPBuf:       u.UDPBuf,
+		SockBuf:      u.SockBuf,
+		LocalFlag:    u.LocalFlag,
+		RemoteFlag:   u.RemoteFlag,
+		Forward:      u.Forward,
+		LogLevel:     u.Log,
+		Signature:    u.Signature,
+		Publisher:    u.PublicKey,
+	}
+}
+
+// ImportFromConfig creates a profile from parsed paqet client options.
+// Machine-specific fields (interface, local address, router MAC, Npcap GUID)
+// are ignored since they only apply to the machine the config came from.
+func (s *Store) ImportFromConfig(opts *config.Options, name string) (*Profile, error) {
+	if opts.ServerAddr == "" {
+		return nil, fmt.Errorf("config has no server address")
+	}
+	host, portStr, err := net.SplitHostPort(opts.ServerAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server address %q: %w", opts.ServerAddr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server port %q: %w", portStr, err)
+	}
+	if opts.Key == "" {
+		return nil, fmt.Errorf("config has no key")
+	}
+
 	p := &Profile{
-		Name:        u.Name,
-		Host:        u.Host,
-		Port:        u.Port,
-		Key:         u.Key,
-		SocksListen: u.Socks,
-		SocksUser:   u.SocksUser,
-		SocksPass:   u.SocksPass,
-		Mode:        u.Mode,
-		Conn:        u.Conn,
-		MTU:         u.MTU,
-		Block:       u.Block,
-		RcvWnd:      u.RcvWnd,
-		SndWnd:      u.SndWnd,
-		DShard:      u.DShard,
-		PShard:      u.PShard,
-		DSCP:        u.DSCP,
-		SmuxBuf:     u.SmuxBuf,
-		StreamBuf:   u.StreamBuf,
-		TCPBuf:      u.TCPBuf,
-		UDPBuf:      u.UDPBuf,
-		SockBuf:     u.SockBuf,
-		LocalFlag:   u.LocalFlag,
-		RemoteFlag:  u.RemoteFlag,
-		LogLevel:    u.Log,
-	}
-
-	if u.Forward != "" {
-		p.Forward = strings.Split(u.Forward, ",")
+		Name:         name,
+		Host:         host,
+		Port:         port,
+		Key:          opts.Key,
+		SocksListen:  opts.SocksListen,
+		SocksUser:    opts.SocksUser,
+		SocksPass:    opts.SocksPass,
+		Mode:         opts.Mode,
+		Conn:         opts.Conn,
+		MTU:          opts.MTU,
+		Block:        opts.Block,
+		NoDelay:      opts.NoDelay,
+		Interval:     opts.Interval,
+		Resend:       opts.Resend,
+		NoCongestion: opts.NoCongestion,
+		WDelay:       opts.WDelay,
+		AckNoDelay:   opts.AckNoDelay,
+		RcvWnd:       opts.RcvWnd,
+		SndWnd:       opts.SndWnd,
+		DShard:       opts.DShard,
+		PShard:       opts.PShard,
+		DSCP:         opts.DSCP,
+		SmuxBuf:      opts.SmuxBuf,
+		StreamBuf:    opts.StreamBuf,
+		TCPBuf:       opts.TCPBuf,
+		UDPBuf:       opts.UDPBuf,
+		SockBuf:      opts.SockBuf,
+		LocalFlag:    opts.LocalFlag,
+		RemoteFlag:   opts.RemoteFlag,
+		Forward:      opts.Forward,
+		LogLevel:     opts.LogLevel,
 	}
 
 	return s.Create(p)
 }
 
-// ExportToURI serializes a profile to a paqet:// URI string.
-func (s *Store) ExportToURI(id string) (string, error) {
+// ExportToURI serializes a profile to a paqet:// URI string. With compact
+// set it uses the shorter binary form, which suits QR codes.
+func (s *Store) ExportToURI(id string, compact bool) (string, error) {
 	p, err := s.Get(id)
 	if err != nil {
 		return "", err
 	}
 
-	u := &uri.PaqetURI{
-		Key:        p.Key,
-		Host:       p.Host,
-		Port:       p.Port,
-		Name:       p.Name,
-		Socks:      p.SocksListen,
-		SocksUser:  p.SocksUser,
-		SocksPass:  p.SocksPass,
-		Mode:       p.Mode,
-		Conn:       p.Conn,
-		MTU:        p.MTU,
-		Block:      p.Block,
-		RcvWnd:     p.RcvWnd,
-		SndWnd:     p.SndWnd,
-		DShard:     p.DShard,
-		PShard:     p.PShard,
-		DSCP:       p.DSCP,
-		SmuxBuf:    p.SmuxBuf,
-		StreamBuf:  p.StreamBuf,
-		TCPBuf:     p.TCPBuf,
-		UDPBuf:     p.UDPBuf,
-		SockBuf:    p.SockBuf,
-		LocalFlag:  p.LocalFlag,
-		RemoteFlag: p.RemoteFlag,
-		Log:        p.LogLevel,
-	}
-
-	if len(p.Forward) > 0 {
-		u.Forward = strings.Join(p.Forward, ",")
+	u := uriFromProfile(p)
+	if compact {
+		return u.Compact(), nil
+	}
+	return u.String(), nil
+}
+
+// ExportToSignedURI serializes a profile to a paqet:// URI signed with a
+// private key from uri.GenerateSigningKey, for admins publishing profiles.
+func (s *Store) ExportToSignedURI(id, privateKey string, compact bool) (string, error) {
+	p, err := s.Get(id)
+	if err != nil {
+		return "", err
 	}
 
+	u := uriFromProfile(p)
+	if err := u.Sign(privateKey); err != nil {
+		return "", err
+	}
+	if compact {
+		return u.Compact(), nil
+	}
 	return u.String(), nil
 }
 
+// ExportToEncryptedURI serializes a profile to a passphrase-encrypted
+// paqet:// link.
+func (s *Store) ExportToEncryptedURI(id, passphrase string) (string, error) {
+	p, err := s.Get(id)
+	if err != nil {
+		return "", err
+	}
+
+	return uri.Encrypt(uriFromProfile(p), passphrase)
+}
+
+// uriFromProfile maps a profile onto a URI.
+func uriFromProfile(p *Profile) *uri.PaqetURI {
+	return &uri.PaqetURI{
+		Key:          p.Key,
+		Host:         p.Host,
+		Port:         p.Port,
+		Fallbacks:    p.Fallbacks,
+		Name:         p.Name,
+		Socks:        p.SocksListen,
+		SocksUser:    p.SocksUser,
+		SocksPass:    p.SocksPass,
+		Mode:         p.Mode,
+		Conn:         p.Conn,
+		MTU:          p.MTU,
+		Block:        p.Block,
+		NoDelay:      p.NoDelay,
+		Interval:     p.Interval,
+		Resend:       p.Resend,
+		NoCongestion: p.NoCongestion,
+		WDelay:       p.WDelay,
+		AckNoDelay:   p.AckNoDelay,
+		RcvWnd:       p.RcvWnd,
+		SndWnd:       p.SndWnd,
+		DShard:       p.DShard,
+		PShard:       p.PShard,
+		DSCP:         p.DSCP,
+		SmuxBuf:      p.SmuxBuf,
+		StreamBuf:    p.StreamBuf,
+		TCPBuf:       p.TCPBuf,
+		UDPBuf:       p.UDPBuf,
+		SockBuf:      p.SockBuf,
+		LocalFlag:    p.LocalFlag,
+		RemoteFlag:   p.RemoteFlag,
+		Forward:      p.Forward,
+		Log:          p.LogLevel,
+		Signature:    p.Signature,
+		PublicKey:    p.Publisher,
+	}
+}
+
+// load reads profiles.json, falling back to the newest valid backup if it
+// cannot be read or parsed.
 func (s *Store) load() error {
 	data, err := os.ReadFile(s.filePath)
-	if os.IsNotExist(err) {
+	if errors.Is(err, fs.ErrNotExist) {
 		s.profiles = make([]*Profile, 0)
 		return nil
 	}
 	if err != nil {
-		return fmt.Errorf("failed to read profiles: %w", err)
+		return s.restoreBackup(fmt.Errorf("failed to read profiles: %w", err))
 	}
-
-	var profiles []*Profile
-	if err := json.Unmarshal(data, &profiles); err != nil {
-		return fmt.Errorf("failed to parse profiles: %w", err)
+	profiles, version, err := decodeProfiles(data)
+	if err != nil {
+		return s.restoreBackup(fmt.Errorf("failed to parse profiles: %w", err))
 	}
-
 	s.profiles = profiles
+	s.synced(data)
+
+	switch {
+	case version < SchemaVersion:
+		// Upgrade in place, keeping the original for older builds
+		if err := s.keepOriginal(version); err != nil {
+			return err
+		}
+		return s.save()
+	case version > SchemaVersion:
+		// Written by a newer build. It is read as far as this build
+		// understands it and rewritten by the next save, so keep a copy
+		// of the original now.
+		return s.keepOriginal(version)
+	}
 	return nil
 }
 
+// save writes profiles.json atomically and keeps a rotating set of backups.
+// Changes another program made to the file are merged in first, and secrets
+// are sealed if encryption is enabled.
 func (s *Store) save() error {
-	data, err := json.MarshalIndent(s.profiles, "", "  ")
+	change, err := s.mergeExternal()
+	if err != nil {
+		return err
+	}
+	profiles, err := s.sealedProfiles()
+	if err != nil {
+		return err
+	}
+	file := profilesFile{Version: SchemaVersion, Profiles: profiles}
+	data, err := json.MarshalIndent(file, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal profiles: %w", err)
 	}
 
-	return os.WriteFile(s.filePath, data, 0644)
+	if err := writeFileAtomic(s.filePath, data, 0600); err != nil {
+		return err
+	}
+	s.synced(data)
+	s.notify(change)
+	return s.backup(data, time.Now())
 }
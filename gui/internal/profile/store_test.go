@@ -1,11 +1,16 @@
 package profile
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/omid3098/autopaqet/gui/internal/config"
 	"github.com/omid3098/autopaqet/gui/internal/uri"
+	"github.com/omid3098/autopaqet/gui/internal/validate"
 )
 
 func tempStore(t *testing.T) *Store {
@@ -121,6 +126,59 @@ func TestUpdateNotFound(t *testing.T) {
 	}
 }
 
+func TestCreateRejectsInvalidFields(t *testing.T) {
+	s := tempStore(t)
+
+	_, err := s.Create(&Profile{
+		Name:      "Bad",
+		Host:      "1.2.3.4",
+		Port:      8080,
+		Key:       "secret",
+		Mode:      "turbo",
+		Conn:      500,
+		LocalFlag: "SYN",
+		Forward:   []config.ForwardRule{{Listen: "127.0.0.1:8080", Target: "internal"}},
+	})
+	var errs validate.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validate.Errors, got %v", err)
+	}
+
+	fields := make(map[string]bool)
+	for _, fe := range errs {
+		fields[fe.Field] = true
+	}
+	for _, want := range []string{"mode", "conn", "local_flag", "forward[0].target"} {
+		if !fields[want] {
+			t.Errorf("missing error for field %q in %v", want, errs)
+		}
+	}
+	if len(s.List()) != 0 {
+		t.Error("invalid profile should not be stored")
+	}
+}
+
+func TestUpdateRejectsInvalidFields(t *testing.T) {
+	s := tempStore(t)
+
+	p, _ := s.Create(&Profile{
+		Name: "Original",
+		Host: "1.2.3.4",
+		Port: 8080,
+		Key:  "secret",
+	})
+
+	p.Block = "rot13"
+	if _, err := s.Update(p); err == nil {
+		t.Fatal("expected error for invalid block")
+	}
+
+	found, _ := s.Get(p.ID)
+	if found.Block != "" {
+		t.Errorf("Block = %q, invalid update should not persist", found.Block)
+	}
+}
+
 func TestDelete(t *testing.T) {
 	s := tempStore(t)
 
@@ -178,6 +236,27 @@ func TestFilePersistence(t *testing.T) {
 	}
 }
 
+func TestLoadLegacyForwardStrings(t *testing.T) {
+	dir := t.TempDir()
+	data := `[{"id":"p1","name":"Old","host":"1.2.3.4","port":8080,"key":"k","forward":["tcp:8080:internal:80"]}]`
+	if err := os.WriteFile(filepath.Join(dir, "profiles.json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	p, err := s.Get("p1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	want := config.ForwardRule{Listen: "127.0.0.1:8080", Target: "internal:80", Protocol: "tcp"}
+	if len(p.Forward) != 1 || p.Forward[0] != want {
+		t.Errorf("Forward = %+v, want [%+v]", p.Forward, want)
+	}
+}
+
 func TestImportFromURI(t *testing.T) {
 	s := tempStore(t)
 
@@ -210,6 +289,68 @@ func TestImportFromURI(t *testing.T) {
 	}
 }
 
+func TestImportFromConfig(t *testing.T) {
+	s := tempStore(t)
+
+	opts := &config.Options{
+		ServerAddr:    "10.0.0.1:9999",
+		Key:           "secretkey",
+		InterfaceName: "Ethernet",
+		LocalAddr:     "192.168.1.100:12345",
+		GatewayMAC:    "aa:bb:cc:dd:ee:ff",
+		NpcapGUID:     `\Device\NPF_{1234}`,
+		Mode:          "fast2",
+		Conn:          2,
+		LocalFlag:     "S",
+		RemoteFlag:    "A",
+	}
+
+	p, err := s.ImportFromConfig(opts, "client")
+	if err != nil {
+		t.Fatalf("ImportFromConfig failed: %v", err)
+	}
+	if p.Name != "client" {
+		t.Errorf("Name = %q, want %q", p.Name, "client")
+	}
+	if p.Host != "10.0.0.1" || p.Port != 9999 {
+		t.Errorf("Host:Port = %s:%d, want 10.0.0.1:9999", p.Host, p.Port)
+	}
+	if p.Key != "secretkey" {
+		t.Errorf("Key = %q, want %q", p.Key, "secretkey")
+	}
+	if p.Mode != "fast2" || p.Conn != 2 {
+		t.Errorf("Mode/Conn = %s/%d, want fast2/2", p.Mode, p.Conn)
+	}
+	if p.LocalFlag != "S" || p.RemoteFlag != "A" {
+		t.Errorf("flags = %s/%s, want S/A", p.LocalFlag, p.RemoteFlag)
+	}
+	if len(s.List()) != 1 {
+		t.Errorf("List returned %d profiles, want 1", len(s.List()))
+	}
+}
+
+func TestImportFromConfigInvalid(t *testing.T) {
+	s := tempStore(t)
+
+	tests := []struct {
+		name string
+		opts *config.Options
+	}{
+		{"missing server", &config.Options{Key: "k"}},
+		{"missing port", &config.Options{ServerAddr: "10.0.0.1", Key: "k"}},
+		{"bad port", &config.Options{ServerAddr: "10.0.0.1:abc", Key: "k"}},
+		{"missing key", &config.Options{ServerAddr: "10.0.0.1:9999"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := s.ImportFromConfig(tc.opts, "x"); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
 func TestExportToURI(t *testing.T) {
 	s := tempStore(t)
 
@@ -225,7 +366,7 @@ func TestExportToURI(t *testing.T) {
 		RemoteFlag: "PA",
 	})
 
-	raw, err := s.ExportToURI(p.ID)
+	raw, err := s.ExportToURI(p.ID, false)
 	if err != nil {
 		t.Fatalf("ExportToURI failed: %v", err)
 	}
@@ -259,7 +400,7 @@ func TestImportExportRoundTrip(t *testing.T) {
 		t.Fatalf("ImportFromURI failed: %v", err)
 	}
 
-	exported, err := s.ExportToURI(p.ID)
+	exported, err := s.ExportToURI(p.ID, false)
 	if err != nil {
 		t.Fatalf("ExportToURI failed: %v", err)
 	}
@@ -309,6 +450,177 @@ func TestImportExportRoundTrip(t *testing.T) {
 	}
 }
 
+func TestManualKCPRoundTrip(t *testing.T) {
+	s := tempStore(t)
+
+	p, err := s.Create(&Profile{
+		Name:         "Lossy",
+		Host:         "1.2.3.4",
+		Port:         8080,
+		Key:          "secret",
+		Mode:         "manual",
+		NoDelay:      1,
+		Interval:     20,
+		Resend:       2,
+		NoCongestion: 1,
+		WDelay:       true,
+		AckNoDelay:   true,
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	exported, err := s.ExportToURI(p.ID, false)
+	if err != nil {
+		t.Fatalf("ExportToURI failed: %v", err)
+	}
+	imported, err := s.ImportFromURI(exported)
+	if err != nil {
+		t.Fatalf("ImportFromURI failed: %v", err)
+	}
+
+	imported.ID = p.ID
+	imported.Verification = "" // set on import
+	if !reflect.DeepEqual(imported, p) {
+		t.Errorf("round trip = %+v, want %+v", imported, p)
+	}
+}
+
+func TestCompactURIRoundTrip(t *testing.T) {
+	s := tempStore(t)
+	p, err := s.Create(&Profile{
+		Name: "Compact", Host: "1.2.3.4", Port: 8080, Key: "secret",
+		Fallbacks: []string{"5.6.7.8:8443"}, Mode: "fast2", Conn: 2, DShard: 10, PShard: 3,
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	text, err := s.ExportToURI(p.ID, false)
+	if err != nil {
+		t.Fatalf("ExportToURI failed: %v", err)
+	}
+	compact, err := s.ExportToURI(p.ID, true)
+	if err != nil {
+		t.Fatalf("ExportToURI (compact) failed: %v", err)
+	}
+	if !uri.IsCompact(compact) || len(compact) >= len(text) {
+		t.Errorf("compact = %q, text = %q", compact, text)
+	}
+
+	imported, err := s.ImportFromURI(compact)
+	if err != nil {
+		t.Fatalf("ImportFromURI failed: %v", err)
+	}
+	imported.ID = p.ID
+	imported.Verification = "" // set on import
+	if !reflect.DeepEqual(imported, p) {
+		t.Errorf("round trip = %+v, want %+v", imported, p)
+	}
+}
+
+func TestFallbackEndpoints(t *testing.T) {
+	s := tempStore(t)
+	p, err := s.ImportFromURI("paqet://key@1.2.3.4:9999,5.6.7.8:8443#Multi")
+	if err != nil {
+		t.Fatalf("ImportFromURI failed: %v", err)
+	}
+	want := []string{"1.2.3.4:9999", "5.6.7.8:8443"}
+	if got := p.Endpoints(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Endpoints = %v, want %v", got, want)
+	}
+
+	exported, err := s.ExportToURI(p.ID, false)
+	if err != nil {
+		t.Fatalf("ExportToURI failed: %v", err)
+	}
+	if !strings.HasPrefix(exported, "paqet://key@1.2.3.4:9999,5.6.7.8:8443?") {
+		t.Errorf("exported = %q", exported)
+	}
+
+	p.Fallbacks = append(p.Fallbacks, "5.6.7.8:8443")
+	_, err = s.Update(p)
+	var errs validate.Errors
+	if !errors.As(err, &errs) || errs[0].Field != "fallbacks[1]" {
+		t.Errorf("duplicate fallback: err = %v, want error on fallbacks[1]", err)
+	}
+}
+
+func TestLastEndpoint(t *testing.T) {
+	s := tempStore(t)
+	p, err := s.ImportFromURI("paqet://key@1.2.3.4:9999,5.6.7.8:8443#Multi")
+	if err != nil {
+		t.Fatalf("ImportFromURI failed: %v", err)
+	}
+	if err := s.SetLastEndpoint(p.ID, "5.6.7.8:8443"); err != nil {
+		t.Fatalf("SetLastEndpoint failed: %v", err)
+	}
+	if err := s.SetLastEndpoint("missing", "5.6.7.8:8443"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+
+	s2, err := NewStore(s.dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	got, _ := s2.Get(p.ID)
+	if got.LastEndpoint != "5.6.7.8:8443" {
+		t.Fatalf("LastEndpoint = %q, want 5.6.7.8:8443", got.LastEndpoint)
+	}
+
+	// Edits keep it, even from a copy read before it was set...
+	p.Name = "Renamed"
+	if got, err = s2.Update(p); err != nil || got.LastEndpoint != "5.6.7.8:8443" {
+		t.Errorf("after rename: LastEndpoint = %q, err = %v", got.LastEndpoint, err)
+	}
+	// ...until the endpoint is removed from the profile.
+	p.Fallbacks = nil
+	if got, err = s2.Update(p); err != nil || got.LastEndpoint != "" {
+		t.Errorf("after removing the fallback: LastEndpoint = %q, err = %v", got.LastEndpoint, err)
+	}
+
+	if _, err := s2.Create(&Profile{Name: "New", Host: "1.2.3.4", Port: 1, Key: "k", LastEndpoint: "1.2.3.4:1"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range s2.List() {
+		if p.Name == "New" && p.LastEndpoint != "" {
+			t.Errorf("Create kept LastEndpoint %q", p.LastEndpoint)
+		}
+	}
+}
+
+func TestEncryptedURIRoundTrip(t *testing.T) {
+	s := tempStore(t)
+	p, err := s.Create(&Profile{Name: "Shared", Host: "1.2.3.4", Port: 8080, Key: "secret", Mode: "fast"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	link, err := s.ExportToEncryptedURI(p.ID, "passphrase")
+	if err != nil {
+		t.Fatalf("ExportToEncryptedURI failed: %v", err)
+	}
+	if _, err := s.ImportFromURI(link); !errors.Is(err, uri.ErrPassphraseRequired) {
+		t.Errorf("ImportFromURI: err = %v, want ErrPassphraseRequired", err)
+	}
+	if _, err := s.ImportFromEncryptedURI(link, "wrong"); !errors.Is(err, uri.ErrWrongPassphrase) {
+		t.Errorf("wrong passphrase: err = %v, want ErrWrongPassphrase", err)
+	}
+
+	imported, err := s.ImportFromEncryptedURI(link, "passphrase")
+	if err != nil {
+		t.Fatalf("ImportFromEncryptedURI failed: %v", err)
+	}
+	imported.ID = p.ID
+	imported.Verification = "" // set on import
+	if !reflect.DeepEqual(imported, p) {
+		t.Errorf("round trip = %+v, want %+v", imported, p)
+	}
+	if len(s.List()) != 2 {
+		t.Errorf("List has %d profiles, want 2", len(s.List()))
+	}
+}
+
 func TestMultipleProfiles(t *testing.T) {
 	s := tempStore(t)
 
@@ -366,7 +678,7 @@ func TestProfileAllFields(t *testing.T) {
 		SockBuf:     4194304,
 		LocalFlag:   "S",
 		RemoteFlag:  "A",
-		Forward:     []string{"tcp:8080:internal:80"},
+		Forward:     []config.ForwardRule{{Listen: "127.0.0.1:8080", Target: "internal:80"}},
 		LogLevel:    "debug",
 		SystemProxy: true,
 	}
@@ -383,7 +695,7 @@ func TestProfileAllFields(t *testing.T) {
 	if found.SystemProxy != true {
 		t.Error("SystemProxy should be true")
 	}
-	if len(found.Forward) != 1 || found.Forward[0] != "tcp:8080:internal:80" {
-		t.Errorf("Forward = %v, want [tcp:8080:internal:80]", found.Forward)
+	if len(found.Forward) != 1 || found.Forward[0].Target != "internal:80" {
+		t.Errorf("Forward = %+v, want one rule to internal:80", found.Forward)
 	}
 }
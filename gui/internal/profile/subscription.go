@@ -0,0 +1,506 @@
+package profile
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/omid3098/autopaqet/gui/internal/uri"
+)
+
+// maxSubscriptionSize caps how much of a subscription response is read.
+const maxSubscriptionSize = 1 << 20
+
+// Subscription is a remote list of paqet:// URIs whose profiles are kept in
+// sync with the store.
+type Subscription struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Interval is the number of minutes between automatic syncs. Zero means
+	// the subscription is only synced on demand.
+	Interval int `json:"interval,omitempty"`
+
+	// Status of the last sync attempt.
+	LastSync   time.Time `json:"last_sync"`
+	LastError  string    `json:"last_error,omitempty"`
+	LastCount  int       `json:"last_count,omitempty"`
+	LastStatus int       `json:"last_status,omitempty"` // HTTP status code
+}
+
+// SyncResult summarizes the changes made by a subscription sync.
+type SyncResult struct {
+	SubscriptionID string   `json:"subscription_id"`
+	Added          int      `json:"added"`
+	Updated        int      `json:"updated"`
+	Removed        int      `json:"removed"`
+	Kept           int      `json:"kept"`              // removed from the feed, kept locally for their overrides
+	Skipped        []string `json:"skipped,omitempty"` // entries that failed to parse
+}
+
+// ListSubscriptions returns all subscriptions.
+func (s *Store) ListSubscriptions() []*Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*Subscription, len(s.subs))
+	for i, sub := range s.subs {
+		cp := *sub
+		result[i] = &cp
+	}
+	return result
+}
+
+// AddSubscription validates and stores a new subscription. It does not fetch
+// it; call SyncSubscription for that.
+func (s *Store) AddSubscription(sub *Subscription) (*Subscription, error) {
+	if err := validateSubscriptionURL(sub.URL); err != nil {
+		return nil, err
+	}
+	if sub.Interval < 0 {
+		return nil, fmt.Errorf("interval cannot be negative")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := *sub
+	cp.ID = uuid.New().String()
+	cp.LastSync, cp.LastError, cp.LastCount, cp.LastStatus = time.Time{}, "", 0, 0
+	if cp.Name == "" {
+		cp.Name = cp.URL
+	}
+
+	s.subs = append(s.subs, &cp)
+	if err := s.saveSubscriptions(); err != nil {
+		// Roll back
+		s.subs = s.subs[:len(s.subs)-1]
+		return nil, err
+	}
+
+	ret := cp
+	return &ret, nil
+}
+
+// DeleteSubscription removes a subscription. Its profiles are deleted too
+// unless keepProfiles is set, in which case they become regular profiles.
+// Profiles inheriting from deleted ones are flattened, as by
+// DeleteAndFlatten.
+func (s *Store) DeleteSubscription(id string, keepProfiles bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := -1
+	for i, sub := range s.subs {
+		if sub.ID == id {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return fmt.Errorf("subscription %q not found", id)
+	}
+
+	profiles := make([]*Profile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		if p.SubscriptionID != id {
+			profiles = append(profiles, p)
+			continue
+		}
+		if keepProfiles {
+			cp := *p
+			cp.SubscriptionID, cp.SubscriptionRef, cp.Overrides = "", "", nil
+			profiles = append(profiles, &cp)
+		}
+	}
+
+	profiles, err := s.flatten(profiles)
+	if err != nil {
+		return err
+	}
+	oldProfiles := s.profiles
+	s.profiles = profiles
+	if err := s.save(); err != nil {
+		s.profiles = oldProfiles // Roll back
+		return err
+	}
+
+	s.subs = append(s.subs[:idx], s.subs[idx+1:]...)
+	return s.saveSubscriptions()
+}
+
+// SyncSubscription fetches a subscription and creates, updates or removes its
+// profiles to match. Fields listed in a profile's Overrides keep their local
+// values, and a profile with overrides whose entry left the feed is kept as a
+// local profile. A feed may be empty, but one whose entries all fail to parse
+// is an error. The outcome is recorded on the subscription either way.
+func (s *Store) SyncSubscription(ctx context.Context, id string) (*SyncResult, error) {
+	s.mu.RLock()
+	if err := s.checkUnlocked(); err != nil {
+		s.mu.RUnlock()
+		return nil, err
+	}
+	var subURL string
+	for _, sub := range s.subs {
+		if sub.ID == id {
+			subURL = sub.URL
+		}
+	}
+	s.mu.RUnlock()
+	if subURL == "" {
+		return nil, fmt.Errorf("subscription %q not found", id)
+	}
+
+	body, status, fetchErr := s.fetchSubscription(ctx, subURL)
+
+	var entries map[string]*Profile
+	var result *SyncResult
+	if fetchErr == nil {
+		result = &SyncResult{SubscriptionID: id}
+		entries, result.Skipped = parseSubscription(body)
+		if len(entries) == 0 && len(result.Skipped) > 0 {
+			fetchErr = fmt.Errorf("subscription returned no valid profiles")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sub *Subscription
+	for _, candidate := range s.subs {
+		if candidate.ID == id {
+			sub = candidate
+		}
+	}
+	if sub == nil {
+		return nil, fmt.Errorf("subscription %q not found", id)
+	}
+	if err := s.checkUnlocked(); err != nil { // locked while fetching
+		return nil, err
+	}
+	sub.LastSync = time.Now()
+	sub.LastStatus = status
+
+	if fetchErr != nil {
+		sub.LastError = fetchErr.Error()
+		if err := s.saveSubscriptions(); err != nil {
+			return nil, err
+		}
+		return nil, fetchErr
+	}
+
+	// Profiles inheriting from removed entries keep their settings
+	profiles, err := s.flatten(s.mergeSubscription(id, entries, result))
+	if err != nil {
+		return nil, err
+	}
+	oldProfiles := s.profiles
+	s.profiles = profiles
+	if err := s.save(); err != nil {
+		s.profiles = oldProfiles // Roll back
+		return nil, err
+	}
+
+	sub.LastError = ""
+	sub.LastCount = len(entries)
+	if err := s.saveSubscriptions(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SyncDue syncs every subscription whose interval has elapsed, reporting each
+// outcome to onSync (which may be nil). Nothing is synced while the store is
+// locked.
+func (s *Store) SyncDue(ctx context.Context, now time.Time, onSync func(*SyncResult, error)) {
+	s.mu.RLock()
+	if s.checkUnlocked() != nil {
+		s.mu.RUnlock()
+		return
+	}
+	var due []string
+	for _, sub := range s.subs {
+		if sub.Interval > 0 && now.Sub(sub.LastSync) >= time.Duration(sub.Interval)*time.Minute {
+			due = append(due, sub.ID)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, id := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		res, err := s.SyncSubscription(ctx, id)
+		if onSync != nil {
+			onSync(res, err)
+		}
+	}
+}
+
+// RunSubscriptionSync calls SyncDue every tick until ctx is cancelled.
+func (s *Store) RunSubscriptionSync(ctx context.Context, tick time.Duration, onSync func(*SyncResult, error)) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	s.SyncDue(ctx, time.Now(), onSync)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.SyncDue(ctx, now, onSync)
+		}
+	}
+}
+
+// mergeSubscription returns the profile list with subscription id's profiles
+// replaced by entries, keyed by SubscriptionRef. Profiles with overrides whose
+// entry is gone are detached from the subscription instead of removed. Must
+// be called with s.mu held.
+func (s *Store) mergeSubscription(id string, entries map[string]*Profile, result *SyncResult) []*Profile {
+	profiles := make([]*Profile, 0, len(s.profiles)+len(entries))
+	seen := make(map[string]bool)
+
+	for _, p := range s.profiles {
+		if p.SubscriptionID != id {
+			profiles = append(profiles, p)
+			continue
+		}
+		remote, ok := entries[p.SubscriptionRef]
+		if !ok && len(p.Overrides) > 0 {
+			cp := *p
+			cp.SubscriptionID, cp.SubscriptionRef, cp.Overrides = "", "", nil
+			profiles = append(profiles, &cp)
+			result.Kept++
+			continue
+		}
+		if !ok {
+			result.Removed++
+			continue
+		}
+		seen[p.SubscriptionRef] = true
+
+		cp := *remote
+		cp.ID = p.ID
+		cp.SubscriptionID = id
+		cp.SubscriptionRef = p.SubscriptionRef
+		cp.Overrides = p.Overrides
+		cp.Group, cp.Tags, cp.Favorite = p.Group, p.Tags, p.Favorite
+		cp.Verification = s.verification(uriFromProfile(remote))
+		signed := cp
+		copyFields(&cp, p, p.Overrides)
+		keepLastEndpoint(&cp, p.LastEndpoint)
+		verifyEdit(&signed, &cp) // overrides may differ from what was signed
+		if cp.Validate() != nil {
+			// Local overrides no longer fit the remote entry; keep the old profile.
+			result.Skipped = append(result.Skipped, p.SubscriptionRef)
+			profiles = append(profiles, p)
+			continue
+		}
+		if !reflect.DeepEqual(&cp, p) {
+			result.Updated++
+		}
+		profiles = append(profiles, &cp)
+	}
+
+	refs := make([]string, 0, len(entries))
+	for ref := range entries {
+		if !seen[ref] {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		cp := *entries[ref]
+		cp.ID = uuid.New().String()
+		cp.SubscriptionID = id
+		cp.SubscriptionRef = ref
+		cp.Verification = s.verification(uriFromProfile(&cp))
+		profiles = append(profiles, &cp)
+		result.Added++
+	}
+	return profiles
+}
+
+func (s *Store) fetchSubscription(ctx context.Context, rawURL string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid subscription request: %w", err)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch subscription: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, fmt.Errorf("subscription server returned %s", resp.Status)
+	}
+	// Read one byte past the limit so a truncated list is never synced.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSubscriptionSize+1))
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("failed to read subscription: %w", err)
+	}
+	if len(body) > maxSubscriptionSize {
+		return nil, resp.StatusCode, fmt.Errorf("subscription is larger than %d bytes", maxSubscriptionSize)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// parseSubscription decodes a subscription body of newline-separated
+// paqet:// URIs, optionally base64-encoded as a whole. Entries are keyed by
+// their name, or host:port when unnamed; repeated keys get a numeric suffix.
+func parseSubscription(body []byte) (map[string]*Profile, []string) {
+	text := strings.TrimSpace(string(body))
+	if !strings.Contains(text, "paqet://") {
+		if decoded, ok := decodeBase64(text); ok {
+			text = decoded
+		}
+	}
+
+	entries := make(map[string]*Profile)
+	var skipped []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		u, err := uri.Parse(line)
+		if err != nil {
+			skipped = append(skipped, err.Error())
+			continue
+		}
+
+		ref := u.Name
+		if ref == "" {
+			ref = net.JoinHostPort(u.Host, strconv.Itoa(u.Port))
+		}
+		key := ref
+		for n := 2; entries[key] != nil; n++ {
+			key = ref + "~" + strconv.Itoa(n)
+		}
+		entries[key] = profileFromURI(u)
+	}
+	return entries, skipped
+}
+
+func decodeBase64(s string) (string, bool) {
+	s = strings.Join(strings.Fields(s), "")
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err := enc.DecodeString(s); err == nil {
+			return string(data), true
+		}
+	}
+	return "", false
+}
+
+func validateSubscriptionURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid subscription URL %q, expected http(s)://", raw)
+	}
+	return nil
+}
+
+// profileFields maps JSON field names to struct field indexes for the
+// fields that a subscription sync and local overrides apply to.
+var profileFields = func() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(Profile{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		switch name {
+		case "", "-", "id", "subscription_id", "subscription_ref", "overrides",
+			"signature", "publisher", "verification", "secrets",
+			"group", "tags", "favorite", "parent", "own", "last_endpoint":
+			continue
+		}
+		fields[name] = i
+	}
+	return fields
+}()
+
+// changedFields returns the JSON names of the fields that differ between a and b.
+func changedFields(a, b *Profile) []string {
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	var changed []string
+	for name, i := range profileFields {
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// copyFields copies the named JSON fields from src to dst.
+func copyFields(dst, src *Profile, names []string) {
+	vd, vs := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for _, name := range names {
+		if i, ok := profileFields[name]; ok {
+			vd.Field(i).Set(vs.Field(i))
+		}
+	}
+}
+
+// mergeOverrides returns the sorted union of two override lists.
+func mergeOverrides(a, b []string) []string {
+	set := make(map[string]bool)
+	for _, name := range a {
+		set[name] = true
+	}
+	for _, name := range b {
+		set[name] = true
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	merged := make([]string, 0, len(set))
+	for name := range set {
+		merged = append(merged, name)
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+func (s *Store) loadSubscriptions() error {
+	data, err := os.ReadFile(s.subsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.subs = make([]*Subscription, 0)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read subscriptions: %w", err)
+	}
+
+	var subs []*Subscription
+	if err := json.Unmarshal(data, &subs); err != nil {
+		s.subs = make([]*Subscription, 0)
+		return s.setAside(s.subsPath, fmt.Errorf("failed to parse subscriptions: %w", err))
+	}
+
+	s.subs = subs
+	return nil
+}
+
+func (s *Store) saveSubscriptions() error {
+	data, err := json.MarshalIndent(s.subs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal subscriptions: %w", err)
+	}
+
+	return writeFileAtomic(s.subsPath, data, 0644)
+}
@@ -0,0 +1,380 @@
+package profile
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// feedServer serves whatever body is currently set, or status if non-zero.
+type feedServer struct {
+	mu     sync.Mutex
+	body   string
+	status int
+	*httptest.Server
+}
+
+func newFeedServer(t *testing.T, body string) *feedServer {
+	t.Helper()
+	f := &feedServer{body: body}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.status != 0 {
+			w.WriteHeader(f.status)
+			return
+		}
+		w.Write([]byte(f.body))
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *feedServer) set(body string, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.body, f.status = body, status
+}
+
+func subscriptionProfiles(s *Store, id string) map[string]*Profile {
+	result := make(map[string]*Profile)
+	for _, p := range s.List() {
+		if p.SubscriptionID == id {
+			cp := *p
+			result[p.SubscriptionRef] = &cp
+		}
+	}
+	return result
+}
+
+func TestParseSubscriptionFormats(t *testing.T) {
+	plain := "paqet://k1@1.2.3.4:8080#A\n\n# comment\npaqet://k2@5.6.7.8:9090\npaqet://k3@9.9.9.9:1#A\nnot-a-uri\n"
+
+	for name, body := range map[string]string{
+		"plain":      plain,
+		"base64":     base64.StdEncoding.EncodeToString([]byte(plain)),
+		"base64 url": base64.RawURLEncoding.EncodeToString([]byte(plain)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			entries, skipped := parseSubscription([]byte(body))
+			var refs []string
+			for ref := range entries {
+				refs = append(refs, ref)
+			}
+			if len(entries) != 3 {
+				t.Fatalf("got entries %v, want 3", refs)
+			}
+			for _, ref := range []string{"A", "A~2", "5.6.7.8:9090"} {
+				if entries[ref] == nil {
+					t.Errorf("missing entry %q in %v", ref, refs)
+				}
+			}
+			if entries["A~2"].Key != "k3" {
+				t.Errorf("A~2 key = %q, want k3", entries["A~2"].Key)
+			}
+			if len(skipped) != 1 {
+				t.Errorf("skipped = %v, want 1 entry", skipped)
+			}
+		})
+	}
+}
+
+func TestSyncSubscriptionAddsUpdatesRemoves(t *testing.T) {
+	feed := newFeedServer(t, "paqet://k1@1.2.3.4:8080?mode=fast#One\npaqet://k2@5.6.7.8:9090#Two\n")
+	s := tempStore(t)
+
+	if _, err := s.Create(&Profile{Name: "Local", Host: "10.0.0.1", Port: 1, Key: "x"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	sub, err := s.AddSubscription(&Subscription{Name: "Feed", URL: feed.URL})
+	if err != nil {
+		t.Fatalf("AddSubscription failed: %v", err)
+	}
+
+	res, err := s.SyncSubscription(context.Background(), sub.ID)
+	if err != nil {
+		t.Fatalf("first sync failed: %v", err)
+	}
+	if res.Added != 2 || res.Updated != 0 || res.Removed != 0 {
+		t.Errorf("first sync result = %+v", res)
+	}
+	first := subscriptionProfiles(s, sub.ID)
+	if first["One"] == nil || first["One"].Mode != "fast" || first["Two"] == nil {
+		t.Fatalf("profiles after first sync = %v", first)
+	}
+
+	feed.set("paqet://k1@1.2.3.4:8080?mode=fast2#One\npaqet://k3@9.9.9.9:1#Three\n", 0)
+	res, err = s.SyncSubscription(context.Background(), sub.ID)
+	if err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	if res.Added != 1 || res.Updated != 1 || res.Removed != 1 {
+		t.Errorf("second sync result = %+v", res)
+	}
+	second := subscriptionProfiles(s, sub.ID)
+	if second["One"].ID != first["One"].ID {
+		t.Error("updated profile should keep its ID")
+	}
+	if second["One"].Mode != "fast2" || second["Three"] == nil || second["Two"] != nil {
+		t.Errorf("profiles after second sync = %v", second)
+	}
+	if len(s.List()) != 3 {
+		t.Errorf("List has %d profiles, want 3 (local profile untouched)", len(s.List()))
+	}
+
+	subs := s.ListSubscriptions()
+	if subs[0].LastError != "" || subs[0].LastCount != 2 || subs[0].LastStatus != http.StatusOK || subs[0].LastSync.IsZero() {
+		t.Errorf("subscription status = %+v", subs[0])
+	}
+}
+
+func TestSyncSubscriptionPreservesOverrides(t *testing.T) {
+	feed := newFeedServer(t, "paqet://k1@1.2.3.4:8080?mode=fast&conn=1#One\n")
+	s := tempStore(t)
+	sub, err := s.AddSubscription(&Subscription{URL: feed.URL})
+	if err != nil {
+		t.Fatalf("AddSubscription failed: %v", err)
+	}
+	if _, err := s.SyncSubscription(context.Background(), sub.ID); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	p := subscriptionProfiles(s, sub.ID)["One"]
+	p.Mode = "normal"
+	// Local only, not overrides
+	p.Group, p.Tags = "Work", []string{"eu"}
+	p.SubscriptionID = "" // the frontend may not send subscription fields back
+	updated, err := s.Update(p)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.SubscriptionID != sub.ID || !reflect.DeepEqual(updated.Overrides, []string{"mode"}) {
+		t.Errorf("after Update: subscription %q, overrides %v", updated.SubscriptionID, updated.Overrides)
+	}
+
+	feed.set("paqet://k2@1.2.3.4:8080?mode=fast3&conn=2#One\n", 0)
+	if _, err := s.SyncSubscription(context.Background(), sub.ID); err != nil {
+		t.Fatalf("resync failed: %v", err)
+	}
+	got := subscriptionProfiles(s, sub.ID)["One"]
+	if got.Mode != "normal" {
+		t.Errorf("Mode = %q, want local override %q", got.Mode, "normal")
+	}
+	if got.Key != "k2" || got.Conn != 2 {
+		t.Errorf("non-overridden fields not synced: key %q, conn %d", got.Key, got.Conn)
+	}
+	if got.Group != "Work" || !reflect.DeepEqual(got.Tags, []string{"eu"}) {
+		t.Errorf("group %q, tags %v not kept across sync", got.Group, got.Tags)
+	}
+}
+
+func TestSyncSubscriptionFailureKeepsProfiles(t *testing.T) {
+	feed := newFeedServer(t, "paqet://k1@1.2.3.4:8080#One\n")
+	s := tempStore(t)
+	sub, err := s.AddSubscription(&Subscription{URL: feed.URL})
+	if err != nil {
+		t.Fatalf("AddSubscription failed: %v", err)
+	}
+	if _, err := s.SyncSubscription(context.Background(), sub.ID); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	feed.set("", http.StatusInternalServerError)
+	if _, err := s.SyncSubscription(context.Background(), sub.ID); err == nil {
+		t.Error("expected error for failed fetch")
+	}
+	feed.set("garbage", 0)
+	if _, err := s.SyncSubscription(context.Background(), sub.ID); err == nil {
+		t.Error("expected error for feed without profiles")
+	}
+
+	if len(subscriptionProfiles(s, sub.ID)) != 1 {
+		t.Error("failed syncs should keep existing profiles")
+	}
+	got := s.ListSubscriptions()[0]
+	if got.LastError == "" || got.LastCount != 1 {
+		t.Errorf("subscription status = %+v", got)
+	}
+}
+
+func TestSyncSubscriptionEmptied(t *testing.T) {
+	feed := newFeedServer(t, "paqet://k1@1.2.3.4:8080#One\npaqet://k2@5.6.7.8:8080#Two\n")
+	s := tempStore(t)
+	sub, err := s.AddSubscription(&Subscription{URL: feed.URL})
+	if err != nil {
+		t.Fatalf("AddSubscription failed: %v", err)
+	}
+	if _, err := s.SyncSubscription(context.Background(), sub.ID); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	two := subscriptionProfiles(s, sub.ID)["Two"]
+	two.Mode = "normal"
+	if _, err := s.Update(two); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	// A feed whose entries all fail is an error; a feed with none is not.
+	feed.set("not a link\n", 0)
+	if _, err := s.SyncSubscription(context.Background(), sub.ID); err == nil {
+		t.Error("expected error for feed without valid profiles")
+	}
+	feed.set("# no servers right now\n", 0)
+	res, err := s.SyncSubscription(context.Background(), sub.ID)
+	if err != nil {
+		t.Fatalf("sync of empty feed failed: %v", err)
+	}
+	if res.Removed != 1 || res.Kept != 1 {
+		t.Errorf("result = %+v, want 1 removed and 1 kept", res)
+	}
+	if got := subscriptionProfiles(s, sub.ID); len(got) != 0 {
+		t.Errorf("profiles still synced: %v", got)
+	}
+	kept, err := s.Get(two.ID)
+	if err != nil || kept.Mode != "normal" || kept.SubscriptionRef != "" || len(kept.Overrides) != 0 {
+		t.Errorf("edited profile = %+v, %v; want kept as a local profile", kept, err)
+	}
+	if got := s.ListSubscriptions()[0]; got.LastError != "" || got.LastCount != 0 {
+		t.Errorf("subscription status = %+v", got)
+	}
+}
+
+func TestSyncSubscriptionTooLarge(t *testing.T) {
+	feed := newFeedServer(t, "paqet://k1@1.2.3.4:8080#One\npaqet://k2@5.6.7.8:8080#Two\n")
+	s := tempStore(t)
+	sub, err := s.AddSubscription(&Subscription{URL: feed.URL})
+	if err != nil {
+		t.Fatalf("AddSubscription failed: %v", err)
+	}
+	if _, err := s.SyncSubscription(context.Background(), sub.ID); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	// Cut at the limit, this would drop Two and leave One's line half read.
+	line := "paqet://k1@1.2.3.4:8080#One\n"
+	body := strings.Repeat("# padding\n", (maxSubscriptionSize-len(line))/10) + line + "paqet://k2@5.6.7.8:8080#Two\n"
+	feed.set(body, 0)
+	if _, err := s.SyncSubscription(context.Background(), sub.ID); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Fatalf("err = %v, want size limit error", err)
+	}
+
+	if got := subscriptionProfiles(s, sub.ID); len(got) != 2 {
+		t.Errorf("profiles after oversized sync = %d, want 2", len(got))
+	}
+	if got := s.ListSubscriptions()[0]; !strings.Contains(got.LastError, "larger than") || got.LastCount != 2 {
+		t.Errorf("subscription status = %+v", got)
+	}
+}
+
+func TestSubscriptionPersistenceAndDelete(t *testing.T) {
+	feed := newFeedServer(t, "paqet://k1@1.2.3.4:8080#One\n")
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	if _, err := s.AddSubscription(&Subscription{URL: "ftp://example.com/list"}); err == nil {
+		t.Error("expected error for non-http URL")
+	}
+	sub, err := s.AddSubscription(&Subscription{URL: feed.URL, Interval: 60})
+	if err != nil {
+		t.Fatalf("AddSubscription failed: %v", err)
+	}
+	if _, err := s.SyncSubscription(context.Background(), sub.ID); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	s2, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("second NewStore failed: %v", err)
+	}
+	subs := s2.ListSubscriptions()
+	if len(subs) != 1 || subs[0].Interval != 60 || subs[0].LastCount != 1 {
+		t.Fatalf("persisted subscriptions = %+v", subs)
+	}
+
+	if err := s2.DeleteSubscription(sub.ID, true); err != nil {
+		t.Fatalf("DeleteSubscription failed: %v", err)
+	}
+	profiles := s2.List()
+	if len(profiles) != 1 || profiles[0].SubscriptionID != "" {
+		t.Errorf("kept profiles = %+v", profiles)
+	}
+	if err := s2.DeleteSubscription(sub.ID, false); err == nil {
+		t.Error("expected error for unknown subscription")
+	}
+}
+
+func TestUnreadableSubscriptionsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "subscriptions.json")
+	if err := os.WriteFile(path, []byte(`[{"id": "x", "ur`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore should start without subscriptions, got: %v", err)
+	}
+	if subs := s.ListSubscriptions(); len(subs) != 0 {
+		t.Errorf("subscriptions = %+v, want none", subs)
+	}
+	resets := s.Resets()
+	if len(resets) != 1 || resets[0].File != "subscriptions.json" || !strings.Contains(resets[0].Reason, "failed to parse subscriptions") {
+		t.Fatalf("Resets = %+v", resets)
+	}
+	if data, err := os.ReadFile(resets[0].CorruptPath); err != nil || string(data) != `[{"id": "x", "ur` {
+		t.Errorf("unreadable file not kept at %s: %v", resets[0].CorruptPath, err)
+	}
+
+	if _, err := s.AddSubscription(&Subscription{URL: "https://example.com/list"}); err != nil {
+		t.Fatalf("AddSubscription failed: %v", err)
+	}
+	s2, err := NewStore(dir)
+	if err != nil || len(s2.Resets()) != 0 || len(s2.ListSubscriptions()) != 1 {
+		t.Errorf("reopened store: err = %v", err)
+	}
+}
+
+func TestSyncDue(t *testing.T) {
+	feed := newFeedServer(t, "paqet://k1@1.2.3.4:8080#One\n")
+	s := tempStore(t)
+	scheduled, err := s.AddSubscription(&Subscription{URL: feed.URL, Interval: 30})
+	if err != nil {
+		t.Fatalf("AddSubscription failed: %v", err)
+	}
+	if _, err := s.AddSubscription(&Subscription{URL: feed.URL}); err != nil {
+		t.Fatalf("AddSubscription failed: %v", err)
+	}
+
+	var synced []string
+	onSync := func(res *SyncResult, err error) {
+		if err != nil {
+			t.Errorf("sync failed: %v", err)
+			return
+		}
+		synced = append(synced, res.SubscriptionID)
+	}
+
+	s.SyncDue(context.Background(), time.Now(), onSync)
+	if !reflect.DeepEqual(synced, []string{scheduled.ID}) {
+		t.Fatalf("synced %v, want only the scheduled subscription", synced)
+	}
+
+	s.SyncDue(context.Background(), time.Now().Add(10*time.Minute), onSync)
+	if len(synced) != 1 {
+		t.Error("subscription synced again before its interval elapsed")
+	}
+	s.SyncDue(context.Background(), time.Now().Add(31*time.Minute), onSync)
+	if len(synced) != 2 {
+		t.Error("subscription not synced after its interval elapsed")
+	}
+}
@@ -0,0 +1,463 @@
+package profile
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// With encryption enabled, each profile's secret fields (Key, SocksUser and
+// SocksPass) are stored in profiles.json as Secrets: the unpadded base64url
+// encoding of nonce (24 bytes) | ciphertext, sealed with XChaCha20-Poly1305
+// under a random data key, with the profile ID as additional data. The data
+// key is kept in vault.json, sealed under a key derived from the master
+// passphrase with Argon2id. All other fields stay readable, so profiles can
+// be listed while the store is locked.
+
+var (
+	// ErrLocked is returned when an operation needs profile secrets while
+	// the store is encrypted and not unlocked.
+	ErrLocked = errors.New("profile store is locked")
+
+	// ErrWrongPassphrase is returned when the master passphrase does not
+	// open the vault.
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+)
+
+const (
+	vaultVersion = 1
+	vaultKeySize = chacha20poly1305.KeySize
+
+	// Argon2id parameters for new vaults (RFC 9106 second recommendation).
+	// Existing vaults keep the parameters they were created with.
+	vaultTime    = 3
+	vaultMemory  = 64 * 1024 // KiB
+	vaultThreads = 4
+
+	// Bounds on Argon2id parameters read from vault.json or a protected
+	// bundle, checked before deriving a key: wide enough for anything this
+	// app writes, narrow enough that a damaged or crafted file cannot hang
+	// the app or exhaust its memory.
+	kdfMinSalt    = 16
+	kdfMaxSalt    = 64
+	kdfMaxTime    = 10
+	kdfMaxMemory  = 256 * 1024 // KiB
+	kdfMaxThreads = 16
+)
+
+// vaultFile is the contents of vault.json.
+type vaultFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+	// Keys are data keys sealed under the passphrase key, current first.
+	// A second key is only present while ChangePassphrase re-encrypts
+	// profiles, so an interrupted change can still be unlocked.
+	Keys [][]byte `json:"keys"`
+}
+
+// secretFields are the profile fields that are encrypted at rest.
+type secretFields struct {
+	Key       string `json:"key,omitempty"`
+	SocksUser string `json:"socks_user,omitempty"`
+	SocksPass string `json:"socks_pass,omitempty"`
+}
+
+// EncryptionEnabled reports whether profile secrets are encrypted at rest.
+func (s *Store) EncryptionEnabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.vault != nil
+}
+
+// Locked reports whether the store is encrypted and not yet unlocked.
+// Profiles can be listed, but not read, edited or exported, while locked.
+func (s *Store) Locked() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.vault != nil && s.dataKey == nil
+}
+
+// Unlock decrypts profile secrets with the master passphrase for the rest
+// of the session.
+func (s *Store) Unlock(passphrase string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.vault == nil {
+		return fmt.Errorf("encryption is not enabled")
+	}
+	if s.dataKey != nil {
+		return nil
+	}
+	keys, err := s.vault.open(passphrase)
+	if err != nil {
+		return err
+	}
+	return s.unlock(keys)
+}
+
+// unlock decrypts every profile's secrets with keys, current first. Must be
+// called with s.mu held.
+func (s *Store) unlock(keys [][]byte) error {
+	profiles := make([]*Profile, len(s.profiles))
+	for i, p := range s.profiles {
+		cp := *p
+		if err := openSecrets(&cp, keys); err != nil {
+			return err
+		}
+		profiles[i] = &cp
+	}
+	s.profiles = profiles
+	s.disk = append([]*Profile(nil), profiles...)
+	s.dataKey = keys[0]
+
+	// Finish an interrupted passphrase change
+	if len(s.vault.Keys) > 1 {
+		if err := s.save(); err != nil {
+			return err
+		}
+		s.vault.Keys = s.vault.Keys[:1]
+		return s.saveVault()
+	}
+	return nil
+}
+
+// Lock forgets the data key, so secrets need the passphrase again.
+func (s *Store) Lock() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.vault == nil || s.dataKey == nil {
+		return nil
+	}
+	profiles, err := s.sealedProfiles()
+	if err != nil {
+		return err
+	}
+	s.profiles = profiles
+	s.disk = append([]*Profile(nil), profiles...)
+	s.dataKey = nil
+	return nil
+}
+
+// EnableEncryption encrypts profile secrets at rest under a new master
+// passphrase. Existing backups hold plaintext secrets and are replaced.
+func (s *Store) EnableEncryption(passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("passphrase is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.vault != nil {
+		return fmt.Errorf("encryption is already enabled")
+	}
+	dataKey := make([]byte, vaultKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	vault, err := newVault(passphrase, dataKey)
+	if err != nil {
+		return err
+	}
+
+	// vault.json goes first: profiles.json must never hold secrets that
+	// nothing on disk can decrypt.
+	s.vault, s.dataKey = vault, dataKey
+	if err := s.saveVault(); err != nil {
+		s.vault, s.dataKey = nil, nil // Roll back
+		return err
+	}
+	return s.rewriteProfiles()
+}
+
+// DisableEncryption stores profile secrets in plaintext again. The
+// passphrase is required even if the store is unlocked.
+func (s *Store) DisableEncryption(passphrase string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.vault == nil {
+		return fmt.Errorf("encryption is not enabled")
+	}
+	keys, err := s.vault.open(passphrase)
+	if err != nil {
+		return err
+	}
+	if s.dataKey == nil {
+		if err := s.unlock(keys); err != nil {
+			return err
+		}
+	}
+
+	// profiles.json goes first, for the same reason as in EnableEncryption.
+	vault, dataKey := s.vault, s.dataKey
+	s.vault, s.dataKey = nil, nil
+	if err := s.rewriteProfiles(); err != nil {
+		s.vault, s.dataKey = vault, dataKey // Roll back
+		return err
+	}
+	if err := os.Remove(s.vaultPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove vault: %w", err)
+	}
+	return nil
+}
+
+// ChangePassphrase re-encrypts profile secrets under a new data key and
+// master passphrase.
+func (s *Store) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	if newPassphrase == "" {
+		return fmt.Errorf("new passphrase is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.vault == nil {
+		return fmt.Errorf("encryption is not enabled")
+	}
+	keys, err := s.vault.open(oldPassphrase)
+	if err != nil {
+		return err
+	}
+	if s.dataKey == nil {
+		if err := s.unlock(keys); err != nil {
+			return err
+		}
+	}
+
+	dataKey := make([]byte, vaultKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	// Keep the old data key in the vault until profiles.json is rewritten.
+	vault, err := newVault(newPassphrase, dataKey, s.dataKey)
+	if err != nil {
+		return err
+	}
+	oldVault := s.vault
+	s.vault = vault
+	if err := s.saveVault(); err != nil {
+		s.vault = oldVault // Roll back
+		return err
+	}
+
+	s.dataKey = dataKey
+	if err := s.rewriteProfiles(); err != nil {
+		return err
+	}
+	s.vault.Keys = s.vault.Keys[:1]
+	return s.saveVault()
+}
+
+// rewriteProfiles saves profiles.json after its encryption changed and
+// replaces the backups, which are unreadable or hold plaintext secrets. The
+// copies kept beside profiles.json are deleted for the same reason. Must be
+// called with s.mu held.
+func (s *Store) rewriteProfiles() error {
+	for _, path := range append(s.listBackups(), s.listCopies()...) {
+		os.Remove(path)
+	}
+	s.lastBackup = time.Time{}
+	return s.save()
+}
+
+// checkUnlocked returns ErrLocked if profile secrets are not available. Must
+// be called with s.mu held.
+func (s *Store) checkUnlocked() error {
+	if s.vault != nil && s.dataKey == nil {
+		return ErrLocked
+	}
+	return nil
+}
+
+// sealedProfiles returns the profiles as stored on disk: with secrets moved
+// into Secrets if encryption is enabled. Profiles of a locked store are
+// already sealed, except ones another program wrote to profiles.json in
+// plaintext; those are kept as they were on disk and sealed by the first save
+// after Unlock. Must be called with s.mu held.
+func (s *Store) sealedProfiles() ([]*Profile, error) {
+	if s.vault == nil || s.dataKey == nil {
+		return s.profiles, nil
+	}
+	profiles := make([]*Profile, len(s.profiles))
+	for i, p := range s.profiles {
+		cp := *p
+		if err := sealSecrets(&cp, s.dataKey); err != nil {
+			return nil, err
+		}
+		profiles[i] = &cp
+	}
+	return profiles, nil
+}
+
+// sealSecrets moves p's secret fields into p.Secrets.
+func sealSecrets(p *Profile, key []byte) error {
+	plaintext, err := json.Marshal(secretFields{p.Key, p.SocksUser, p.SocksPass})
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(key, plaintext, []byte(p.ID))
+	if err != nil {
+		return err
+	}
+	p.Secrets = base64.RawURLEncoding.EncodeToString(sealed)
+	p.Key, p.SocksUser, p.SocksPass = "", "", ""
+	return nil
+}
+
+// openSecrets restores p's secret fields from p.Secrets, trying each key.
+// Profiles without Secrets were written before encryption was enabled and
+// are left as they are.
+func openSecrets(p *Profile, keys [][]byte) error {
+	if p.Secrets == "" {
+		return nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(p.Secrets)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt profile %q: %w", p.Name, err)
+	}
+	for _, key := range keys {
+		plaintext, err := unseal(key, data, []byte(p.ID))
+		if err != nil {
+			continue
+		}
+		var secrets secretFields
+		if err := json.Unmarshal(plaintext, &secrets); err != nil {
+			return fmt.Errorf("failed to decrypt profile %q: %w", p.Name, err)
+		}
+		p.Key, p.SocksUser, p.SocksPass = secrets.Key, secrets.SocksUser, secrets.SocksPass
+		p.Secrets = ""
+		return nil
+	}
+	return fmt.Errorf("failed to decrypt profile %q: data is corrupted", p.Name)
+}
+
+// newVault seals data keys, current first, under a passphrase.
+func newVault(passphrase string, dataKeys ...[]byte) (*vaultFile, error) {
+	v := &vaultFile{
+		Version: vaultVersion,
+		Salt:    make([]byte, 16),
+		Time:    vaultTime,
+		Memory:  vaultMemory,
+		Threads: vaultThreads,
+	}
+	if _, err := rand.Read(v.Salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	kek := v.deriveKey(passphrase)
+	for _, dk := range dataKeys {
+		sealed, err := seal(kek, dk, v.Salt)
+		if err != nil {
+			return nil, err
+		}
+		v.Keys = append(v.Keys, sealed)
+	}
+	return v, nil
+}
+
+// open returns the vault's data keys, current first.
+func (v *vaultFile) open(passphrase string) ([][]byte, error) {
+	kek := v.deriveKey(passphrase)
+	keys := make([][]byte, 0, len(v.Keys))
+	for _, sealed := range v.Keys {
+		dk, err := unseal(kek, sealed, v.Salt)
+		if err != nil {
+			return nil, ErrWrongPassphrase
+		}
+		keys = append(keys, dk)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("vault has no keys")
+	}
+	return keys, nil
+}
+
+// checkKDF checks that the salt and Argon2id parameters are within bounds.
+func (v *vaultFile) checkKDF() error {
+	switch {
+	case len(v.Salt) < kdfMinSalt || len(v.Salt) > kdfMaxSalt:
+		return fmt.Errorf("salt must be %d to %d bytes", kdfMinSalt, kdfMaxSalt)
+	case v.Time < 1 || v.Time > kdfMaxTime:
+		return fmt.Errorf("time must be 1 to %d", kdfMaxTime)
+	case v.Threads < 1 || v.Threads > kdfMaxThreads:
+		return fmt.Errorf("threads must be 1 to %d", kdfMaxThreads)
+	case v.Memory < 8*uint32(v.Threads) || v.Memory > kdfMaxMemory:
+		return fmt.Errorf("memory must be %d to %d KiB", 8*uint32(v.Threads), kdfMaxMemory)
+	}
+	return nil
+}
+
+func (v *vaultFile) deriveKey(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), v.Salt, v.Time, v.Memory, v.Threads, vaultKeySize)
+}
+
+// seal encrypts plaintext and returns nonce | ciphertext.
+func seal(key, plaintext, ad []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+// unseal decrypts nonce | ciphertext from seal.
+func unseal(key, data, ad []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], ad)
+}
+
+func (s *Store) loadVault() error {
+	data, err := os.ReadFile(s.vaultPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read vault: %w", err)
+	}
+
+	var vault vaultFile
+	if err := json.Unmarshal(data, &vault); err != nil {
+		return fmt.Errorf("failed to parse vault: %w", err)
+	}
+	if vault.Version != vaultVersion {
+		return fmt.Errorf("unsupported vault version %d", vault.Version)
+	}
+	if err := vault.checkKDF(); err != nil {
+		return fmt.Errorf("invalid vault: %w", err)
+	}
+
+	s.vault = &vault
+	return nil
+}
+
+func (s *Store) saveVault() error {
+	data, err := json.MarshalIndent(s.vault, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal vault: %w", err)
+	}
+
+	return writeFileAtomic(s.vaultPath, data, 0600)
+}
@@ -0,0 +1,325 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// encryptedStore returns a store directory with one profile and encryption
+// enabled under "master".
+func encryptedStore(t *testing.T) (string, *Profile) {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	p, err := s.Create(&Profile{Name: "Office", Host: "1.2.3.4", Port: 8080, Key: "topsecret", SocksUser: "alice", SocksPass: "hunter2"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := s.EnableEncryption("master"); err != nil {
+		t.Fatalf("EnableEncryption failed: %v", err)
+	}
+	return dir, p
+}
+
+func TestEncryptionAtRest(t *testing.T) {
+	dir, _ := encryptedStore(t)
+
+	files := append([]string{filepath.Join(dir, "profiles.json")}, (&Store{dir: dir}).listBackups()...)
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{"topsecret", "alice", "hunter2"} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("%s contains %q in plaintext", filepath.Base(path), secret)
+			}
+		}
+		if !strings.Contains(string(data), "Office") {
+			t.Errorf("%s should keep non-secret fields readable", filepath.Base(path))
+		}
+		if info, _ := os.Stat(path); runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Errorf("%s mode = %v, want 0600", filepath.Base(path), info.Mode().Perm())
+		}
+	}
+}
+
+func TestEnableEncryptionRemovesPlaintextCopies(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if _, err := s.Create(&Profile{Name: "Office", Host: "1.2.3.4", Port: 8080, Key: "topsecret"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "profiles.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copies := []string{"profiles.json.v0.bak", "profiles.json.corrupt-20260101-000000"}
+	for _, name := range copies {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.EnableEncryption("master"); err != nil {
+		t.Fatalf("EnableEncryption failed: %v", err)
+	}
+	for _, name := range copies {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after enabling encryption", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "profiles.json")); err != nil {
+		t.Errorf("profiles.json: %v", err)
+	}
+}
+
+func TestLockedStore(t *testing.T) {
+	dir, p := encryptedStore(t)
+
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if !s.EncryptionEnabled() || !s.Locked() {
+		t.Fatal("reopened store should be encrypted and locked")
+	}
+
+	// Listing works, secrets are hidden
+	list := s.List()
+	if len(list) != 1 || list[0].Name != "Office" || list[0].Key != "" {
+		t.Errorf("locked List = %+v", list)
+	}
+	if _, err := s.Get(p.ID); !errors.Is(err, ErrLocked) {
+		t.Errorf("Get: err = %v, want ErrLocked", err)
+	}
+	if _, err := s.Create(&Profile{Name: "New", Host: "1.2.3.4", Port: 1, Key: "k"}); !errors.Is(err, ErrLocked) {
+		t.Errorf("Create: err = %v, want ErrLocked", err)
+	}
+	if _, err := s.ExportToURI(p.ID, false); !errors.Is(err, ErrLocked) {
+		t.Errorf("ExportToURI: err = %v, want ErrLocked", err)
+	}
+
+	if err := s.Unlock("wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Unlock(wrong): err = %v, want ErrWrongPassphrase", err)
+	}
+	if err := s.Unlock("master"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	got, err := s.Get(p.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Key != "topsecret" || got.SocksUser != "alice" || got.SocksPass != "hunter2" || got.Secrets != "" {
+		t.Errorf("unlocked profile = %+v", got)
+	}
+
+	// Locking again hides secrets without touching disk
+	if err := s.Lock(); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if _, err := s.Get(p.ID); !errors.Is(err, ErrLocked) {
+		t.Errorf("Get after Lock: err = %v, want ErrLocked", err)
+	}
+
+	// Deleting does not need secrets
+	if err := s.Delete(p.ID); err != nil {
+		t.Errorf("Delete while locked failed: %v", err)
+	}
+}
+
+func TestLockedStoreKeepsPlaintextProfiles(t *testing.T) {
+	dir, p := encryptedStore(t)
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	// Another program adds a profile without encrypting it.
+	path := filepath.Join(dir, "profiles.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file profilesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	writeExternal(t, dir, append(file.Profiles, &Profile{ID: "ext", Name: "External", Host: "5.6.7.8", Port: 1, Key: "plainkey"})...)
+	if _, err := s.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	// Unrelated saves still work while locked, and leave it as it was.
+	if err := s.SetFavorite(p.ID, true); err != nil {
+		t.Fatalf("SetFavorite while locked failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "plainkey") || strings.Contains(string(data), "topsecret") {
+		t.Errorf("locked save changed secrets on disk: %s", data)
+	}
+
+	// The first save after unlocking seals it.
+	if err := s.Unlock("master"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if err := s.SetFavorite(p.ID, false); err != nil {
+		t.Fatalf("SetFavorite failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "plainkey") {
+		t.Error("profiles.json still holds the external profile's key in plaintext")
+	}
+	if got, err := s.Get("ext"); err != nil || got.Key != "plainkey" {
+		t.Errorf("external profile = %+v, %v", got, err)
+	}
+}
+
+func TestMalformedVault(t *testing.T) {
+	dir, _ := encryptedStore(t)
+	path := filepath.Join(dir, "vault.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, edit := range map[string]func(v *vaultFile){
+		"zero time":    func(v *vaultFile) { v.Time = 0 },
+		"huge memory":  func(v *vaultFile) { v.Memory = 1 << 31 },
+		"zero threads": func(v *vaultFile) { v.Threads = 0 },
+		"short salt":   func(v *vaultFile) { v.Salt = v.Salt[:4] },
+	} {
+		var v vaultFile
+		if err := json.Unmarshal(data, &v); err != nil {
+			t.Fatal(err)
+		}
+		edit(&v)
+		bad, _ := json.Marshal(&v)
+		if err := os.WriteFile(path, bad, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewStore(dir); err == nil || !strings.Contains(err.Error(), "invalid vault") {
+			t.Errorf("%s: NewStore = %v, want an invalid vault error", name, err)
+		}
+	}
+}
+
+func TestSecretsAreBoundToProfile(t *testing.T) {
+	dir, _ := encryptedStore(t)
+	s, _ := NewStore(dir)
+	if err := s.Unlock("master"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(&Profile{Name: "Home", Host: "5.6.7.8", Port: 1, Key: "other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Swap the sealed secrets of the two profiles
+	s2, _ := NewStore(dir)
+	s2.profiles[0].Secrets, s2.profiles[1].Secrets = s2.profiles[1].Secrets, s2.profiles[0].Secrets
+	if err := s2.Unlock("master"); err == nil || !strings.Contains(err.Error(), "failed to decrypt") {
+		t.Errorf("Unlock with swapped secrets: err = %v", err)
+	}
+	if !s2.Locked() {
+		t.Error("store should stay locked after a failed Unlock")
+	}
+}
+
+func TestChangePassphrase(t *testing.T) {
+	dir, p := encryptedStore(t)
+	s, _ := NewStore(dir)
+
+	if err := s.ChangePassphrase("wrong", "new"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("ChangePassphrase(wrong): err = %v", err)
+	}
+	before, _ := os.ReadFile(filepath.Join(dir, "profiles.json"))
+	if err := s.ChangePassphrase("master", "new"); err != nil {
+		t.Fatalf("ChangePassphrase failed: %v", err)
+	}
+	after, _ := os.ReadFile(filepath.Join(dir, "profiles.json"))
+	if string(before) == string(after) {
+		t.Error("profiles.json should be re-encrypted")
+	}
+
+	s2, _ := NewStore(dir)
+	if err := s2.Unlock("master"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("old passphrase: err = %v, want ErrWrongPassphrase", err)
+	}
+	if err := s2.Unlock("new"); err != nil {
+		t.Fatalf("Unlock(new) failed: %v", err)
+	}
+	if got, _ := s2.Get(p.ID); got == nil || got.Key != "topsecret" {
+		t.Errorf("profile after change = %+v", got)
+	}
+	if len(s2.vault.Keys) != 1 {
+		t.Errorf("vault keeps %d keys, want 1", len(s2.vault.Keys))
+	}
+}
+
+func TestInterruptedPassphraseChange(t *testing.T) {
+	dir, p := encryptedStore(t)
+	s, _ := NewStore(dir)
+	keys, err := s.vault.open("master")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The new vault was written, profiles.json was not
+	vault, err := newVault("new", make([]byte, vaultKeySize), keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.vault = vault
+	if err := s.saveVault(); err != nil {
+		t.Fatal(err)
+	}
+
+	s2, _ := NewStore(dir)
+	if err := s2.Unlock("new"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if got, _ := s2.Get(p.ID); got == nil || got.Key != "topsecret" {
+		t.Errorf("profile = %+v", got)
+	}
+	if len(s2.vault.Keys) != 1 {
+		t.Errorf("vault keeps %d keys after unlock, want 1", len(s2.vault.Keys))
+	}
+}
+
+func TestDisableEncryption(t *testing.T) {
+	dir, p := encryptedStore(t)
+	s, _ := NewStore(dir)
+
+	if err := s.DisableEncryption("wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("DisableEncryption(wrong): err = %v", err)
+	}
+	if err := s.DisableEncryption("master"); err != nil {
+		t.Fatalf("DisableEncryption failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "vault.json")); !os.IsNotExist(err) {
+		t.Errorf("vault.json should be removed, stat err = %v", err)
+	}
+
+	s2, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if s2.EncryptionEnabled() || s2.Locked() {
+		t.Error("store should not be encrypted")
+	}
+	if got, _ := s2.Get(p.ID); got == nil || got.Key != "topsecret" {
+		t.Errorf("profile = %+v", got)
+	}
+	if err := s2.EnableEncryption(""); err == nil {
+		t.Error("expected error for empty passphrase")
+	}
+}
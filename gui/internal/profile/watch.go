@@ -0,0 +1,309 @@
+package profile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long Watch waits after the last event for
+// profiles.json before reloading it, so a file written in several steps is
+// read once, complete.
+const watchDebounce = 250 * time.Millisecond
+
+// Change describes how profiles changed because another program wrote
+// profiles.json, for example a config management tool.
+type Change struct {
+	Added     []string    `json:"added"` // profile IDs
+	Updated   []string    `json:"updated"`
+	Removed   []string    `json:"removed"`
+	Conflicts []*Conflict `json:"conflicts,omitempty"`
+
+	// Recovery is set if the file was unreadable and moved aside.
+	Recovery *Recovery `json:"recovery,omitempty"`
+}
+
+// Conflict is a profile that was changed both in the app and in
+// profiles.json. The app's version is kept; External is the version from the
+// file, nil if the file deleted the profile.
+type Conflict struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	External *Profile `json:"external,omitempty"`
+}
+
+func (c *Change) empty() bool {
+	return len(c.Added) == 0 && len(c.Updated) == 0 && len(c.Removed) == 0 && len(c.Conflicts) == 0 &&
+		c.Recovery == nil
+}
+
+// Watch reloads profiles.json whenever another program changes it, until
+// ctx is cancelled, and reports every reload that changed profiles or
+// failed to onChange (which may be nil). Changes found while saving are
+// reported too. The store directory is watched rather than the file, since
+// atomic writes, the store's own included, replace the file.
+func (s *Store) Watch(ctx context.Context, onChange func(*Change, error)) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch profiles: %w", err)
+	}
+	defer w.Close()
+	if err := w.Add(s.dir); err != nil {
+		return fmt.Errorf("failed to watch profiles: %w", err)
+	}
+
+	s.mu.Lock()
+	s.onChange = onChange
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.onChange = nil
+		s.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(ev.Name) == s.filePath {
+				timer.Reset(watchDebounce)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			if onChange != nil {
+				onChange(nil, err)
+			}
+		case <-timer.C:
+			change, err := s.Reload()
+			if onChange != nil && (err != nil || change != nil) {
+				onChange(change, err)
+			}
+		}
+	}
+}
+
+// Reload merges profiles.json into the store if another program changed it
+// since the store last read or wrote it. It returns nil if nothing changed.
+// Profiles changed only in the file take the file's version. The merged
+// profiles are saved if they differ from the file or the file is in an
+// older format.
+func (s *Store) Reload() (*Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, theirs, version, err := s.readChanged()
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && data == nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	old, oldDisk, oldHash := s.profiles, s.disk, s.diskHash
+	merged, conflicts := mergeProfiles(s.disk, s.profiles, theirs)
+	change := diffProfiles(s.profiles, merged)
+	change.Conflicts = conflicts
+	s.profiles = merged
+	s.disk, s.diskHash = theirs, sha256.Sum256(data)
+
+	if version != SchemaVersion {
+		if err := s.keepOriginal(version); err != nil {
+			s.profiles, s.disk, s.diskHash = old, oldDisk, oldHash // Roll back
+			return nil, err
+		}
+	}
+	if version < SchemaVersion || !sameProfiles(merged, theirs) {
+		if err := s.save(); err != nil {
+			s.profiles, s.disk, s.diskHash = old, oldDisk, oldHash // Roll back
+			return nil, err
+		}
+	}
+
+	if change.empty() {
+		return nil, nil
+	}
+	return change, nil
+}
+
+// mergeExternal merges changes another program wrote to profiles.json into
+// s.profiles before save overwrites the file. A file that cannot be read is
+// moved aside for manual repair and recorded in s.recovery, as when the store
+// is opened, and reported in the change. Must be called with s.mu held.
+func (s *Store) mergeExternal() (*Change, error) {
+	data, theirs, _, err := s.readChanged()
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && data == nil) {
+		return nil, nil
+	}
+	if err != nil {
+		rec := &Recovery{
+			File:        filepath.Base(s.filePath),
+			Reason:      err.Error(),
+			CorruptPath: s.filePath + ".corrupt-" + time.Now().UTC().Format(backupTimeFormat),
+			Profiles:    len(s.profiles),
+			External:    true,
+		}
+		if err := os.Rename(s.filePath, rec.CorruptPath); err != nil {
+			return nil, fmt.Errorf("failed to move unreadable profiles aside: %w", err)
+		}
+		s.recovery = rec
+		return &Change{Added: make([]string, 0), Updated: make([]string, 0), Removed: make([]string, 0), Recovery: rec}, nil
+	}
+
+	merged, conflicts := mergeProfiles(s.disk, s.profiles, theirs)
+	change := diffProfiles(s.profiles, merged)
+	change.Conflicts = conflicts
+	s.profiles = merged
+	return change, nil
+}
+
+// readChanged reads profiles.json if its contents changed since synced was
+// last called, opening secrets if the store is unlocked. It returns nil data
+// if the file is unchanged. Must be called with s.mu held.
+func (s *Store) readChanged() ([]byte, []*Profile, int, error) {
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if sha256.Sum256(data) == s.diskHash {
+		return nil, nil, 0, nil
+	}
+
+	profiles, version, err := decodeProfiles(data)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to parse profiles: %w", err)
+	}
+	if s.dataKey != nil {
+		for _, p := range profiles {
+			if err := openSecrets(p, [][]byte{s.dataKey}); err != nil {
+				return nil, nil, 0, err
+			}
+		}
+	}
+	return data, profiles, version, nil
+}
+
+// synced records data as the contents of profiles.json, matching the
+// current profiles. Must be called with s.mu held.
+func (s *Store) synced(data []byte) {
+	s.disk = append([]*Profile(nil), s.profiles...)
+	s.diskHash = sha256.Sum256(data)
+}
+
+// notify reports a change found while saving to the Watch callback.
+// Must be called with s.mu held.
+func (s *Store) notify(change *Change) {
+	if s.onChange != nil && change != nil && !change.empty() {
+		go s.onChange(change, nil)
+	}
+}
+
+// mergeProfiles merges two versions of the profiles that started out as
+// base: ours, in memory, and theirs, from profiles.json. A profile changed
+// on one side only takes that side's version. A profile changed differently
+// on both sides, or changed on one side and deleted on the other, is a
+// conflict, and ours is kept. The result is in theirs' order, followed by
+// the profiles only ours added.
+func mergeProfiles(base, ours, theirs []*Profile) ([]*Profile, []*Conflict) {
+	b, o, t := byID(base), byID(ours), byID(theirs)
+	result := make([]*Profile, 0, len(theirs)+len(ours))
+	var conflicts []*Conflict
+
+	for _, tp := range theirs {
+		bp, inBase := b[tp.ID]
+		op, inOurs := o[tp.ID]
+		switch {
+		case !inOurs && !inBase: // added to the file
+			result = append(result, tp)
+		case !inOurs: // deleted in the app
+			if !sameProfile(bp, tp) {
+				conflicts = append(conflicts, &Conflict{ID: tp.ID, Name: tp.Name, External: tp})
+			}
+		case inBase && sameProfile(op, bp):
+			result = append(result, tp)
+		case sameProfile(op, tp) || (inBase && sameProfile(tp, bp)):
+			result = append(result, op)
+		default:
+			conflicts = append(conflicts, &Conflict{ID: op.ID, Name: op.Name, External: tp})
+			result = append(result, op)
+		}
+	}
+	for _, op := range ours {
+		if _, ok := t[op.ID]; ok {
+			continue
+		}
+		bp, inBase := b[op.ID]
+		switch {
+		case !inBase: // added in the app
+			result = append(result, op)
+		case sameProfile(op, bp): // deleted from the file
+		default:
+			conflicts = append(conflicts, &Conflict{ID: op.ID, Name: op.Name})
+			result = append(result, op)
+		}
+	}
+	return result, conflicts
+}
+
+// diffProfiles returns the changes from old to new, by profile ID.
+func diffProfiles(old, new []*Profile) *Change {
+	c := &Change{Added: make([]string, 0), Updated: make([]string, 0), Removed: make([]string, 0)}
+	o, n := byID(old), byID(new)
+	for _, p := range new {
+		if op, ok := o[p.ID]; !ok {
+			c.Added = append(c.Added, p.ID)
+		} else if !sameProfile(op, p) {
+			c.Updated = append(c.Updated, p.ID)
+		}
+	}
+	for _, p := range old {
+		if _, ok := n[p.ID]; !ok {
+			c.Removed = append(c.Removed, p.ID)
+		}
+	}
+	return c
+}
+
+func byID(profiles []*Profile) map[string]*Profile {
+	m := make(map[string]*Profile, len(profiles))
+	for _, p := range profiles {
+		m[p.ID] = p
+	}
+	return m
+}
+
+// sameProfile compares profiles by their JSON form, so nil and empty
+// slices are equal.
+func sameProfile(a, b *Profile) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
+
+func sameProfiles(a, b []*Profile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameProfile(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
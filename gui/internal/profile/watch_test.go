@@ -0,0 +1,246 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeExternal replaces profiles.json the way another program would.
+func writeExternal(t *testing.T, dir string, profiles ...*Profile) {
+	t.Helper()
+	data, err := json.MarshalIndent(profilesFile{Version: SchemaVersion, Profiles: profiles}, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "profiles.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// watchedStore returns a store with profiles A and B, and its directory.
+func watchedStore(t *testing.T) (*Store, string, *Profile, *Profile) {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	a, err := s.Create(&Profile{Name: "A", Host: "1.1.1.1", Port: 1, Key: "a"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	b, err := s.Create(&Profile{Name: "B", Host: "2.2.2.2", Port: 2, Key: "b"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	return s, dir, a, b
+}
+
+func TestReloadExternalChange(t *testing.T) {
+	s, dir, a, _ := watchedStore(t)
+
+	a.Name = "A2"
+	c := &Profile{ID: "c", Name: "C", Host: "3.3.3.3", Port: 3, Key: "c"}
+	writeExternal(t, dir, a, c) // B deleted
+
+	change, err := s.Reload()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if change == nil || strings.Join(change.Added, ",") != "c" || strings.Join(change.Updated, ",") != a.ID ||
+		len(change.Removed) != 1 || len(change.Conflicts) != 0 {
+		t.Errorf("change = %+v, want C added, A updated, B removed", change)
+	}
+	if got := names(s.List()); strings.Join(got, ",") != "A2,C" {
+		t.Errorf("profiles = %v, want [A2 C]", got)
+	}
+
+	if change, err := s.Reload(); change != nil || err != nil {
+		t.Errorf("second Reload = %+v, %v; want nothing", change, err)
+	}
+}
+
+func TestReloadUnreadableFile(t *testing.T) {
+	s, dir, _, _ := watchedStore(t)
+	if err := os.WriteFile(filepath.Join(dir, "profiles.json"), []byte(`{"version": 1, "prof`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Reload(); err == nil {
+		t.Error("Reload of a partial file should fail")
+	}
+	if len(s.List()) != 2 {
+		t.Errorf("store has %d profiles after a failed reload, want 2", len(s.List()))
+	}
+}
+
+func TestSaveMergesExternalChange(t *testing.T) {
+	s, dir, a, b := watchedStore(t)
+
+	// Written by another program, and not reloaded yet
+	extA := *a
+	extA.Name = "A2"
+	c := &Profile{ID: "c", Name: "C", Host: "3.3.3.3", Port: 3, Key: "c"}
+	writeExternal(t, dir, &extA, b, c)
+
+	b.Mode = "fast3"
+	if _, err := s.Update(b); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got := names(s.List()); strings.Join(got, ",") != "A2,B,C" {
+		t.Errorf("profiles = %v, want [A2 B C]", got)
+	}
+
+	reopened, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if got := names(reopened.List()); strings.Join(got, ",") != "A2,B,C" {
+		t.Errorf("saved profiles = %v, want [A2 B C]", got)
+	}
+	if p, _ := reopened.Get(b.ID); p.Mode != "fast3" {
+		t.Errorf("Mode = %q, want the local edit", p.Mode)
+	}
+}
+
+func TestSaveReportsUnreadableExternalFile(t *testing.T) {
+	s, dir, _, _ := watchedStore(t)
+	changes := make(chan *Change, 1)
+	s.onChange = func(c *Change, err error) { changes <- c }
+	if err := os.WriteFile(filepath.Join(dir, "profiles.json"), []byte(`{"version": 1, "prof`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Create(&Profile{Name: "C", Host: "3.3.3.3", Port: 3, Key: "c"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	select {
+	case c := <-changes:
+		if c.Recovery == nil || !c.Recovery.External || c.Recovery.Profiles != 3 {
+			t.Errorf("change = %+v, want the unreadable file reported", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("unreadable file not reported")
+	}
+	rec := s.Recovery()
+	if rec == nil {
+		t.Fatal("Recovery() = nil after moving the file aside")
+	}
+	if data, err := os.ReadFile(rec.CorruptPath); err != nil || string(data) != `{"version": 1, "prof` {
+		t.Errorf("moved file = %q, %v; want the unreadable contents", data, err)
+	}
+}
+
+func TestMergeProfiles(t *testing.T) {
+	p := func(id, name string) *Profile {
+		return &Profile{ID: id, Name: name, Host: "1.2.3.4", Port: 1, Key: "k"}
+	}
+	base := []*Profile{p("1", "one"), p("2", "two"), p("3", "three")}
+
+	tests := []struct {
+		name      string
+		ours      []*Profile
+		theirs    []*Profile
+		want      string
+		conflicts string
+	}{
+		{"unchanged", base, base, "one,two,three", ""},
+		{"theirs edited", base, []*Profile{p("1", "uno"), p("2", "two"), p("3", "three")}, "uno,two,three", ""},
+		{"ours edited", []*Profile{p("1", "uno"), p("2", "two"), p("3", "three")}, base, "uno,two,three", ""},
+		{"both edited the same", []*Profile{p("1", "uno"), p("2", "two"), p("3", "three")},
+			[]*Profile{p("1", "uno"), p("2", "two"), p("3", "three")}, "uno,two,three", ""},
+		{"both edited differently", []*Profile{p("1", "ours"), p("2", "two"), p("3", "three")},
+			[]*Profile{p("1", "theirs"), p("2", "two"), p("3", "three")}, "ours,two,three", "1"},
+		{"different profiles edited", []*Profile{p("1", "uno"), p("2", "two"), p("3", "three")},
+			[]*Profile{p("1", "one"), p("2", "dos"), p("3", "three")}, "uno,dos,three", ""},
+		{"both added", append(base[:3:3], p("4", "ours")), append(base[:3:3], p("5", "theirs")), "one,two,three,theirs,ours", ""},
+		{"theirs deleted", base, base[:2], "one,two", ""},
+		{"ours deleted", base[1:], base, "two,three", ""},
+		{"theirs deleted, ours edited", []*Profile{p("1", "uno"), p("2", "two"), p("3", "three")}, base[1:], "two,three,uno", "1"},
+		{"ours deleted, theirs edited", base[1:], []*Profile{p("1", "uno"), p("2", "two"), p("3", "three")}, "two,three", "1"},
+		{"theirs reordered", base, []*Profile{base[2], base[0], base[1]}, "three,one,two", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, conflicts := mergeProfiles(base, tt.ours, tt.theirs)
+			if got := strings.Join(names(merged), ","); got != tt.want {
+				t.Errorf("merged = %s, want %s", got, tt.want)
+			}
+			var ids []string
+			for _, c := range conflicts {
+				ids = append(ids, c.ID)
+			}
+			if got := strings.Join(ids, ","); got != tt.conflicts {
+				t.Errorf("conflicts = %q, want %q", got, tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestReloadUpgradesOlderFile(t *testing.T) {
+	s, dir, _, _ := watchedStore(t)
+	path := filepath.Join(dir, "profiles.json")
+	if err := os.WriteFile(path, []byte(`[{"id":"old","name":"Old","host":"1.2.3.4","port":1,"key":"k","forward":["tcp:8080:internal:80"]}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if v, err := schemaVersion(data); err != nil || v != SchemaVersion {
+		t.Errorf("profiles.json version = %d, err = %v; want %d", v, err, SchemaVersion)
+	}
+	if _, err := os.Stat(path + ".v0.bak"); err != nil {
+		t.Errorf("original not kept: %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	s, dir, a, _ := watchedStore(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan *Change, 10)
+	started := make(chan struct{})
+	go func() {
+		close(started)
+		s.Watch(ctx, func(c *Change, err error) {
+			if err != nil {
+				t.Errorf("watch error: %v", err)
+				return
+			}
+			changes <- c
+		})
+	}()
+	<-started
+	time.Sleep(100 * time.Millisecond) // let the watcher start
+
+	// The store's own writes are not reported
+	if _, err := s.Create(&Profile{Name: "Mine", Host: "4.4.4.4", Port: 4, Key: "m"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	select {
+	case c := <-changes:
+		t.Fatalf("own write reported as %+v", c)
+	case <-time.After(3 * watchDebounce):
+	}
+
+	writeExternal(t, dir, a)
+	select {
+	case c := <-changes:
+		if len(c.Removed) != 2 {
+			t.Errorf("change = %+v, want 2 removed", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("external change not reported")
+	}
+	if got := names(s.List()); strings.Join(got, ",") != "A" {
+		t.Errorf("profiles = %v, want [A]", got)
+	}
+}
@@ -0,0 +1,88 @@
+// Package qr encodes share links as QR code PNGs and decodes them from
+// images, such as a screenshot taken on a phone.
+package qr
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg" // register JPEG for image.Decode
+	_ "image/png"  // register PNG for image.Decode
+	"io"
+	"os"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	zxingqr "github.com/makiuchi-d/gozxing/qrcode"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// DefaultSize is the PNG width and height in pixels used when size is 0.
+const DefaultSize = 512
+
+// levels maps error-correction level names to their go-qrcode values. Higher
+// levels survive more damage at the cost of a denser code.
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,     // ~7% recovery
+	"M": qrcode.Medium,  // ~15%
+	"Q": qrcode.High,    // ~25%
+	"H": qrcode.Highest, // ~30%
+}
+
+// Encode renders content as a QR code PNG. level is one of "L", "M", "Q" or
+// "H" (case-insensitive); empty means "M". size is the image width and height
+// in pixels; 0 means DefaultSize.
+func Encode(content, level string, size int) ([]byte, error) {
+	if level == "" {
+		level = "M"
+	}
+	rl, ok := levels[strings.ToUpper(level)]
+	if !ok {
+		return nil, fmt.Errorf("invalid error correction level %q, expected L, M, Q or H", level)
+	}
+	if size == 0 {
+		size = DefaultSize
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("invalid size %d", size)
+	}
+
+	png, err := qrcode.Encode(content, rl, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	return png, nil
+}
+
+// Decode finds and decodes a QR code in a PNG or JPEG image.
+func Decode(r io.Reader) (string, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %w", err)
+	}
+
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %w", err)
+	}
+	// TRY_HARDER helps with photos and screenshots where the code is small
+	// or surrounded by other content.
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	}
+	result, err := zxingqr.NewQRCodeReader().Decode(bmp, hints)
+	if err != nil {
+		return "", fmt.Errorf("no QR code found in image: %w", err)
+	}
+	return result.GetText(), nil
+}
+
+// DecodeFile decodes a QR code from a PNG or JPEG file.
+func DecodeFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open image: %w", err)
+	}
+	defer f.Close()
+
+	return Decode(f)
+}
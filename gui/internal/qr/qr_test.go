@@ -0,0 +1,105 @@
+package qr
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const link = "paqet://secretkey@10.0.0.1:9090?mode=fast3&conn=2#My%20Server"
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for _, level := range []string{"", "L", "m", "Q", "H"} {
+		data, err := Encode(link, level, 0)
+		if err != nil {
+			t.Fatalf("Encode(level %q) failed: %v", level, err)
+		}
+		cfg, err := png.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("level %q: output is not a PNG: %v", level, err)
+		}
+		if cfg.Width != DefaultSize {
+			t.Errorf("level %q: width = %d, want %d", level, cfg.Width, DefaultSize)
+		}
+
+		got, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Decode(level %q) failed: %v", level, err)
+		}
+		if got != link {
+			t.Errorf("level %q: decoded %q, want %q", level, got, link)
+		}
+	}
+}
+
+func TestEncodeRejects(t *testing.T) {
+	if _, err := Encode(link, "X", 0); err == nil {
+		t.Error("expected error for invalid level")
+	}
+	if _, err := Encode(link, "M", -1); err == nil {
+		t.Error("expected error for negative size")
+	}
+	if _, err := Encode(strings.Repeat("x", 5000), "H", 0); err == nil {
+		t.Error("expected error for content too long for a QR code")
+	}
+}
+
+// TestDecodeScreenshotJPEG decodes a small QR code placed off-center on a
+// larger canvas and saved as JPEG, like a cropped phone screenshot.
+func TestDecodeScreenshotJPEG(t *testing.T) {
+	data, err := Encode(link, "Q", 300)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	code, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode failed: %v", err)
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, 900, 1200))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(canvas, code.Bounds().Add(image.Pt(400, 700)), code, image.Point{}, draw.Src)
+
+	path := filepath.Join(t.TempDir(), "screenshot.jpg")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(f, canvas, &jpeg.Options{Quality: 80}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	got, err := DecodeFile(path)
+	if err != nil {
+		t.Fatalf("DecodeFile failed: %v", err)
+	}
+	if got != link {
+		t.Errorf("decoded %q, want %q", got, link)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, err := Decode(strings.NewReader("not an image")); err == nil {
+		t.Error("expected error for non-image input")
+	}
+
+	var buf bytes.Buffer
+	blank := image.NewGray(image.Rect(0, 0, 100, 100))
+	if err := png.Encode(&buf, blank); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(&buf); err == nil {
+		t.Error("expected error for image without a QR code")
+	}
+
+	if _, err := DecodeFile(filepath.Join(t.TempDir(), "missing.png")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
@@ -0,0 +1,266 @@
+package uri
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"net"
+	"strings"
+
+	"github.com/omid3098/autopaqet/gui/internal/config"
+	"github.com/omid3098/autopaqet/gui/internal/validate"
+)
+
+// Compact links have the form paqet://c/<payload>, where payload is the
+// unpadded base64url encoding of
+//
+//	version (1 byte) | fields | CRC-32 of the preceding bytes (4 bytes, BE)
+//
+// Each field is a uvarint tag (field number << 1 | wire type) followed by a
+// uvarint value (wire type 0) or a uvarint length and that many bytes (wire
+// type 1). Zero values are omitted. The wire type lets older builds skip
+// fields added later, which they report as warnings.
+const compactPrefix = "paqet://c/"
+
+const (
+	wireVarint = 0
+	wireBytes  = 1
+)
+
+// Field numbers. Never reuse or renumber a field; add new ones at the end.
+const (
+	fieldKey      = 1
+	fieldHost     = 2 // hostname
+	fieldHostIP   = 3 // 4 or 16 raw bytes
+	fieldPort     = 4
+	fieldName     = 5
+	fieldFallback = 6 // repeated host:port
+	fieldForward  = 32
+)
+
+// compactFields maps the remaining field numbers to PaqetURI fields. Names
+// are the query parameter names, used in errors and warnings.
+var compactFields = []struct {
+	num     uint64
+	name    string
+	str     func(p *PaqetURI) *string
+	integer func(p *PaqetURI) *int
+	boolean func(p *PaqetURI) *bool
+}{
+	{num: 7, name: "socks", str: func(p *PaqetURI) *string { return &p.Socks }},
+	{num: 8, name: "socks_user", str: func(p *PaqetURI) *string { return &p.SocksUser }},
+	{num: 9, name: "socks_pass", str: func(p *PaqetURI) *string { return &p.SocksPass }},
+	{num: 10, name: "mode", str: func(p *PaqetURI) *string { return &p.Mode }},
+	{num: 11, name: "conn", integer: func(p *PaqetURI) *int { return &p.Conn }},
+	{num: 12, name: "mtu", integer: func(p *PaqetURI) *int { return &p.MTU }},
+	{num: 13, name: "block", str: func(p *PaqetURI) *string { return &p.Block }},
+	{num: 14, name: "nodelay", integer: func(p *PaqetURI) *int { return &p.NoDelay }},
+	{num: 15, name: "interval", integer: func(p *PaqetURI) *int { return &p.Interval }},
+	{num: 16, name: "resend", integer: func(p *PaqetURI) *int { return &p.Resend }},
+	{num: 17, name: "nc", integer: func(p *PaqetURI) *int { return &p.NoCongestion }},
+	{num: 18, name: "wdelay", boolean: func(p *PaqetURI) *bool { return &p.WDelay }},
+	{num: 19, name: "acknodelay", boolean: func(p *PaqetURI) *bool { return &p.AckNoDelay }},
+	{num: 20, name: "rcvwnd", integer: func(p *PaqetURI) *int { return &p.RcvWnd }},
+	{num: 21, name: "sndwnd", integer: func(p *PaqetURI) *int { return &p.SndWnd }},
+	{num: 22, name: "dshard", integer: func(p *PaqetURI) *int { return &p.DShard }},
+	{num: 23, name: "pshard", integer: func(p *PaqetURI) *int { return &p.PShard }},
+	{num: 24, name: "dscp", integer: func(p *PaqetURI) *int { return &p.DSCP }},
+	{num: 25, name: "smuxbuf", integer: func(p *PaqetURI) *int { return &p.SmuxBuf }},
+	{num: 26, name: "streambuf", integer: func(p *PaqetURI) *int { return &p.StreamBuf }},
+	{num: 27, name: "tcpbuf", integer: func(p *PaqetURI) *int { return &p.TCPBuf }},
+	{num: 28, name: "udpbuf", integer: func(p *PaqetURI) *int { return &p.UDPBuf }},
+	{num: 29, name: "sockbuf", integer: func(p *PaqetURI) *int { return &p.SockBuf }},
+	{num: 30, name: "lf", str: func(p *PaqetURI) *string { return &p.LocalFlag }},
+	{num: 31, name: "rf", str: func(p *PaqetURI) *string { return &p.RemoteFlag }},
+	{num: 33, name: "log", str: func(p *PaqetURI) *string { return &p.Log }},
+	{num: 34, name: "sig", str: func(p *PaqetURI) *string { return &p.Signature }},
+	{num: 35, name: "pub", str: func(p *PaqetURI) *string { return &p.PublicKey }},
+}
+
+// IsCompact reports whether raw is a compact paqet:// link.
+func IsCompact(raw string) bool {
+	return strings.HasPrefix(strings.TrimSpace(raw), compactPrefix)
+}
+
+// Compact serializes the URI into the compact binary link form. It holds the
+// same information as String and parses back to an identical PaqetURI.
+func (p *PaqetURI) Compact() string {
+	buf := []byte{FormatVersion}
+	putBytes := func(num uint64, b []byte) {
+		if len(b) == 0 {
+			return
+		}
+		buf = binary.AppendUvarint(buf, num<<1|wireBytes)
+		buf = binary.AppendUvarint(buf, uint64(len(b)))
+		buf = append(buf, b...)
+	}
+	putVarint := func(num uint64, v int) {
+		if v == 0 {
+			return
+		}
+		buf = binary.AppendUvarint(buf, num<<1|wireVarint)
+		buf = binary.AppendUvarint(buf, uint64(v))
+	}
+
+	putBytes(fieldKey, []byte(p.Key))
+	if ip := net.ParseIP(p.Host); ip != nil && ip.String() == p.Host {
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		putBytes(fieldHostIP, ip)
+	} else {
+		putBytes(fieldHost, []byte(p.Host))
+	}
+	putVarint(fieldPort, p.Port)
+	putBytes(fieldName, []byte(p.Name))
+	for _, ep := range p.Fallbacks {
+		putBytes(fieldFallback, []byte(ep))
+	}
+	for _, f := range compactFields {
+		switch {
+		case f.str != nil:
+			putBytes(f.num, []byte(*f.str(p)))
+		case f.integer != nil:
+			putVarint(f.num, *f.integer(p))
+		case *f.boolean(p):
+			putVarint(f.num, 1)
+		}
+	}
+	putBytes(fieldForward, []byte(config.FormatForward(p.Forward)))
+
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+	return compactPrefix + base64.RawURLEncoding.EncodeToString(buf)
+}
+
+var errCompactTruncated = errors.New("invalid compact link: truncated")
+
+// parseCompact decodes a compact link. Unknown fields are skipped and
+// reported as warnings; malformed values are errors, as in the text form.
+func parseCompact(raw string) (*ParseResult, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.TrimPrefix(strings.TrimSpace(raw), compactPrefix), "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compact link: %w", err)
+	}
+	if len(data) < 5 {
+		return nil, errCompactTruncated
+	}
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, fmt.Errorf("invalid compact link: checksum mismatch")
+	}
+
+	var warnings []*validate.FieldError
+	version := int(body[0])
+	if version < 1 {
+		return nil, &validate.FieldError{Field: "v", Value: fmt.Sprint(version), Message: fmt.Sprintf("invalid format version %d", version)}
+	}
+	if version > FormatVersion {
+		warnings = append(warnings, &validate.FieldError{Field: "v", Value: fmt.Sprint(version),
+			Message: fmt.Sprintf("link format version %d is newer than this app supports (%d), some settings may be lost", version, FormatVersion)})
+	}
+
+	result := &PaqetURI{}
+	var errs validate.Errors
+	for rest := body[1:]; len(rest) > 0; {
+		tag, n := binary.Uvarint(rest)
+		if n <= 0 {
+			return nil, errCompactTruncated
+		}
+		rest = rest[n:]
+
+		var value uint64
+		var bytesVal []byte
+		switch tag & 1 {
+		case wireVarint:
+			value, n = binary.Uvarint(rest)
+			if n <= 0 {
+				return nil, errCompactTruncated
+			}
+			rest = rest[n:]
+		case wireBytes:
+			length, n := binary.Uvarint(rest)
+			if n <= 0 || uint64(len(rest)-n) < length {
+				return nil, errCompactTruncated
+			}
+			bytesVal = rest[n : n+int(length)]
+			rest = rest[n+int(length):]
+		}
+		if value > math.MaxInt32 {
+			return nil, fmt.Errorf("invalid compact link: value out of range")
+		}
+
+		num := tag >> 1
+		switch num {
+		case fieldKey:
+			result.Key = string(bytesVal)
+		case fieldHost:
+			result.Host = string(bytesVal)
+		case fieldHostIP:
+			if len(bytesVal) != net.IPv4len && len(bytesVal) != net.IPv6len {
+				errs.Add(&validate.FieldError{Field: "host", Message: "invalid IP address"})
+				continue
+			}
+			result.Host = net.IP(bytesVal).String()
+		case fieldPort:
+			result.Port = int(value)
+		case fieldName:
+			result.Name = string(bytesVal)
+		case fieldFallback:
+			result.Fallbacks = append(result.Fallbacks, string(bytesVal))
+		case fieldForward:
+			rules, err := config.ParseForward(string(bytesVal))
+			if err != nil {
+				errs.Add(&validate.FieldError{Field: "fwd", Value: string(bytesVal), Message: err.Error()})
+			}
+			result.Forward = rules
+		default:
+			known, err := setCompactField(result, num, tag&1, value, bytesVal)
+			errs.Add(err)
+			if !known {
+				warnings = append(warnings, &validate.FieldError{
+					Field:   fmt.Sprintf("field %d", num),
+					Message: fmt.Sprintf("unknown field %d is ignored", num),
+				})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if result.Key == "" {
+		return nil, fmt.Errorf("missing key")
+	}
+	if result.Host == "" {
+		return nil, fmt.Errorf("missing host")
+	}
+	if err := result.Validate(); err != nil {
+		return nil, err
+	}
+	return &ParseResult{URI: result, Warnings: warnings}, nil
+}
+
+// setCompactField stores a table field, reporting false for unknown numbers.
+func setCompactField(p *PaqetURI, num, wire, value uint64, b []byte) (bool, error) {
+	for _, f := range compactFields {
+		if f.num != num {
+			continue
+		}
+		if (f.str != nil) != (wire == wireBytes) {
+			return true, &validate.FieldError{Field: f.name, Message: fmt.Sprintf("invalid encoding for field %d", num)}
+		}
+		switch {
+		case f.str != nil:
+			*f.str(p) = string(b)
+		case f.integer != nil:
+			*f.integer(p) = int(value)
+		default:
+			*f.boolean(p) = value != 0
+		}
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,112 @@
+package uri
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompactRoundTrip(t *testing.T) {
+	tests := []string{
+		"paqet://k@1.2.3.4:8080",
+		"paqet://secret%2Fkey@vpn.example.com:443#Home",
+		"paqet://k@[2001:db8::1]:9999,h2.example:8443,5.6.7.8:1",
+		"paqet://secretkey@10.0.0.1:9090?socks=127.0.0.1:1080&mode=manual&conn=2&mtu=1400&nodelay=1&interval=20&resend=2&nc=1&wdelay=1&acknodelay=1&rcvwnd=1024&sndwnd=1024&block=aes&dscp=46&dshard=10&pshard=3&smuxbuf=4194304&streambuf=2097152&tcpbuf=4194304&udpbuf=1048576&sockbuf=4194304&log=info&socks_user=user1&socks_pass=pass1&lf=PA&rf=PA&fwd=tcp:127.0.0.1:8080%3Dinternal:80,udp:127.0.0.1:53%3D8.8.8.8:53#My%20Profile",
+	}
+
+	for _, raw := range tests {
+		t.Run(raw, func(t *testing.T) {
+			want, err := Parse(raw)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			compact := want.Compact()
+			if !IsCompact(compact) {
+				t.Fatalf("Compact() = %q, missing prefix", compact)
+			}
+			got, err := Parse(compact)
+			if err != nil {
+				t.Fatalf("Parse(compact) failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("compact round trip = %+v, want %+v", got, want)
+			}
+			if got.String() != want.String() {
+				t.Errorf("text form changed: %q, want %q", got.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestCompactIsShorter(t *testing.T) {
+	u, err := Parse("paqet://secretkey@10.0.0.1:9090?socks=127.0.0.1:1080&mode=fast3&conn=2&mtu=1400&rcvwnd=1024&sndwnd=1024&block=aes&dscp=46&dshard=10&pshard=3&smuxbuf=4194304&streambuf=2097152&tcpbuf=4194304&udpbuf=1048576&sockbuf=4194304#MyProfile")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if text, compact := u.String(), u.Compact(); len(compact) >= len(text)*2/3 {
+		t.Errorf("compact form is %d bytes, text form %d; want at least a third shorter", len(compact), len(text))
+	}
+}
+
+// compactLink builds a compact link from raw body bytes with a valid checksum.
+func compactLink(body []byte) string {
+	data := binary.BigEndian.AppendUint32(body, crc32.ChecksumIEEE(body))
+	return compactPrefix + base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestCompactRejectsCorruption(t *testing.T) {
+	link := (&PaqetURI{Key: "k", Host: "1.2.3.4", Port: 8080}).Compact()
+
+	// Flip a character in the payload
+	b := []byte(link)
+	i := len(compactPrefix) + 3
+	if b[i] == 'A' {
+		b[i] = 'B'
+	} else {
+		b[i] = 'A'
+	}
+	if _, err := Parse(string(b)); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("corrupted link: err = %v, want checksum error", err)
+	}
+
+	for name, raw := range map[string]string{
+		"not base64":    compactPrefix + "!!!",
+		"too short":     compactPrefix + "AAA",
+		"truncated":     compactLink([]byte{1, 1<<1 | wireBytes, 10, 'k'}),
+		"missing key":   compactLink([]byte{1, fieldPort << 1, 80}),
+		"version zero":  compactLink([]byte{0}),
+		"bad encoding":  compactLink([]byte{1, fieldKey<<1 | wireBytes, 1, 'k', fieldHost<<1 | wireBytes, 1, 'h', fieldPort << 1, 80, 10 << 1, 3}),
+		"invalid value": compactLink([]byte{1, fieldKey<<1 | wireBytes, 1, 'k', fieldHost<<1 | wireBytes, 1, 'h', fieldPort << 1, 80, 12 << 1, 0xA8, 0x46}), // mtu=9000
+	} {
+		if _, err := Parse(raw); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestCompactUnknownFieldsWarn(t *testing.T) {
+	body := []byte{2,
+		fieldKey<<1 | wireBytes, 1, 'k',
+		fieldHost<<1 | wireBytes, 1, 'h',
+		fieldPort << 1, 80,
+		60 << 1, 7, // unknown varint field from a newer build
+		61<<1 | wireBytes, 2, 'x', 'y', // unknown bytes field
+	}
+	res, err := ParseWithWarnings(compactLink(body))
+	if err != nil {
+		t.Fatalf("ParseWithWarnings failed: %v", err)
+	}
+	if res.URI.Key != "k" || res.URI.Host != "h" || res.URI.Port != 80 {
+		t.Errorf("URI = %+v", res.URI)
+	}
+	var fields []string
+	for _, w := range res.Warnings {
+		fields = append(fields, w.Field)
+	}
+	if want := []string{"v", "field 60", "field 61"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("warnings = %v, want %v", fields, want)
+	}
+}
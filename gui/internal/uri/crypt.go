@@ -0,0 +1,129 @@
+package uri
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Encrypted share links have the form paqet://?enc=<payload>, where payload
+// is the unpadded base64url encoding of
+//
+//	version (1 byte) | salt (16 bytes) | nonce (24 bytes) | ciphertext
+//
+// The ciphertext is the plain paqet:// URI sealed with XChaCha20-Poly1305
+// under a key derived from the passphrase with Argon2id. The version and
+// salt are authenticated as additional data.
+const (
+	encVersion = 1
+	saltSize   = 16
+
+	// Argon2id parameters for version 1 (RFC 9106 second recommendation).
+	kdfTime    = 3
+	kdfMemory  = 64 * 1024 // KiB
+	kdfThreads = 4
+)
+
+var (
+	// ErrPassphraseRequired is returned when parsing an encrypted link
+	// without a passphrase.
+	ErrPassphraseRequired = errors.New("link is encrypted, passphrase required")
+
+	// ErrWrongPassphrase is returned when an encrypted link cannot be opened,
+	// either because the passphrase is wrong or the link was altered.
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted link")
+)
+
+// IsEncrypted reports whether raw is an encrypted paqet:// link.
+func IsEncrypted(raw string) bool {
+	_, ok := encPayload(raw)
+	return ok
+}
+
+// Encrypt seals the URI with a passphrase and returns an encrypted
+// paqet://?enc= link.
+func Encrypt(p *PaqetURI, passphrase string) (string, error) {
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase is required")
+	}
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+
+	header := make([]byte, 1+saltSize)
+	header[0] = encVersion
+	if _, err := rand.Read(header[1:]); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	aead, err := chacha20poly1305.NewX(deriveKey(passphrase, header[1:]))
+	if err != nil {
+		return "", err
+	}
+	payload := append(header, nonce...)
+	payload = aead.Seal(payload, nonce, []byte(p.String()), header)
+
+	return "paqet://?enc=" + base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+// Decrypt opens an encrypted paqet://?enc= link with a passphrase and parses
+// the URI inside it.
+func Decrypt(raw, passphrase string) (*PaqetURI, error) {
+	enc, ok := encPayload(raw)
+	if !ok {
+		return nil, fmt.Errorf("not an encrypted paqet:// link")
+	}
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(enc, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted payload: %w", err)
+	}
+	if len(payload) < 1+saltSize+chacha20poly1305.NonceSizeX+chacha20poly1305.Overhead {
+		return nil, fmt.Errorf("invalid encrypted payload: too short")
+	}
+	if payload[0] != encVersion {
+		return nil, fmt.Errorf("unsupported encrypted link version %d", payload[0])
+	}
+
+	header := payload[:1+saltSize]
+	nonce := payload[len(header) : len(header)+chacha20poly1305.NonceSizeX]
+	ciphertext := payload[len(header)+len(nonce):]
+
+	aead, err := chacha20poly1305.NewX(deriveKey(passphrase, header[1:]))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	return Parse(string(plain))
+}
+
+func deriveKey(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, kdfTime, kdfMemory, kdfThreads, chacha20poly1305.KeySize)
+}
+
+// encPayload returns the enc parameter of an encrypted link.
+func encPayload(raw string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme != "paqet" || u.User != nil {
+		return "", false
+	}
+	enc := u.Query().Get("enc")
+	return enc, enc != ""
+}
@@ -0,0 +1,118 @@
+package uri
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/omid3098/autopaqet/gui/internal/config"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	original := &PaqetURI{
+		Key:       "secret/key+with=chars",
+		Host:      "2001:db8::1",
+		Port:      9090,
+		Name:      "My Server",
+		Mode:      "fast3",
+		SocksUser: "user",
+		SocksPass: "pass",
+		Forward:   []config.ForwardRule{{Listen: "127.0.0.1:8080", Target: "internal:80", Protocol: "tcp"}},
+	}
+
+	link, err := Encrypt(original, "correct horse")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if !strings.HasPrefix(link, "paqet://?enc=") {
+		t.Errorf("link = %q, want paqet://?enc= prefix", link)
+	}
+	if strings.Contains(link, "secret") || strings.Contains(link, "2001") {
+		t.Errorf("link leaks plaintext: %q", link)
+	}
+	if !IsEncrypted(link) {
+		t.Error("IsEncrypted = false for encrypted link")
+	}
+
+	got, err := Decrypt(link, "correct horse")
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, original) {
+		t.Errorf("round trip = %+v, want %+v", got, original)
+	}
+}
+
+func TestEncryptUsesFreshSalt(t *testing.T) {
+	u := &PaqetURI{Key: "k", Host: "1.2.3.4", Port: 1}
+	a, err := Encrypt(u, "pw")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	b, err := Encrypt(u, "pw")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if a == b {
+		t.Error("encrypting twice produced the same link")
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	link, err := Encrypt(&PaqetURI{Key: "k", Host: "1.2.3.4", Port: 1}, "pw")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	if _, err := Decrypt(link, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("wrong passphrase: err = %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := Decrypt(link, ""); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("empty passphrase: err = %v, want ErrPassphraseRequired", err)
+	}
+
+	// Flip a character in the ciphertext
+	tampered := []byte(link)
+	i := len(tampered) - 5
+	if tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+	if _, err := Decrypt(string(tampered), "pw"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("tampered link: err = %v, want ErrWrongPassphrase", err)
+	}
+
+	for _, raw := range []string{
+		"paqet://k@1.2.3.4:1",
+		"paqet://?enc=AAAA",
+		"paqet://?enc=!!!",
+	} {
+		if _, err := Decrypt(raw, "pw"); err == nil {
+			t.Errorf("Decrypt(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestParseEncryptedRequiresPassphrase(t *testing.T) {
+	link, err := Encrypt(&PaqetURI{Key: "k", Host: "1.2.3.4", Port: 1}, "pw")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if _, err := Parse(link); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("Parse: err = %v, want ErrPassphraseRequired", err)
+	}
+	if IsEncrypted("paqet://k@1.2.3.4:1?enc=x") {
+		t.Error("IsEncrypted = true for plain link with an enc parameter")
+	}
+}
+
+func TestEncryptRejects(t *testing.T) {
+	if _, err := Encrypt(&PaqetURI{Key: "k", Host: "1.2.3.4", Port: 1}, ""); err == nil {
+		t.Error("expected error for empty passphrase")
+	}
+	if _, err := Encrypt(&PaqetURI{Host: "1.2.3.4", Port: 1}, "pw"); err == nil {
+		t.Error("expected error for invalid URI")
+	}
+}
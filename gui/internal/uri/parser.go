@@ -2,9 +2,14 @@ package uri
 
 import (
 	"fmt"
+	"net"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/omid3098/autopaqet/gui/internal/config"
+	"github.com/omid3098/autopaqet/gui/internal/validate"
 )
 
 // PaqetURI represents a parsed paqet:// URI with all configuration fields.
@@ -14,6 +19,10 @@ type PaqetURI struct {
 	Host string
 	Port int
 
+	// Failover endpoints (host:port) tried in order after Host:Port, written
+	// as paqet://key@host:port,host2:port2
+	Fallbacks []string
+
 	// Optional identity
 	Name string // from fragment
 
@@ -28,6 +37,14 @@ type PaqetURI struct {
 	MTU   int
 	Block string // aes, etc.
 
+	// Manual KCP tuning (mode=manual)
+	NoDelay      int
+	Interval     int
+	Resend       int
+	NoCongestion int
+	WDelay       bool
+	AckNoDelay   bool
+
 	// KCP windows
 	RcvWnd int
 	SndWnd int
@@ -51,14 +68,79 @@ type PaqetURI struct {
 	RemoteFlag string
 
 	// Forwarding
-	Forward string
+	Forward []config.ForwardRule
 
 	// Logging
 	Log string
+
+	// Publisher signature (see Sign)
+	Signature string
+	PublicKey string
+}
+
+// FormatVersion is the link format written by String as the v parameter.
+// Links from newer builds may carry parameters this build does not know, so
+// parsing them warns rather than silently dropping settings.
+const FormatVersion = 1
+
+// knownParams are the query parameters of the current format version.
+var knownParams = map[string]bool{
+	"v": true, "socks": true, "socks_user": true, "socks_pass": true,
+	"mode": true, "conn": true, "mtu": true, "block": true,
+	"nodelay": true, "interval": true, "resend": true, "nc": true,
+	"wdelay": true, "acknodelay": true, "rcvwnd": true, "sndwnd": true,
+	"dshard": true, "pshard": true, "dscp": true,
+	"smuxbuf": true, "streambuf": true, "tcpbuf": true, "udpbuf": true, "sockbuf": true,
+	"lf": true, "rf": true, "fwd": true, "log": true,
+	"sig": true, "pub": true,
+}
+
+// deprecatedParams maps accepted aliases to their current parameter names.
+// They are the profile JSON names, which hand-written links tend to use.
+var deprecatedParams = map[string]string{
+	"socks_listen": "socks",
+	"nocongestion": "nc",
+	"local_flag":   "lf",
+	"remote_flag":  "rf",
+	"forward":      "fwd",
+	"log_level":    "log",
 }
 
-// Parse parses a paqet:// URI string into a PaqetURI struct.
+// ParseResult is a parsed URI along with anything in the link that was
+// ignored or rewritten, which the user should see before saving it.
+type ParseResult struct {
+	URI *PaqetURI `json:"uri"`
+
+	// Warnings lists unknown, repeated and deprecated parameters, numbers
+	// and booleans that could not be read, and a format version newer than
+	// FormatVersion, keyed by parameter name.
+	Warnings []*validate.FieldError `json:"warnings,omitempty"`
+}
+
+// Parse parses a paqet:// URI string, in text or compact form, into a
+// PaqetURI struct, ignoring warnings. Encrypted links fail with
+// ErrPassphraseRequired; open those with Decrypt.
 func Parse(raw string) (*PaqetURI, error) {
+	res, err := ParseWithWarnings(raw)
+	if err != nil {
+		return nil, err
+	}
+	return res.URI, nil
+}
+
+// ParseWithWarnings parses a paqet:// URI string and reports parameters that
+// were ignored or rewritten. A number or boolean that cannot be read is left
+// at its default with a warning; a bad key, host or port, and values out of
+// range, are errors, as in Parse.
+func ParseWithWarnings(raw string) (*ParseResult, error) {
+	if IsEncrypted(raw) {
+		return nil, ErrPassphraseRequired
+	}
+	if IsCompact(raw) {
+		return parseCompact(raw)
+	}
+
+	raw, fallbacks := splitEndpoints(raw)
 	u, err := url.Parse(raw)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URI: %w", err)
@@ -82,12 +164,12 @@ func Parse(raw string) (*PaqetURI, error) {
 	if portStr == "" {
 		return nil, fmt.Errorf("missing port")
 	}
-	port, err := strconv.Atoi(portStr)
+	port, err := validate.Int("port", portStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid port %q: %w", portStr, err)
+		return nil, err
 	}
-	if port < 1 || port > 65535 {
-		return nil, fmt.Errorf("port %d out of range (1-65535)", port)
+	if err := validate.Port("port", port); err != nil {
+		return nil, err
 	}
 
 	result := &PaqetURI{
@@ -95,6 +177,13 @@ func Parse(raw string) (*PaqetURI, error) {
 		Host: host,
 		Port: port,
 	}
+	for _, ep := range fallbacks {
+		// Normalize IPv6 brackets; invalid entries are reported by Validate.
+		if h, p, err := net.SplitHostPort(ep); err == nil {
+			ep = net.JoinHostPort(h, p)
+		}
+		result.Fallbacks = append(result.Fallbacks, ep)
+	}
 
 	// Parse fragment as profile name
 	if u.Fragment != "" {
@@ -102,7 +191,10 @@ func Parse(raw string) (*PaqetURI, error) {
 	}
 
 	// Parse query parameters
-	q := u.Query()
+	q, warnings, err := normalizeQuery(u.Query())
+	if err != nil {
+		return nil, err
+	}
 	result.Socks = q.Get("socks")
 	result.SocksUser = q.Get("socks_user")
 	result.SocksPass = q.Get("socks_pass")
@@ -110,27 +202,138 @@ func Parse(raw string) (*PaqetURI, error) {
 	result.Block = q.Get("block")
 	result.LocalFlag = q.Get("lf")
 	result.RemoteFlag = q.Get("rf")
-	result.Forward = q.Get("fwd")
 	result.Log = q.Get("log")
+	result.Signature = q.Get("sig")
+	result.PublicKey = q.Get("pub")
+
+	// Malformed numbers and booleans fall back to the default with a warning.
+	result.Conn = getIntParam(q, "conn", &warnings)
+	result.MTU = getIntParam(q, "mtu", &warnings)
+	result.NoDelay = getIntParam(q, "nodelay", &warnings)
+	result.Interval = getIntParam(q, "interval", &warnings)
+	result.Resend = getIntParam(q, "resend", &warnings)
+	result.NoCongestion = getIntParam(q, "nc", &warnings)
+	result.WDelay = getBoolParam(q, "wdelay", &warnings)
+	result.AckNoDelay = getBoolParam(q, "acknodelay", &warnings)
+	result.RcvWnd = getIntParam(q, "rcvwnd", &warnings)
+	result.SndWnd = getIntParam(q, "sndwnd", &warnings)
+	result.DSCP = getIntParam(q, "dscp", &warnings)
+	result.DShard = getIntParam(q, "dshard", &warnings)
+	result.PShard = getIntParam(q, "pshard", &warnings)
+	result.SmuxBuf = getIntParam(q, "smuxbuf", &warnings)
+	result.StreamBuf = getIntParam(q, "streambuf", &warnings)
+	result.TCPBuf = getIntParam(q, "tcpbuf", &warnings)
+	result.UDPBuf = getIntParam(q, "udpbuf", &warnings)
+	result.SockBuf = getIntParam(q, "sockbuf", &warnings)
+	var errs validate.Errors
+	if fwd := q.Get("fwd"); fwd != "" {
+		rules, err := config.ParseForward(fwd)
+		if err != nil {
+			errs.Add(&validate.FieldError{Field: "fwd", Value: fwd, Message: err.Error()})
+		}
+		result.Forward = rules
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
 
-	result.Conn = getIntParam(q, "conn")
-	result.MTU = getIntParam(q, "mtu")
-	result.RcvWnd = getIntParam(q, "rcvwnd")
-	result.SndWnd = getIntParam(q, "sndwnd")
-	result.DSCP = getIntParam(q, "dscp")
-	result.DShard = getIntParam(q, "dshard")
-	result.PShard = getIntParam(q, "pshard")
-	result.SmuxBuf = getIntParam(q, "smuxbuf")
-	result.StreamBuf = getIntParam(q, "streambuf")
-	result.TCPBuf = getIntParam(q, "tcpbuf")
-	result.UDPBuf = getIntParam(q, "udpbuf")
-	result.SockBuf = getIntParam(q, "sockbuf")
+	if err := result.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &ParseResult{URI: result, Warnings: warnings}, nil
+}
 
-	return result, nil
+// Endpoints returns the primary endpoint followed by the fallbacks.
+func (p *PaqetURI) Endpoints() []string {
+	return append([]string{net.JoinHostPort(p.Host, strconv.Itoa(p.Port))}, p.Fallbacks...)
+}
+
+// splitEndpoints removes failover endpoints from the authority of raw, so
+// the rest parses as a regular URL, and returns them separately.
+func splitEndpoints(raw string) (string, []string) {
+	i := strings.Index(raw, "://")
+	if i < 0 {
+		return raw, nil
+	}
+	start := i + len("://")
+	end := len(raw)
+	if j := strings.IndexAny(raw[start:], "/?#"); j >= 0 {
+		end = start + j
+	}
+	hostStart := start + strings.LastIndex(raw[start:end], "@") + 1
+
+	endpoints := strings.Split(raw[hostStart:end], ",")
+	if len(endpoints) == 1 {
+		return raw, nil
+	}
+	return raw[:hostStart] + endpoints[0] + raw[end:], endpoints[1:]
+}
+
+// normalizeQuery checks the format version, renames deprecated parameters
+// and collects warnings for anything that will be ignored.
+func normalizeQuery(q url.Values) (url.Values, []*validate.FieldError, error) {
+	version := FormatVersion
+	if v := q.Get("v"); v != "" {
+		n, err := validate.Int("v", v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if n < 1 {
+			return nil, nil, &validate.FieldError{Field: "v", Value: v, Message: fmt.Sprintf("invalid format version %d", n)}
+		}
+		version = n
+	}
+
+	var warnings []*validate.FieldError
+	warn := func(key, msg string, args ...interface{}) {
+		warnings = append(warnings, &validate.FieldError{Field: key, Value: q.Get(key), Message: fmt.Sprintf(msg, args...)})
+	}
+	if version > FormatVersion {
+		warn("v", "link format version %d is newer than this app supports (%d), some settings may be lost", version, FormatVersion)
+	}
+
+	keys := make([]string, 0, len(q))
+	for key := range q {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := url.Values{}
+	for _, key := range keys {
+		if len(q[key]) > 1 {
+			warn(key, "%s is repeated, only the first value is used", key)
+		}
+		if current, ok := deprecatedParams[key]; ok {
+			if q.Has(current) {
+				warn(key, "%s is ignored because %s is also set", key, current)
+			} else {
+				warn(key, "%s is deprecated, use %s", key, current)
+				out.Set(current, q.Get(key))
+			}
+			continue
+		}
+		if !knownParams[key] {
+			if version > FormatVersion {
+				warn(key, "%s is not supported by this app version and is ignored", key)
+			} else {
+				warn(key, "unknown parameter %s is ignored", key)
+			}
+			continue
+		}
+		out.Set(key, q.Get(key))
+	}
+	return out, warnings, nil
 }
 
 // String serializes the PaqetURI back into a paqet:// URI string.
 func (p *PaqetURI) String() string {
+	return p.text(true)
+}
+
+// text serializes the PaqetURI, leaving out the v parameter unless versioned
+// is set.
+func (p *PaqetURI) text(versioned bool) string {
 	var b strings.Builder
 	b.WriteString("paqet://")
 	b.WriteString(url.PathEscape(p.Key))
@@ -146,13 +349,26 @@ func (p *PaqetURI) String() string {
 	}
 	b.WriteString(":")
 	b.WriteString(strconv.Itoa(p.Port))
+	for _, ep := range p.Fallbacks {
+		b.WriteString(",")
+		b.WriteString(ep)
+	}
 
 	// Query params - only include non-zero values
 	q := url.Values{}
+	if versioned {
+		addIntParam(q, "v", FormatVersion)
+	}
 	addStringParam(q, "socks", p.Socks)
 	addStringParam(q, "mode", p.Mode)
 	addIntParam(q, "conn", p.Conn)
 	addIntParam(q, "mtu", p.MTU)
+	addIntParam(q, "nodelay", p.NoDelay)
+	addIntParam(q, "interval", p.Interval)
+	addIntParam(q, "resend", p.Resend)
+	addIntParam(q, "nc", p.NoCongestion)
+	addBoolParam(q, "wdelay", p.WDelay)
+	addBoolParam(q, "acknodelay", p.AckNoDelay)
 	addIntParam(q, "rcvwnd", p.RcvWnd)
 	addIntParam(q, "sndwnd", p.SndWnd)
 	addStringParam(q, "block", p.Block)
@@ -169,7 +385,9 @@ func (p *PaqetURI) String() string {
 	addStringParam(q, "log", p.Log)
 	addStringParam(q, "socks_user", p.SocksUser)
 	addStringParam(q, "socks_pass", p.SocksPass)
-	addStringParam(q, "fwd", p.Forward)
+	addStringParam(q, "fwd", config.FormatForward(p.Forward))
+	addStringParam(q, "sig", p.Signature)
+	addStringParam(q, "pub", p.PublicKey)
 
 	encoded := q.Encode()
 	if encoded != "" {
@@ -185,15 +403,72 @@ func (p *PaqetURI) String() string {
 	return b.String()
 }
 
-func getIntParam(q url.Values, key string) int {
+// Validate checks all fields against paqet's accepted values. Errors are
+// reported per query parameter name.
+func (p *PaqetURI) Validate() error {
+	var errs validate.Errors
+	if p.Key == "" {
+		errs.Add(&validate.FieldError{Field: "key", Message: "key is required"})
+	}
+	if p.Host == "" {
+		errs.Add(&validate.FieldError{Field: "host", Message: "host is required"})
+	}
+	errs.Add(validate.Port("port", p.Port))
+	errs.Add(validate.Fallbacks("fallbacks", net.JoinHostPort(p.Host, strconv.Itoa(p.Port)), p.Fallbacks))
+	errs.Add(validate.ListenAddr("socks", p.Socks))
+	errs.Add(validate.Mode("mode", p.Mode))
+	errs.Add(validate.Conn("conn", p.Conn))
+	errs.Add(validate.MTU("mtu", p.MTU))
+	errs.Add(validate.Block("block", p.Block))
+	errs.Add(validate.Toggle("nodelay", p.NoDelay))
+	errs.Add(validate.Interval("interval", p.Interval))
+	errs.Add(validate.NonNegative("resend", p.Resend))
+	errs.Add(validate.Toggle("nc", p.NoCongestion))
+	errs.Add(validate.NonNegative("rcvwnd", p.RcvWnd))
+	errs.Add(validate.NonNegative("sndwnd", p.SndWnd))
+	errs.Add(validate.Shards("dshard", p.DShard, "pshard", p.PShard))
+	errs.Add(validate.DSCP("dscp", p.DSCP))
+	errs.Add(validate.NonNegative("smuxbuf", p.SmuxBuf))
+	errs.Add(validate.NonNegative("streambuf", p.StreamBuf))
+	errs.Add(validate.NonNegative("tcpbuf", p.TCPBuf))
+	errs.Add(validate.NonNegative("udpbuf", p.UDPBuf))
+	errs.Add(validate.NonNegative("sockbuf", p.SockBuf))
+	errs.Add(validate.Flag("lf", p.LocalFlag))
+	errs.Add(validate.Flag("rf", p.RemoteFlag))
+	errs.Add(config.ValidateForward("fwd", p.Forward))
+	return errs.Err()
+}
+
+// getIntParam reads an integer query parameter. Missing and malformed
+// parameters are 0, paqet's default; malformed ones add a warning.
+func getIntParam(q url.Values, key string, warnings *[]*validate.FieldError) int {
 	v := q.Get(key)
 	if v == "" {
 		return 0
 	}
-	n, _ := strconv.Atoi(v)
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		*warnings = append(*warnings, &validate.FieldError{Field: key, Value: v, Message: fmt.Sprintf("invalid number %q is ignored, the default is used", v)})
+		return 0
+	}
 	return n
 }
 
+// getBoolParam reads a boolean query parameter ("1", "true", ...). Missing
+// and malformed parameters are false; malformed ones add a warning.
+func getBoolParam(q url.Values, key string, warnings *[]*validate.FieldError) bool {
+	v := q.Get(key)
+	if v == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		*warnings = append(*warnings, &validate.FieldError{Field: key, Value: v, Message: fmt.Sprintf("invalid boolean %q is ignored, the default is used", v)})
+		return false
+	}
+	return b
+}
+
 func addStringParam(q url.Values, key, val string) {
 	if val != "" {
 		q.Set(key, val)
@@ -205,3 +480,9 @@ func addIntParam(q url.Values, key string, val int) {
 		q.Set(key, strconv.Itoa(val))
 	}
 }
+
+func addBoolParam(q url.Values, key string, val bool) {
+	if val {
+		q.Set(key, "1")
+	}
+}
@@ -1,7 +1,12 @@
 package uri
 
 import (
+	"errors"
+	"reflect"
 	"testing"
+
+	"github.com/omid3098/autopaqet/gui/internal/config"
+	"github.com/omid3098/autopaqet/gui/internal/validate"
 )
 
 func TestParseMinimalURI(t *testing.T) {
@@ -302,8 +307,42 @@ func TestParseWithForwardRules(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if u.Forward != "tcp:8080:internal:80,udp:9090:internal:9090" {
-		t.Errorf("Forward = %q, want %q", u.Forward, "tcp:8080:internal:80,udp:9090:internal:9090")
+	want := []config.ForwardRule{
+		{Listen: "127.0.0.1:8080", Target: "internal:80", Protocol: "tcp"},
+		{Listen: "127.0.0.1:9090", Target: "internal:9090", Protocol: "udp"},
+	}
+	if !reflect.DeepEqual(u.Forward, want) {
+		t.Errorf("Forward = %+v, want %+v", u.Forward, want)
+	}
+
+	reparsed, err := Parse(u.String())
+	if err != nil {
+		t.Fatalf("re-parse failed: %v", err)
+	}
+	if !reflect.DeepEqual(reparsed.Forward, want) {
+		t.Errorf("round trip Forward = %+v, want %+v", reparsed.Forward, want)
+	}
+}
+
+func TestParseManualKCPParams(t *testing.T) {
+	raw := "paqet://k@1.2.3.4:8080?mode=manual&nodelay=1&interval=20&resend=2&nc=1&wdelay=false&acknodelay=1"
+	u, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u.NoDelay != 1 || u.Interval != 20 || u.Resend != 2 || u.NoCongestion != 1 {
+		t.Errorf("manual params = %d/%d/%d/%d, want 1/20/2/1", u.NoDelay, u.Interval, u.Resend, u.NoCongestion)
+	}
+	if u.WDelay || !u.AckNoDelay {
+		t.Errorf("wdelay/acknodelay = %v/%v, want false/true", u.WDelay, u.AckNoDelay)
+	}
+
+	reparsed, err := Parse(u.String())
+	if err != nil {
+		t.Fatalf("re-parse failed: %v", err)
+	}
+	if !reflect.DeepEqual(reparsed, u) {
+		t.Errorf("round trip = %+v, want %+v", reparsed, u)
 	}
 }
 
@@ -325,8 +364,110 @@ func TestStringMinimalOmitsDefaults(t *testing.T) {
 		Port: 8080,
 	}
 	s := u.String()
-	if s != "paqet://key@1.2.3.4:8080" {
-		t.Errorf("String() = %q, want %q", s, "paqet://key@1.2.3.4:8080")
+	if s != "paqet://key@1.2.3.4:8080?v=1" {
+		t.Errorf("String() = %q, want %q", s, "paqet://key@1.2.3.4:8080?v=1")
+	}
+}
+
+func TestParseMultipleEndpoints(t *testing.T) {
+	raw := "paqet://key@1.2.3.4:9999,h2.example:8443,[2001:db8::1]:443?mode=fast#Multi"
+	u, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u.Host != "1.2.3.4" || u.Port != 9999 {
+		t.Errorf("primary = %s:%d, want 1.2.3.4:9999", u.Host, u.Port)
+	}
+	want := []string{"h2.example:8443", "[2001:db8::1]:443"}
+	if !reflect.DeepEqual(u.Fallbacks, want) {
+		t.Errorf("Fallbacks = %v, want %v", u.Fallbacks, want)
+	}
+	if got := u.Endpoints(); len(got) != 3 || got[0] != "1.2.3.4:9999" {
+		t.Errorf("Endpoints = %v", got)
+	}
+	if u.Mode != "fast" || u.Name != "Multi" {
+		t.Errorf("Mode = %q, Name = %q", u.Mode, u.Name)
+	}
+
+	reparsed, err := Parse(u.String())
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if !reflect.DeepEqual(reparsed, u) {
+		t.Errorf("round trip = %+v, want %+v", reparsed, u)
+	}
+}
+
+func TestParseRejectsInvalidEndpoints(t *testing.T) {
+	for _, raw := range []string{
+		"paqet://key@1.2.3.4:9999,h2.example",
+		"paqet://key@1.2.3.4:9999,h2.example:0",
+		"paqet://key@1.2.3.4:9999,1.2.3.4:9999",
+		"paqet://key@1.2.3.4:9999,",
+	} {
+		_, err := Parse(raw)
+		var errs validate.Errors
+		if !errors.As(err, &errs) || errs[0].Field != "fallbacks[0]" {
+			t.Errorf("Parse(%q): err = %v, want error on fallbacks[0]", raw, err)
+		}
+	}
+}
+
+func TestParseWithWarnings(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		wantWarns []string // fields
+		check     func(u *PaqetURI) bool
+	}{
+		{"clean", "paqet://k@1.2.3.4:8080?v=1&mode=fast", nil, nil},
+		{"no version", "paqet://k@1.2.3.4:8080?mode=fast", nil, nil},
+		{"unknown key", "paqet://k@1.2.3.4:8080?mdoe=fast&colour=red", []string{"colour", "mdoe"},
+			func(u *PaqetURI) bool { return u.Mode == "" }},
+		{"deprecated alias", "paqet://k@1.2.3.4:8080?local_flag=PA&nocongestion=1", []string{"local_flag", "nocongestion"},
+			func(u *PaqetURI) bool { return u.LocalFlag == "PA" && u.NoCongestion == 1 }},
+		{"alias and current", "paqet://k@1.2.3.4:8080?log_level=debug&log=info", []string{"log_level"},
+			func(u *PaqetURI) bool { return u.Log == "info" }},
+		{"repeated", "paqet://k@1.2.3.4:8080?conn=2&conn=3", []string{"conn"},
+			func(u *PaqetURI) bool { return u.Conn == 2 }},
+		{"newer version", "paqet://k@1.2.3.4:8080?v=2&mode=fast&obfs=tls", []string{"v", "obfs"},
+			func(u *PaqetURI) bool { return u.Mode == "fast" }},
+		{"non-numeric conn", "paqet://k@1.2.3.4:8080?conn=two&mode=fast", []string{"conn"},
+			func(u *PaqetURI) bool { return u.Conn == 0 && u.Mode == "fast" && u.Key == "k" }},
+		{"non-numeric mtu and bad boolean", "paqet://k@1.2.3.4:8080?mtu=big&acknodelay=sometimes&rcvwnd=512", []string{"mtu", "acknodelay"},
+			func(u *PaqetURI) bool { return u.MTU == 0 && !u.AckNoDelay && u.RcvWnd == 512 }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := ParseWithWarnings(tc.raw)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, w := range res.Warnings {
+				got = append(got, w.Field)
+			}
+			if !reflect.DeepEqual(got, tc.wantWarns) {
+				t.Errorf("warnings = %v, want %v", res.Warnings, tc.wantWarns)
+			}
+			if tc.check != nil && !tc.check(res.URI) {
+				t.Errorf("unexpected URI %+v", res.URI)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidVersion(t *testing.T) {
+	for _, raw := range []string{
+		"paqet://k@1.2.3.4:8080?v=one",
+		"paqet://k@1.2.3.4:8080?v=0",
+	} {
+		_, err := Parse(raw)
+		var fe *validate.FieldError
+		if !errors.As(err, &fe) || fe.Field != "v" {
+			t.Errorf("Parse(%q): err = %v, want error on v", raw, err)
+		}
 	}
 }
 
@@ -340,3 +481,37 @@ func TestParseURLEncodedFragment(t *testing.T) {
 		t.Errorf("Name = %q, want %q", u.Name, "My Profile")
 	}
 }
+
+func TestParseRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		wantField string
+	}{
+		{"conn out of range", "paqet://k@1.2.3.4:8080?conn=300", "conn"},
+		{"mtu out of range", "paqet://k@1.2.3.4:8080?mtu=9000", "mtu"},
+		{"bad mode", "paqet://k@1.2.3.4:8080?mode=turbo", "mode"},
+		{"bad block", "paqet://k@1.2.3.4:8080?block=rot13", "block"},
+		{"bad local flag", "paqet://k@1.2.3.4:8080?lf=SYN", "lf"},
+		{"bad remote flag", "paqet://k@1.2.3.4:8080?rf=x", "rf"},
+		{"parity without data", "paqet://k@1.2.3.4:8080?pshard=3", "dshard"},
+		{"bad socks", "paqet://k@1.2.3.4:8080?socks=localhost", "socks"},
+		{"bad nodelay", "paqet://k@1.2.3.4:8080?mode=manual&nodelay=2", "nodelay"},
+		{"interval out of range", "paqet://k@1.2.3.4:8080?mode=manual&interval=1", "interval"},
+		{"bad forward rule", "paqet://k@1.2.3.4:8080?fwd=tcp:8080", "fwd"},
+		{"duplicate forward listen", "paqet://k@1.2.3.4:8080?fwd=tcp:8080:a:80,tcp:8080:b:80", "fwd[1].listen"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(tc.raw)
+			var errs validate.Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected validate.Errors, got %v", err)
+			}
+			if errs[0].Field != tc.wantField {
+				t.Errorf("Field = %q, want %q", errs[0].Field, tc.wantField)
+			}
+		})
+	}
+}
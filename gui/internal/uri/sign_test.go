@@ -0,0 +1,119 @@
+package uri
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	pub, priv, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey failed: %v", err)
+	}
+	if !ValidPublicKey(pub) || ValidPublicKey(priv+"x") {
+		t.Error("ValidPublicKey gave the wrong answer")
+	}
+
+	u, err := Parse("paqet://key@1.2.3.4:8080,5.6.7.8:443?mode=fast#Office")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := u.Verify(); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("unsigned: err = %v, want ErrUnsigned", err)
+	}
+	if err := u.Sign(priv); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if u.PublicKey != pub {
+		t.Errorf("PublicKey = %q, want %q", u.PublicKey, pub)
+	}
+
+	// The signature survives every link form
+	encrypted, err := Encrypt(u, "pw")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	decrypted, err := Decrypt(encrypted, "pw")
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	for name, raw := range map[string]string{"text": u.String(), "compact": u.Compact()} {
+		parsed, err := Parse(raw)
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v", name, err)
+		}
+		if err := parsed.Verify(); err != nil {
+			t.Errorf("%s: Verify failed: %v", name, err)
+		}
+	}
+	if err := decrypted.Verify(); err != nil {
+		t.Errorf("encrypted: Verify failed: %v", err)
+	}
+}
+
+// knownSignedLink was signed once with a fixed key. It must keep verifying in
+// every later build, whatever format version that build writes.
+const knownSignedLink = "paqet://key@1.2.3.4:8080?mode=fast&pub=A6EHv_POEL4dcN0Y50vAmWfk1jCbpQ1fHdyGZBJVMbg&sig=6NfzNeRvGii7DTBlzbxsDRLbFYOGfmHg8lDTl0awH3qz62UTf1OaRJo8-36L_LB1jr7x2vHjZElR1YfMhCQWCw&v=1#Office"
+
+func TestVerifyKnownSignedLink(t *testing.T) {
+	for _, raw := range []string{
+		knownSignedLink,
+		strings.Replace(knownSignedLink, "&v=1", "", 1),
+		strings.Replace(knownSignedLink, "&v=1", "&v=9", 1),
+	} {
+		u, err := Parse(raw)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", raw, err)
+		}
+		if err := u.Verify(); err != nil {
+			t.Errorf("Verify(%q) = %v", raw, err)
+		}
+	}
+
+	tampered, err := Parse(strings.Replace(knownSignedLink, "mode=fast", "mode=fast2", 1))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := tampered.Verify(); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("tampered: err = %v, want ErrBadSignature", err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	_, priv, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey failed: %v", err)
+	}
+	u := &PaqetURI{Key: "key", Host: "1.2.3.4", Port: 8080, Name: "Office"}
+	if err := u.Sign(priv); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	tampered := *u
+	tampered.Host = "6.6.6.6"
+	if err := tampered.Verify(); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("changed host: err = %v, want ErrBadSignature", err)
+	}
+
+	// The signature does not transfer to another publisher's key
+	otherPub, _, _ := GenerateSigningKey()
+	forged := *u
+	forged.PublicKey = otherPub
+	if err := forged.Verify(); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("swapped key: err = %v, want ErrBadSignature", err)
+	}
+
+	garbled := *u
+	garbled.Signature = "not-base64!"
+	if err := garbled.Verify(); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("garbled signature: err = %v, want ErrBadSignature", err)
+	}
+}
+
+func TestSignRejectsBadKey(t *testing.T) {
+	u := &PaqetURI{Key: "key", Host: "1.2.3.4", Port: 8080}
+	if err := u.Sign("short"); err == nil {
+		t.Error("expected error for invalid private key")
+	}
+}
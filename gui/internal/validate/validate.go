@@ -0,0 +1,271 @@
+// Package validate checks paqet configuration values shared by profiles,
+// URIs and generated configs. Each check returns a *FieldError naming the
+// offending field so callers (and the frontend) can point at it directly.
+package validate
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Valid KCP modes, block ciphers, TCP flags and forwarding protocols accepted by paqet.
+var (
+	Modes     = []string{"normal", "fast", "fast2", "fast3", "manual"}
+	Blocks    = []string{"aes", "aes-128", "aes-192", "salsa20", "blowfish", "twofish", "cast5", "3des", "tea", "xtea", "xor", "sm4", "none"}
+	Flags     = []string{"S", "PA", "A"}
+	Protocols = []string{"tcp", "udp"}
+)
+
+// Numeric bounds.
+const (
+	MinConn   = 1
+	MaxConn   = 256
+	MinPort   = 1
+	MaxPort   = 65535
+	MinMTU    = 50
+	MaxMTU    = 1500
+	MaxShards = 256 // data + parity shards combined
+	MaxDSCP   = 63
+
+	MinInterval = 10 // KCP update interval in ms
+	MaxInterval = 5000
+)
+
+// FieldError describes a single invalid field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// Errors collects field errors from a multi-field validation.
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Add appends err if it is non-nil. Plain errors are wrapped in a FieldError
+// with an empty field name.
+func (e *Errors) Add(err error) {
+	if err == nil {
+		return
+	}
+	switch v := err.(type) {
+	case *FieldError:
+		*e = append(*e, v)
+	case Errors:
+		*e = append(*e, v...)
+	default:
+		*e = append(*e, &FieldError{Message: err.Error()})
+	}
+}
+
+// Err returns the collected errors, or nil if there are none.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func newError(field string, value interface{}, format string, args ...interface{}) *FieldError {
+	return &FieldError{
+		Field:   field,
+		Value:   fmt.Sprint(value),
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Mode checks a KCP mode. Empty means the default.
+func Mode(field, v string) error {
+	if v == "" || contains(Modes, v) {
+		return nil
+	}
+	return newError(field, v, "invalid mode %q, must be one of %s", v, strings.Join(Modes, ", "))
+}
+
+// Block checks a KCP block cipher. Empty means the default.
+func Block(field, v string) error {
+	if v == "" || contains(Blocks, v) {
+		return nil
+	}
+	return newError(field, v, "invalid block cipher %q, must be one of %s", v, strings.Join(Blocks, ", "))
+}
+
+// Flag checks a TCP flag combination. Empty means the default.
+func Flag(field, v string) error {
+	if v == "" || contains(Flags, v) {
+		return nil
+	}
+	return newError(field, v, "invalid TCP flag %q, must be one of %s", v, strings.Join(Flags, ", "))
+}
+
+// Conn checks the KCP connection count. Zero means the default.
+func Conn(field string, v int) error {
+	if v == 0 || (v >= MinConn && v <= MaxConn) {
+		return nil
+	}
+	return newError(field, v, "conn %d out of range (%d-%d)", v, MinConn, MaxConn)
+}
+
+// Port checks a required TCP/UDP port.
+func Port(field string, v int) error {
+	if v >= MinPort && v <= MaxPort {
+		return nil
+	}
+	return newError(field, v, "port %d out of range (%d-%d)", v, MinPort, MaxPort)
+}
+
+// MTU checks the KCP MTU. Zero means the default.
+func MTU(field string, v int) error {
+	if v == 0 || (v >= MinMTU && v <= MaxMTU) {
+		return nil
+	}
+	return newError(field, v, "MTU %d out of range (%d-%d)", v, MinMTU, MaxMTU)
+}
+
+// Shards checks the FEC data and parity shard counts. Both zero disables FEC.
+// Parity shards without data shards is rejected.
+func Shards(dataField string, data int, parityField string, parity int) error {
+	var errs Errors
+	if data < 0 {
+		errs.Add(newError(dataField, data, "data shards cannot be negative"))
+	}
+	if parity < 0 {
+		errs.Add(newError(parityField, parity, "parity shards cannot be negative"))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	if parity > 0 && data == 0 {
+		return newError(dataField, data, "data shards are required when parity shards are set")
+	}
+	if data+parity > MaxShards {
+		return newError(parityField, parity, "data + parity shards %d exceeds %d", data+parity, MaxShards)
+	}
+	return nil
+}
+
+// DSCP checks a DSCP value (0-63).
+func DSCP(field string, v int) error {
+	if v >= 0 && v <= MaxDSCP {
+		return nil
+	}
+	return newError(field, v, "DSCP %d out of range (0-%d)", v, MaxDSCP)
+}
+
+// NonNegative checks window and buffer sizes where zero means the default.
+func NonNegative(field string, v int) error {
+	if v >= 0 {
+		return nil
+	}
+	return newError(field, v, "value cannot be negative")
+}
+
+// Toggle checks an integer switch that must be 0 or 1, such as KCP nodelay.
+func Toggle(field string, v int) error {
+	if v == 0 || v == 1 {
+		return nil
+	}
+	return newError(field, v, "value %d must be 0 or 1", v)
+}
+
+// Interval checks the KCP update interval in milliseconds. Zero means the default.
+func Interval(field string, v int) error {
+	if v == 0 || (v >= MinInterval && v <= MaxInterval) {
+		return nil
+	}
+	return newError(field, v, "interval %d out of range (%d-%d ms)", v, MinInterval, MaxInterval)
+}
+
+// ListenAddr checks a host:port listen address such as a SOCKS5 listener.
+// The host must be an IP address, "localhost", or empty (all interfaces).
+// Empty means the default.
+func ListenAddr(field, v string) error {
+	if v == "" {
+		return nil
+	}
+	host, portStr, err := net.SplitHostPort(v)
+	if err != nil {
+		return newError(field, v, "invalid address %q, expected host:port", v)
+	}
+	if host != "" && host != "localhost" && net.ParseIP(host) == nil {
+		return newError(field, v, "invalid host %q, expected an IP address", host)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < MinPort || port > MaxPort {
+		return newError(field, v, "invalid port %q (%d-%d)", portStr, MinPort, MaxPort)
+	}
+	return nil
+}
+
+// HostPort checks a required host:port address where the host may be a
+// hostname, such as a forwarding target.
+func HostPort(field, v string) error {
+	host, portStr, err := net.SplitHostPort(v)
+	if err != nil || host == "" {
+		return newError(field, v, "invalid address %q, expected host:port", v)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < MinPort || port > MaxPort {
+		return newError(field, v, "invalid port %q (%d-%d)", portStr, MinPort, MaxPort)
+	}
+	return nil
+}
+
+// Fallbacks checks failover endpoints: each must be a host:port address that
+// differs from the primary endpoint and the others. Errors are reported as
+// field[i].
+func Fallbacks(field, primary string, endpoints []string) error {
+	var errs Errors
+	seen := map[string]bool{primary: true}
+	for i, ep := range endpoints {
+		name := fmt.Sprintf("%s[%d]", field, i)
+		if err := HostPort(name, ep); err != nil {
+			errs.Add(err)
+			continue
+		}
+		if seen[ep] {
+			errs.Add(newError(name, ep, "endpoint %s is listed more than once", ep))
+		}
+		seen[ep] = true
+	}
+	return errs.Err()
+}
+
+// Protocol checks a forwarding protocol. Empty means tcp.
+func Protocol(field, v string) error {
+	if v == "" || contains(Protocols, v) {
+		return nil
+	}
+	return newError(field, v, "invalid protocol %q, must be one of %s", v, strings.Join(Protocols, ", "))
+}
+
+// Int parses an integer field value, reporting a FieldError on failure.
+func Int(field, v string) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, newError(field, v, "invalid number %q", v)
+	}
+	return n, nil
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
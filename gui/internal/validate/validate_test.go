@@ -0,0 +1,240 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEnums(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{"empty mode", Mode("mode", ""), false},
+		{"fast3 mode", Mode("mode", "fast3"), false},
+		{"manual mode", Mode("mode", "manual"), false},
+		{"bad mode", Mode("mode", "turbo"), true},
+		{"empty block", Block("block", ""), false},
+		{"salsa20 block", Block("block", "salsa20"), false},
+		{"none block", Block("block", "none"), false},
+		{"bad block", Block("block", "rot13"), true},
+		{"empty flag", Flag("lf", ""), false},
+		{"S flag", Flag("lf", "S"), false},
+		{"PA flag", Flag("lf", "PA"), false},
+		{"A flag", Flag("lf", "A"), false},
+		{"lowercase flag", Flag("lf", "pa"), true},
+		{"bad flag", Flag("lf", "SYN"), true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if (tc.err != nil) != tc.wantErr {
+				t.Errorf("err = %v, wantErr %v", tc.err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNumericBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{"conn default", Conn("conn", 0), false},
+		{"conn min", Conn("conn", 1), false},
+		{"conn max", Conn("conn", 256), false},
+		{"conn too high", Conn("conn", 257), true},
+		{"conn negative", Conn("conn", -1), true},
+		{"port min", Port("port", 1), false},
+		{"port max", Port("port", 65535), false},
+		{"port zero", Port("port", 0), true},
+		{"port too high", Port("port", 65536), true},
+		{"mtu default", MTU("mtu", 0), false},
+		{"mtu typical", MTU("mtu", 1350), false},
+		{"mtu too small", MTU("mtu", 20), true},
+		{"mtu too large", MTU("mtu", 9000), true},
+		{"dscp ef", DSCP("dscp", 46), false},
+		{"dscp too high", DSCP("dscp", 64), true},
+		{"buffer negative", NonNegative("sockbuf", -1), true},
+		{"buffer zero", NonNegative("sockbuf", 0), false},
+		{"toggle off", Toggle("nodelay", 0), false},
+		{"toggle on", Toggle("nodelay", 1), false},
+		{"toggle invalid", Toggle("nodelay", 2), true},
+		{"interval default", Interval("interval", 0), false},
+		{"interval min", Interval("interval", 10), false},
+		{"interval max", Interval("interval", 5000), false},
+		{"interval too low", Interval("interval", 5), true},
+		{"interval too high", Interval("interval", 6000), true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if (tc.err != nil) != tc.wantErr {
+				t.Errorf("err = %v, wantErr %v", tc.err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestShards(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      int
+		parity    int
+		wantErr   bool
+		wantField string
+	}{
+		{"disabled", 0, 0, false, ""},
+		{"typical", 10, 3, false, ""},
+		{"data only", 10, 0, false, ""},
+		{"max total", 200, 56, false, ""},
+		{"parity without data", 0, 3, true, "dshard"},
+		{"too many", 200, 57, true, "pshard"},
+		{"negative data", -1, 0, true, "dshard"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Shards("dshard", tc.data, "pshard", tc.parity)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			var errs Errors
+			errs.Add(err)
+			if errs[0].Field != tc.wantField {
+				t.Errorf("Field = %q, want %q", errs[0].Field, tc.wantField)
+			}
+		})
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		wantErr bool
+	}{
+		{"", false},
+		{"127.0.0.1:1080", false},
+		{"0.0.0.0:1080", false},
+		{":1080", false},
+		{"localhost:1080", false},
+		{"[::1]:1080", false},
+		{"127.0.0.1", true},
+		{"127.0.0.1:0", true},
+		{"127.0.0.1:70000", true},
+		{"127.0.0.1:socks", true},
+		{"example.com:1080", true},
+		{"::1:1080", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.addr, func(t *testing.T) {
+			err := ListenAddr("socks", tc.addr)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ListenAddr(%q) err = %v, wantErr %v", tc.addr, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestHostPortAndProtocol(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{"ip target", HostPort("target", "10.0.0.2:80"), false},
+		{"hostname target", HostPort("target", "internal.example:5432"), false},
+		{"ipv6 target", HostPort("target", "[2001:db8::1]:443"), false},
+		{"missing host", HostPort("target", ":80"), true},
+		{"missing port", HostPort("target", "internal"), true},
+		{"bad port", HostPort("target", "internal:0"), true},
+		{"empty protocol", Protocol("protocol", ""), false},
+		{"tcp", Protocol("protocol", "tcp"), false},
+		{"udp", Protocol("protocol", "udp"), false},
+		{"sctp", Protocol("protocol", "sctp"), true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if (tc.err != nil) != tc.wantErr {
+				t.Errorf("err = %v, wantErr %v", tc.err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestFallbacks(t *testing.T) {
+	if err := Fallbacks("fallbacks", "1.2.3.4:8080", nil); err != nil {
+		t.Errorf("no fallbacks: err = %v", err)
+	}
+	if err := Fallbacks("fallbacks", "1.2.3.4:8080", []string{"1.2.3.5:8080", "[2001:db8::1]:443", "vpn.example:9999"}); err != nil {
+		t.Errorf("valid fallbacks: err = %v", err)
+	}
+
+	err := Fallbacks("fallbacks", "1.2.3.4:8080", []string{"1.2.3.5", "1.2.3.4:8080", "h:1", "h:1"})
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected Errors, got %v", err)
+	}
+	var fields []string
+	for _, fe := range errs {
+		fields = append(fields, fe.Field)
+	}
+	if strings.Join(fields, " ") != "fallbacks[0] fallbacks[1] fallbacks[3]" {
+		t.Errorf("fields = %v", fields)
+	}
+}
+
+func TestInt(t *testing.T) {
+	n, err := Int("conn", "4")
+	if err != nil || n != 4 {
+		t.Errorf("Int(4) = %d, %v", n, err)
+	}
+
+	_, err = Int("conn", "two")
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected *FieldError, got %T", err)
+	}
+	if fe.Field != "conn" || fe.Value != "two" {
+		t.Errorf("FieldError = %+v", fe)
+	}
+}
+
+func TestErrorsCollect(t *testing.T) {
+	var errs Errors
+	errs.Add(nil)
+	if errs.Err() != nil {
+		t.Fatal("expected nil error for empty collection")
+	}
+
+	errs.Add(Mode("mode", "turbo"))
+	errs.Add(Conn("conn", 999))
+	errs.Add(errors.New("plain"))
+
+	err := errs.Err()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if len(errs) != 3 {
+		t.Fatalf("collected %d errors, want 3", len(errs))
+	}
+	if errs[0].Field != "mode" || errs[1].Field != "conn" || errs[2].Field != "" {
+		t.Errorf("fields = %q, %q, %q", errs[0].Field, errs[1].Field, errs[2].Field)
+	}
+	if !strings.Contains(err.Error(), "mode:") || !strings.Contains(err.Error(), "conn:") {
+		t.Errorf("Error() = %q, want both fields mentioned", err.Error())
+	}
+
+	var out Errors
+	if !errors.As(err, &out) {
+		t.Error("expected errors.As to recover Errors")
+	}
+}